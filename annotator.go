@@ -0,0 +1,220 @@
+package cmotel
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/coordimap/cm-otel-go/internal/semconv"
+)
+
+// AnnotationRule derives Coordimap attributes from a semantic convention attribute set when the span starts
+type AnnotationRule struct {
+	// Attribute the semantic convention attribute the rule applies to, e.g. db.system
+	Attribute attribute.Key
+
+	// SpanKinds the kinds of spans the rule applies to, all of them when empty
+	SpanKinds []trace.SpanKind
+
+	// ComponentType registers the span as a component of this type, with the attribute as its data, when not empty
+	ComponentType string
+
+	// TargetService records the value of the attribute as the SpanAttrTargetService of the span
+	TargetService bool
+}
+
+func (r AnnotationRule) appliesTo(kind trace.SpanKind) bool {
+	if len(r.SpanKinds) == 0 {
+		return true
+	}
+
+	for _, ruleKind := range r.SpanKinds {
+		if ruleKind == kind {
+			return true
+		}
+	}
+
+	return false
+}
+
+// DefaultAnnotationRules the rules used by NewAnnotatingProcessor unless WithAnnotationRules is used. The first matching rule wins, so the most specific target services come first.
+func DefaultAnnotationRules() []AnnotationRule {
+	outgoing := []trace.SpanKind{trace.SpanKindClient, trace.SpanKindProducer}
+
+	rules := []AnnotationRule{
+		{Attribute: "http.route", SpanKinds: []trace.SpanKind{trace.SpanKindServer}, ComponentType: ComponentTypeHTTPRestGeneric},
+		{Attribute: "rpc.service", SpanKinds: []trace.SpanKind{trace.SpanKindServer}, ComponentType: ComponentTypeGeneric},
+	}
+	for _, key := range semconv.MessagingDestinationKeys() {
+		rules = append(rules, AnnotationRule{Attribute: attribute.Key(key), SpanKinds: outgoing, TargetService: true})
+	}
+	rules = append(rules, AnnotationRule{Attribute: "rpc.service", SpanKinds: outgoing, TargetService: true})
+	for _, key := range semconv.ServerAddressKeys() {
+		rules = append(rules, AnnotationRule{Attribute: attribute.Key(key), SpanKinds: outgoing, TargetService: true})
+	}
+
+	return append(rules, AnnotationRule{Attribute: "db.system", SpanKinds: outgoing, TargetService: true})
+}
+
+type annotatorOpts struct {
+	serviceName    string
+	rules          []AnnotationRule
+	componentRules *ComponentRules
+}
+
+// AnnotatorOption the function parameter for NewAnnotatingProcessor
+type AnnotatorOption = func(opt *annotatorOpts) error
+
+// WithAnnotatorServiceName the service the internal names of the annotated spans belong to. It defaults to the SERVICE_NAME environment variable.
+func WithAnnotatorServiceName(serviceName string) AnnotatorOption {
+	return func(opt *annotatorOpts) error {
+		if serviceName == "" {
+			return errors.New("service name must not be empty")
+		}
+
+		opt.serviceName = serviceName
+
+		return nil
+	}
+}
+
+// WithAnnotatorComponentRules the rules deriving the component of the spans, which take precedence over the component types of the annotation rules. It defaults to DefaultComponentRules.
+func WithAnnotatorComponentRules(rules *ComponentRules) AnnotatorOption {
+	return func(opt *annotatorOpts) error {
+		if rules == nil {
+			return errors.New("the component rules must not be nil")
+		}
+
+		opt.componentRules = rules
+
+		return nil
+	}
+}
+
+// WithAnnotationRules replaces the DefaultAnnotationRules, which can be passed along to extend them
+func WithAnnotationRules(rules ...AnnotationRule) AnnotatorOption {
+	return func(opt *annotatorOpts) error {
+		for _, rule := range rules {
+			if rule.Attribute == "" {
+				return errors.New("the attribute of an annotation rule must not be empty")
+			}
+
+			if rule.ComponentType == "" && !rule.TargetService {
+				return errors.New("an annotation rule must set either a component type or the target service")
+			}
+		}
+
+		opt.rules = rules
+
+		return nil
+	}
+}
+
+type annotatingProcessor struct {
+	serviceName    string
+	rules          []AnnotationRule
+	componentRules *ComponentRules
+}
+
+// NewAnnotatingProcessor creates a span processor that makes the spans of other OTel instrumentation, e.g. otelhttp, otelgrpc or otelsql, visible to Coordimap.
+// When such a span starts it gets its SpanAttrInternalName, the SpanAttrParentName of the nearest Coordimap span of its context and the component or target service derived by the first matching rules.
+// Only the attributes known when the span starts can be used by the rules. Spans created by CMOtel, whose names are internal names, are left untouched.
+func NewAnnotatingProcessor(opts ...AnnotatorOption) (sdktrace.SpanProcessor, error) {
+	options := &annotatorOpts{
+		serviceName:    GetEnvWithPrefix(GetEnvWithPrefix("", EnvCmPrefix), EnvServiceName),
+		rules:          DefaultAnnotationRules(),
+		componentRules: DefaultComponentRules(),
+	}
+
+	for _, opt := range opts {
+		if err := opt(options); err != nil {
+			return nil, err
+		}
+	}
+
+	return &annotatingProcessor{
+		serviceName:    options.serviceName,
+		rules:          options.rules,
+		componentRules: options.componentRules,
+	}, nil
+}
+
+func (p *annotatingProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	if strings.Contains(s.Name(), "@") {
+		return
+	}
+
+	attributes := map[attribute.Key]attribute.Value{}
+	for _, attr := range s.Attributes() {
+		attributes[attr.Key] = attr.Value
+	}
+
+	if _, ok := attributes[SpanAttrInternalName]; ok {
+		return
+	}
+
+	internalName := GetServiceName(p.serviceName) + "@" + s.Name()
+	annotations := []attribute.KeyValue{
+		attribute.String(SpanAttrInternalName, internalName),
+	}
+
+	if _, ok := attributes[SpanAttrParentName]; !ok {
+		if parentName, ok := InternalNameFromContext(parent); ok {
+			annotations = append(annotations, attribute.String(SpanAttrParentName, parentName))
+		}
+	}
+
+	hasComponent := false
+	hasTarget := false
+
+	if match, ok := p.componentRules.Match(s.SpanKind(), s.Attributes()); ok {
+		annotations, hasComponent = appendComponent(annotations, s.Name(), internalName, match.Type, match.Data)
+	}
+
+	for _, rule := range p.rules {
+		value, ok := attributes[rule.Attribute]
+		if !ok || value.Emit() == "" || !rule.appliesTo(s.SpanKind()) {
+			continue
+		}
+
+		if rule.ComponentType != "" && !hasComponent {
+			annotations, hasComponent = appendComponent(annotations, s.Name(), internalName, rule.ComponentType, map[string]string{string(rule.Attribute): value.Emit()})
+		}
+
+		if rule.TargetService && !hasTarget {
+			annotations = append(annotations, attribute.String(SpanAttrTargetService, value.Emit()))
+			hasTarget = true
+		}
+	}
+
+	s.SetAttributes(annotations...)
+}
+
+func appendComponent(annotations []attribute.KeyValue, name, internalName, componentType string, data map[string]string) ([]attribute.KeyValue, bool) {
+	component, errMarshal := json.Marshal(CMComponent{
+		Name:       name,
+		InternalID: internalName,
+		Type:       componentType,
+		Data:       data,
+	})
+	if errMarshal != nil {
+		return annotations, false
+	}
+
+	return append(annotations, attribute.String(SpanAttrComponent, string(component))), true
+}
+
+func (p *annotatingProcessor) OnEnd(s sdktrace.ReadOnlySpan) {}
+
+func (p *annotatingProcessor) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+func (p *annotatingProcessor) ForceFlush(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,82 @@
+package cmotel
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestAnnotatingProcessor(t *testing.T) {
+	t.Setenv(EnvServiceNamePrefix, "cluster.namespace")
+
+	annotator, errAnnotator := NewAnnotatingProcessor(WithAnnotatorServiceName("orders"))
+	if errAnnotator != nil {
+		t.Fatalf("NewAnnotatingProcessor() error = %v", errAnnotator)
+	}
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(annotator), sdktrace.WithSpanProcessor(recorder))
+	tracer := provider.Tracer("third-party")
+	cm := New(provider.Tracer("test"), "orders")
+
+	serverCtx, server := tracer.Start(context.Background(), "GET /orders/{id}", trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(attribute.String("http.route", "/orders/{id}")))
+	handler, handlerCtx := cm.NewSpan(WithSpanName("handler"), WithSpanContext(serverCtx))
+	_, query := tracer.Start(handlerCtx, "SELECT orders", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attribute.String("db.system", "postgresql"), attribute.String("net.peer.name", "orders-db")))
+	query.End()
+	handler.End()
+	server.End()
+
+	tests := []struct {
+		span string
+		want map[attribute.Key]string
+	}{
+		{
+			span: "GET /orders/{id}",
+			want: map[attribute.Key]string{
+				SpanAttrInternalName: "cluster.namespace.orders@GET /orders/{id}",
+				SpanAttrComponent:    `{"name":"GET /orders/{id}","internal_id":"cluster.namespace.orders@GET /orders/{id}","type":"coordimap.asset.http_rest","data":{"http.route":"/orders/{id}"}}`,
+			},
+		},
+		{
+			span: "cluster.namespace.orders@handler",
+			want: map[attribute.Key]string{
+				SpanAttrParentName: "cluster.namespace.orders@GET /orders/{id}",
+			},
+		},
+		{
+			span: "SELECT orders",
+			want: map[attribute.Key]string{
+				SpanAttrParentName:    "cluster.namespace.orders@handler",
+				SpanAttrTargetService: "orders-db",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.span, func(t *testing.T) {
+			var span sdktrace.ReadOnlySpan
+			for _, ended := range recorder.Ended() {
+				if ended.Name() == tt.span {
+					span = ended
+				}
+			}
+			if span == nil {
+				t.Fatalf("span %s not found", tt.span)
+			}
+
+			got := map[attribute.Key]string{}
+			for _, attr := range span.Attributes() {
+				got[attr.Key] = attr.Value.Emit()
+			}
+
+			for key, want := range tt.want {
+				if got[key] != want {
+					t.Errorf("attribute %s = %s, want %s", key, got[key], want)
+				}
+			}
+		})
+	}
+}
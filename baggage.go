@@ -0,0 +1,111 @@
+package cmotel
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+const (
+	// BaggageKeyService the baggage member holding the unique name of the calling service, see GetServiceName
+	BaggageKeyService = "coordimap.service"
+
+	// BaggageKeyK8SCluster the baggage member holding the k8s cluster of the calling service
+	BaggageKeyK8SCluster = "coordimap.k8s.cluster"
+
+	// BaggageKeyK8SNamespace the baggage member holding the k8s namespace of the calling service
+	BaggageKeyK8SNamespace = "coordimap.k8s.namespace"
+
+	// BaggageKeyK8SPod the baggage member holding the k8s pod of the calling service, only sent when it uses WithPodBaggage
+	BaggageKeyK8SPod = "coordimap.k8s.pod"
+)
+
+// ServiceIdentity identifies a service across calls
+type ServiceIdentity struct {
+	Service   string `json:"service"`
+	Cluster   string `json:"cluster,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	Pod       string `json:"pod,omitempty"`
+}
+
+// LocalServiceIdentity returns the identity of the running service based on the unique service prefix and the k8s environment variables
+func LocalServiceIdentity(serviceName string) ServiceIdentity {
+	prefix := os.Getenv(EnvCmPrefix)
+
+	return ServiceIdentity{
+		Service:   GetServiceName(serviceName),
+		Cluster:   GetEnvWithPrefix(prefix, EnvK8SClusterName),
+		Namespace: GetEnvWithPrefix(prefix, EnvK8SNamespaceName),
+		Pod:       GetEnvWithPrefix(prefix, EnvPodNameType),
+	}
+}
+
+// ContextWithServiceIdentity stores the identity in the baggage of the context. Members that already exist are replaced while the rest of the baggage is kept.
+func ContextWithServiceIdentity(ctx context.Context, identity ServiceIdentity) (context.Context, error) {
+	if identity.Service == "" {
+		return ctx, errors.New("the service of the identity must not be empty")
+	}
+
+	bag := baggage.FromContext(ctx)
+
+	for key, value := range map[string]string{
+		BaggageKeyService:      identity.Service,
+		BaggageKeyK8SCluster:   identity.Cluster,
+		BaggageKeyK8SNamespace: identity.Namespace,
+		BaggageKeyK8SPod:       identity.Pod,
+	} {
+		if value == "" {
+			bag = bag.DeleteMember(key)
+			continue
+		}
+
+		member, errMember := baggage.NewMember(key, url.PathEscape(value))
+		if errMember != nil {
+			return ctx, errors.Join(errors.New("could not create the baggage member "+key), errMember)
+		}
+
+		var errSet error
+		bag, errSet = bag.SetMember(member)
+		if errSet != nil {
+			return ctx, errors.Join(errors.New("could not set the baggage member "+key), errSet)
+		}
+	}
+
+	return baggage.ContextWithBaggage(ctx, bag), nil
+}
+
+// ServiceIdentityFromContext returns the identity of the calling service stored in the baggage of the context
+func ServiceIdentityFromContext(ctx context.Context) (ServiceIdentity, bool) {
+	bag := baggage.FromContext(ctx)
+
+	identity := ServiceIdentity{
+		Service:   bag.Member(BaggageKeyService).Value(),
+		Cluster:   bag.Member(BaggageKeyK8SCluster).Value(),
+		Namespace: bag.Member(BaggageKeyK8SNamespace).Value(),
+		Pod:       bag.Member(BaggageKeyK8SPod).Value(),
+	}
+
+	return identity, identity.Service != ""
+}
+
+// InjectServiceBaggage sets the W3C baggage header with the identity, e.g. LocalServiceIdentity, along with the rest of the baggage of the context
+func InjectServiceBaggage(ctx context.Context, identity ServiceIdentity, header http.Header) error {
+	ctx, errIdentity := ContextWithServiceIdentity(ctx, identity)
+	if errIdentity != nil {
+		return errIdentity
+	}
+
+	propagation.Baggage{}.Inject(ctx, propagation.HeaderCarrier(header))
+
+	return nil
+}
+
+// ExtractServiceBaggage reads the W3C baggage header into the context
+func ExtractServiceBaggage(ctx context.Context, header http.Header) context.Context {
+	return propagation.Baggage{}.Extract(ctx, propagation.HeaderCarrier(header))
+}
@@ -0,0 +1,542 @@
+package cmotel
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+
+	"github.com/coordimap/cm-otel-go/internal/semconv"
+)
+
+const (
+	// ComponentTypeCloudAccount a cloud account, e.g. an AWS account, a GCP project or an Azure subscription
+	ComponentTypeCloudAccount = "coordimap.asset.cloud_account"
+
+	// ComponentTypeCloudRegion a region of a cloud account
+	ComponentTypeCloudRegion = "coordimap.asset.cloud_region"
+
+	// CloudResourceSource the name of the cloud detectors in ResourceComponentsKey
+	CloudResourceSource = "cloud"
+
+	// DefaultAWSMetadataEndpoint the EC2 instance metadata service
+	DefaultAWSMetadataEndpoint = "http://169.254.169.254"
+
+	// DefaultGCPMetadataEndpoint the GCP metadata server
+	DefaultGCPMetadataEndpoint = "http://metadata.google.internal"
+
+	// DefaultAzureMetadataEndpoint the Azure instance metadata service
+	DefaultAzureMetadataEndpoint = "http://169.254.169.254"
+
+	// DefaultCloudMetadataTimeout how long the detectors wait for the metadata endpoint, which is not reachable outside of the cloud
+	DefaultCloudMetadataTimeout = 2 * time.Second
+
+	// DefaultCloudMetadataDialTimeout how long the detectors wait for the connection to the metadata endpoint, which is link local and answers quickly when it exists
+	DefaultCloudMetadataDialTimeout = 200 * time.Millisecond
+)
+
+type cloudDetectorOpts struct {
+	endpoint string
+	client   *http.Client
+}
+
+// CloudDetectorOption configures a cloud resource detector
+type CloudDetectorOption func(*cloudDetectorOpts) error
+
+// WithCloudMetadataEndpoint the base URL of the metadata service, e.g. a stub in tests
+func WithCloudMetadataEndpoint(endpoint string) CloudDetectorOption {
+	return func(opts *cloudDetectorOpts) error {
+		parsed, errParse := url.Parse(endpoint)
+		if errParse != nil {
+			return errors.Join(fmt.Errorf("invalid metadata endpoint %s", endpoint), errParse)
+		}
+
+		if parsed.Scheme == "" || parsed.Host == "" {
+			return fmt.Errorf("the metadata endpoint %s must be an absolute URL", endpoint)
+		}
+
+		opts.endpoint = strings.TrimSuffix(endpoint, "/")
+
+		return nil
+	}
+}
+
+// WithCloudHTTPClient the client querying the metadata service. It defaults to a client without proxy timing out after DefaultCloudMetadataTimeout, or DefaultCloudMetadataDialTimeout while connecting.
+func WithCloudHTTPClient(client *http.Client) CloudDetectorOption {
+	return func(opts *cloudDetectorOpts) error {
+		if client == nil {
+			return errors.New("http client must not be nil")
+		}
+
+		opts.client = client
+
+		return nil
+	}
+}
+
+func newCloudDetectorOpts(defaultEndpoint string, opts []CloudDetectorOption) (*cloudDetectorOpts, error) {
+	options := &cloudDetectorOpts{
+		endpoint: defaultEndpoint,
+		client: &http.Client{
+			Transport: &http.Transport{DialContext: (&net.Dialer{Timeout: DefaultCloudMetadataDialTimeout}).DialContext},
+			Timeout:   DefaultCloudMetadataTimeout,
+		},
+	}
+
+	for _, opt := range opts {
+		if err := opt(options); err != nil {
+			return nil, err
+		}
+	}
+
+	return options, nil
+}
+
+// cloudInfo what a detector found about the cloud the service runs on
+type cloudInfo struct {
+	provider   string
+	platform   string
+	account    string
+	region     string
+	zone       string
+	hostID     string
+	attributes []attribute.KeyValue
+}
+
+// resource returns the cloud.* attributes along with the account and the region as Coordimap containers
+func (info cloudInfo) resource() (*resource.Resource, error) {
+	attributes := []attribute.KeyValue{}
+
+	for _, value := range []struct {
+		value     string
+		attribute func(string) attribute.KeyValue
+	}{
+		{value: info.provider, attribute: semconv.CloudProvider},
+		{value: info.platform, attribute: semconv.CloudPlatform},
+		{value: info.account, attribute: semconv.CloudAccountID},
+		{value: info.region, attribute: semconv.CloudRegion},
+		{value: info.zone, attribute: semconv.CloudAvailabilityZone},
+		{value: info.hostID, attribute: semconv.HostID},
+	} {
+		if value.value != "" {
+			attributes = append(attributes, value.attribute(value.value))
+		}
+	}
+
+	attributes = append(attributes, info.attributes...)
+
+	components := []CMComponent{}
+
+	if info.account != "" {
+		components = append(components, CMComponent{
+			Name:        info.account,
+			InternalID:  fmt.Sprintf("%s.%s", info.provider, info.account),
+			Type:        ComponentTypeCloudAccount,
+			Data:        map[string]string{"provider": info.provider},
+			IsContainer: true,
+		})
+	}
+
+	if info.region != "" {
+		components = append(components, CMComponent{
+			Name:        info.region,
+			InternalID:  joinNonEmpty(".", info.provider, info.account, info.region),
+			Type:        ComponentTypeCloudRegion,
+			Data:        map[string]string{"provider": info.provider, "account": info.account},
+			IsContainer: true,
+		})
+	}
+
+	if len(components) > 0 {
+		componentsAttribute, errComponents := ResourceComponents(CloudResourceSource, components...)
+		if errComponents != nil {
+			return nil, errComponents
+		}
+
+		attributes = append(attributes, componentsAttribute)
+	}
+
+	return resource.NewWithAttributes(semconv.SchemaURL, attributes...), nil
+}
+
+// fetchMetadata queries the metadata service. It returns false when the service cannot be reached or does not know the path, i.e. the service does not run on that cloud.
+func fetchMetadata(ctx context.Context, client *http.Client, method, url string, headers map[string]string) ([]byte, bool, error) {
+	req, errReq := http.NewRequestWithContext(ctx, method, url, nil)
+	if errReq != nil {
+		return nil, false, errReq
+	}
+
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, errDo := client.Do(req)
+	if errDo != nil {
+		return nil, false, nil
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, nil
+	}
+
+	body, errRead := io.ReadAll(resp.Body)
+	if errRead != nil {
+		return nil, false, errors.Join(fmt.Errorf("could not read the metadata of %s", url), errRead)
+	}
+
+	return body, true, nil
+}
+
+// parseARN returns the region and the account of an ARN, arn:<partition>:<service>:<region>:<account>:<resource>
+func parseARN(arn string) (string, string) {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) != 6 || parts[0] != "arn" {
+		return "", ""
+	}
+
+	return parts[3], parts[4]
+}
+
+// lastPathSegment returns the part after the last slash, e.g. the region of projects/123/regions/europe-west1
+func lastPathSegment(value string) string {
+	return value[strings.LastIndex(value, "/")+1:]
+}
+
+type awsDetector struct {
+	*cloudDetectorOpts
+}
+
+// NewAWSDetector creates a resource detector for AWS Lambda, read from the environment variables, ECS, read from the task metadata endpoint or file, and EC2, read from the instance metadata service.
+// It returns an empty resource outside of AWS.
+func NewAWSDetector(opts ...CloudDetectorOption) (resource.Detector, error) {
+	options, errOpts := newCloudDetectorOpts(DefaultAWSMetadataEndpoint, opts)
+	if errOpts != nil {
+		return nil, errOpts
+	}
+
+	return &awsDetector{cloudDetectorOpts: options}, nil
+}
+
+type ecsTaskMetadata struct {
+	Cluster                string `json:"Cluster"`
+	TaskARN                string `json:"TaskARN"`
+	Family                 string `json:"Family"`
+	Revision               string `json:"Revision"`
+	TaskDefinitionFamily   string `json:"TaskDefinitionFamily"`
+	TaskDefinitionRevision string `json:"TaskDefinitionRevision"`
+	AvailabilityZone       string `json:"AvailabilityZone"`
+	LaunchType             string `json:"LaunchType"`
+}
+
+type ec2IdentityDocument struct {
+	AccountID        string `json:"accountId"`
+	Region           string `json:"region"`
+	AvailabilityZone string `json:"availabilityZone"`
+	InstanceID       string `json:"instanceId"`
+}
+
+func (d *awsDetector) Detect(ctx context.Context) (*resource.Resource, error) {
+	if function := os.Getenv("AWS_LAMBDA_FUNCTION_NAME"); function != "" {
+		info := cloudInfo{
+			provider: "aws",
+			platform: "aws_lambda",
+			region:   os.Getenv("AWS_REGION"),
+			attributes: []attribute.KeyValue{
+				semconv.FaaSName(function),
+				semconv.FaaSVersion(os.Getenv("AWS_LAMBDA_FUNCTION_VERSION")),
+				semconv.FaaSInstance(os.Getenv("AWS_LAMBDA_LOG_STREAM_NAME")),
+			},
+		}
+
+		return info.resource()
+	}
+
+	task, isECS, errECS := d.ecsTask(ctx)
+	if errECS != nil {
+		return nil, errECS
+	}
+
+	if isECS {
+		region, account := parseARN(task.TaskARN)
+
+		cluster := task.Cluster
+		if cluster != "" && !strings.HasPrefix(cluster, "arn:") {
+			cluster = fmt.Sprintf("arn:aws:ecs:%s:%s:cluster/%s", region, account, cluster)
+		}
+
+		info := cloudInfo{
+			provider:   "aws",
+			platform:   "aws_ecs",
+			account:    account,
+			region:     region,
+			zone:       task.AvailabilityZone,
+			attributes: []attribute.KeyValue{semconv.AWSECSTaskARN(task.TaskARN), semconv.AWSECSClusterARN(cluster)},
+		}
+
+		// the metadata endpoint and the metadata file name the task definition differently
+		if task.Family == "" {
+			task.Family, task.Revision = task.TaskDefinitionFamily, task.TaskDefinitionRevision
+		}
+
+		if task.Family != "" {
+			info.attributes = append(info.attributes, semconv.AWSECSTaskFamily(task.Family), semconv.AWSECSTaskRevision(task.Revision))
+		}
+
+		if task.LaunchType != "" {
+			info.attributes = append(info.attributes, semconv.AWSECSLaunchType(strings.ToLower(task.LaunchType)))
+		}
+
+		return info.resource()
+	}
+
+	// IMDSv2 requires a session token, IMDSv1 is used when it cannot be fetched
+	headers := map[string]string{}
+	token, hasToken, errToken := fetchMetadata(ctx, d.client, http.MethodPut, d.endpoint+"/latest/api/token", map[string]string{"X-aws-ec2-metadata-token-ttl-seconds": "60"})
+	if errToken != nil {
+		return nil, errToken
+	}
+
+	if hasToken {
+		headers["X-aws-ec2-metadata-token"] = string(token)
+	}
+
+	body, isEC2, errEC2 := fetchMetadata(ctx, d.client, http.MethodGet, d.endpoint+"/latest/dynamic/instance-identity/document", headers)
+	if errEC2 != nil {
+		return nil, errEC2
+	}
+
+	if !isEC2 {
+		return resource.Empty(), nil
+	}
+
+	document := ec2IdentityDocument{}
+	if errUnmarshal := json.Unmarshal(body, &document); errUnmarshal != nil {
+		return nil, errors.Join(errors.New("could not decode the EC2 instance identity document"), errUnmarshal)
+	}
+
+	info := cloudInfo{
+		provider:   "aws",
+		platform:   "aws_ec2",
+		account:    document.AccountID,
+		region:     document.Region,
+		zone:       document.AvailabilityZone,
+		hostID:     document.InstanceID,
+		attributes: []attribute.KeyValue{},
+	}
+
+	return info.resource()
+}
+
+// ecsTask reads the task metadata from the v4 endpoint or else from the ECS container metadata file
+func (d *awsDetector) ecsTask(ctx context.Context) (ecsTaskMetadata, bool, error) {
+	task := ecsTaskMetadata{}
+
+	var body []byte
+
+	if endpoint := os.Getenv("ECS_CONTAINER_METADATA_URI_V4"); endpoint != "" {
+		fetched, found, errFetch := fetchMetadata(ctx, d.client, http.MethodGet, endpoint+"/task", nil)
+		if errFetch != nil || !found {
+			return task, false, errors.Join(fmt.Errorf("could not fetch the ECS task metadata from %s", endpoint), errFetch)
+		}
+
+		body = fetched
+	} else if file := os.Getenv("ECS_CONTAINER_METADATA_FILE"); file != "" {
+		contents, errRead := os.ReadFile(file)
+		if errRead != nil {
+			return task, false, errors.Join(errors.New("could not read the ECS container metadata file"), errRead)
+		}
+
+		body = contents
+	} else {
+		return task, false, nil
+	}
+
+	if errUnmarshal := json.Unmarshal(body, &task); errUnmarshal != nil {
+		return task, false, errors.Join(errors.New("could not decode the ECS task metadata"), errUnmarshal)
+	}
+
+	return task, true, nil
+}
+
+type gcpDetector struct {
+	*cloudDetectorOpts
+}
+
+// NewGCPDetector creates a resource detector for Cloud Run services and jobs, read from the environment variables, and Compute Engine. The project, region and instance come from the metadata server.
+// It returns an empty resource outside of GCP.
+func NewGCPDetector(opts ...CloudDetectorOption) (resource.Detector, error) {
+	options, errOpts := newCloudDetectorOpts(DefaultGCPMetadataEndpoint, opts)
+	if errOpts != nil {
+		return nil, errOpts
+	}
+
+	return &gcpDetector{cloudDetectorOpts: options}, nil
+}
+
+// metadata returns the value of the path of the metadata server or an empty string when it is unknown
+func (d *gcpDetector) metadata(ctx context.Context, path string) (string, error) {
+	body, _, errFetch := fetchMetadata(ctx, d.client, http.MethodGet, d.endpoint+"/computeMetadata/v1/"+path, map[string]string{"Metadata-Flavor": "Google"})
+
+	return strings.TrimSpace(string(body)), errFetch
+}
+
+func (d *gcpDetector) Detect(ctx context.Context) (*resource.Resource, error) {
+	project, errProject := d.metadata(ctx, "project/project-id")
+	if errProject != nil {
+		return nil, errProject
+	}
+
+	if project == "" {
+		project = os.Getenv("GOOGLE_CLOUD_PROJECT")
+	}
+
+	service := os.Getenv("K_SERVICE")
+	job := os.Getenv("CLOUD_RUN_JOB")
+
+	if project == "" && service == "" && job == "" {
+		return resource.Empty(), nil
+	}
+
+	instance, errInstance := d.metadata(ctx, "instance/id")
+	if errInstance != nil {
+		return nil, errInstance
+	}
+
+	info := cloudInfo{
+		provider:   "gcp",
+		platform:   "gcp_compute_engine",
+		account:    project,
+		hostID:     instance,
+		attributes: []attribute.KeyValue{},
+	}
+
+	if service == "" && job == "" {
+		zone, errZone := d.metadata(ctx, "instance/zone")
+		if errZone != nil {
+			return nil, errZone
+		}
+
+		// zones are named <region>-<letter>
+		info.zone = lastPathSegment(zone)
+		if index := strings.LastIndex(info.zone, "-"); index != -1 {
+			info.region = info.zone[:index]
+		}
+
+		return info.resource()
+	}
+
+	region, errRegion := d.metadata(ctx, "instance/region")
+	if errRegion != nil {
+		return nil, errRegion
+	}
+
+	info.platform = "gcp_cloud_run"
+	info.region = lastPathSegment(region)
+	info.hostID = ""
+
+	if service != "" {
+		info.attributes = append(info.attributes, semconv.FaaSName(service), semconv.FaaSVersion(os.Getenv("K_REVISION")))
+	} else {
+		info.attributes = append(info.attributes, semconv.FaaSName(job), semconv.GCPCloudRunJobExecution(os.Getenv("CLOUD_RUN_EXECUTION")))
+
+		if index, errIndex := strconv.Atoi(os.Getenv("CLOUD_RUN_TASK_INDEX")); errIndex == nil {
+			info.attributes = append(info.attributes, semconv.GCPCloudRunJobTaskIndex(index))
+		}
+	}
+
+	if instance != "" {
+		info.attributes = append(info.attributes, semconv.FaaSInstance(instance))
+	}
+
+	return info.resource()
+}
+
+type azureDetector struct {
+	*cloudDetectorOpts
+}
+
+// NewAzureDetector creates a resource detector for App Service and Functions, read from the environment variables, and virtual machines, read from the instance metadata service.
+// It returns an empty resource outside of Azure.
+func NewAzureDetector(opts ...CloudDetectorOption) (resource.Detector, error) {
+	options, errOpts := newCloudDetectorOpts(DefaultAzureMetadataEndpoint, opts)
+	if errOpts != nil {
+		return nil, errOpts
+	}
+
+	return &azureDetector{cloudDetectorOpts: options}, nil
+}
+
+type azureComputeMetadata struct {
+	Location       string `json:"location"`
+	SubscriptionID string `json:"subscriptionId"`
+	VMID           string `json:"vmId"`
+	ResourceID     string `json:"resourceId"`
+	Zone           string `json:"zone"`
+}
+
+func (d *azureDetector) Detect(ctx context.Context) (*resource.Resource, error) {
+	if site := os.Getenv("WEBSITE_SITE_NAME"); site != "" {
+		// the owner name is <subscription>+<resource group>-<region>webspace
+		subscription, _, _ := strings.Cut(os.Getenv("WEBSITE_OWNER_NAME"), "+")
+
+		info := cloudInfo{
+			provider:   "azure",
+			platform:   "azure_app_service",
+			account:    subscription,
+			region:     os.Getenv("REGION_NAME"),
+			attributes: []attribute.KeyValue{semconv.FaaSName(site)},
+		}
+
+		if os.Getenv("FUNCTIONS_WORKER_RUNTIME") != "" {
+			info.platform = "azure_functions"
+		}
+
+		if group := os.Getenv("WEBSITE_RESOURCE_GROUP"); group != "" && subscription != "" {
+			info.attributes = append(info.attributes, semconv.CloudResourceID(fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.Web/sites/%s", subscription, group, site)))
+		}
+
+		return info.resource()
+	}
+
+	body, isVM, errVM := fetchMetadata(ctx, d.client, http.MethodGet, d.endpoint+"/metadata/instance/compute?api-version=2021-02-01&format=json", map[string]string{"Metadata": "true"})
+	if errVM != nil {
+		return nil, errVM
+	}
+
+	if !isVM {
+		return resource.Empty(), nil
+	}
+
+	compute := azureComputeMetadata{}
+	if errUnmarshal := json.Unmarshal(body, &compute); errUnmarshal != nil {
+		return nil, errors.Join(errors.New("could not decode the Azure compute metadata"), errUnmarshal)
+	}
+
+	info := cloudInfo{
+		provider:   "azure",
+		platform:   "azure_vm",
+		account:    compute.SubscriptionID,
+		region:     compute.Location,
+		zone:       compute.Zone,
+		hostID:     compute.VMID,
+		attributes: []attribute.KeyValue{},
+	}
+
+	if compute.ResourceID != "" {
+		info.attributes = append(info.attributes, semconv.CloudResourceID(compute.ResourceID))
+	}
+
+	return info.resource()
+}
@@ -0,0 +1,252 @@
+package cmotel
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+var cloudEnvs = []string{
+	"AWS_LAMBDA_FUNCTION_NAME", "AWS_LAMBDA_FUNCTION_VERSION", "AWS_LAMBDA_LOG_STREAM_NAME", "AWS_REGION",
+	"ECS_CONTAINER_METADATA_URI_V4", "ECS_CONTAINER_METADATA_FILE",
+	"GOOGLE_CLOUD_PROJECT", "K_SERVICE", "K_REVISION", "CLOUD_RUN_JOB", "CLOUD_RUN_EXECUTION", "CLOUD_RUN_TASK_INDEX",
+	"WEBSITE_SITE_NAME", "WEBSITE_OWNER_NAME", "WEBSITE_RESOURCE_GROUP", "REGION_NAME", "FUNCTIONS_WORKER_RUNTIME",
+}
+
+// metadataStub serves the bodies by path and checks the header the metadata services require on reads
+func metadataStub(t *testing.T, header string, bodies map[string]string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		body, ok := bodies[r.Method+" "+r.URL.Path]
+		if !ok || (r.Method == http.MethodGet && header != "" && r.Header.Get(header) == "") {
+			http.NotFound(rw, r)
+			return
+		}
+
+		rw.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestCloudDetectors(t *testing.T) {
+	tests := []struct {
+		name           string
+		newDetector    func(...CloudDetectorOption) (resource.Detector, error)
+		header         string
+		bodies         map[string]string
+		env            map[string]string
+		ecsFile        string
+		want           map[attribute.Key]string
+		wantComponents []string
+	}{
+		{
+			name:        "aws ec2",
+			newDetector: NewAWSDetector,
+			header:      "X-aws-ec2-metadata-token",
+			bodies: map[string]string{
+				"PUT /latest/api/token":                          "token",
+				"GET /latest/dynamic/instance-identity/document": `{"accountId": "123456789012", "region": "eu-west-1", "availabilityZone": "eu-west-1a", "instanceId": "i-0abc"}`,
+			},
+			want: map[attribute.Key]string{
+				"cloud.provider":          "aws",
+				"cloud.platform":          "aws_ec2",
+				"cloud.account.id":        "123456789012",
+				"cloud.region":            "eu-west-1",
+				"cloud.availability_zone": "eu-west-1a",
+				"host.id":                 "i-0abc",
+			},
+			wantComponents: []string{"aws.123456789012", "aws.123456789012.eu-west-1"},
+		},
+		{
+			name:        "aws lambda",
+			newDetector: NewAWSDetector,
+			env:         map[string]string{"AWS_LAMBDA_FUNCTION_NAME": "resize", "AWS_LAMBDA_FUNCTION_VERSION": "$LATEST", "AWS_REGION": "us-east-1"},
+			want: map[attribute.Key]string{
+				"cloud.platform": "aws_lambda",
+				"cloud.region":   "us-east-1",
+				"faas.name":      "resize",
+				"faas.version":   "$LATEST",
+			},
+			wantComponents: []string{"aws.us-east-1"},
+		},
+		{
+			name:        "aws ecs metadata file",
+			newDetector: NewAWSDetector,
+			ecsFile:     `{"Cluster": "shop", "TaskARN": "arn:aws:ecs:eu-west-1:123456789012:task/shop/abc", "TaskDefinitionFamily": "orders", "TaskDefinitionRevision": "7", "AvailabilityZone": "eu-west-1b"}`,
+			want: map[attribute.Key]string{
+				"cloud.platform":        "aws_ecs",
+				"cloud.account.id":      "123456789012",
+				"aws.ecs.cluster.arn":   "arn:aws:ecs:eu-west-1:123456789012:cluster/shop",
+				"aws.ecs.task.family":   "orders",
+				"aws.ecs.task.revision": "7",
+			},
+			wantComponents: []string{"aws.123456789012", "aws.123456789012.eu-west-1"},
+		},
+		{
+			name:        "gcp cloud run",
+			newDetector: NewGCPDetector,
+			header:      "Metadata-Flavor",
+			bodies: map[string]string{
+				"GET /computeMetadata/v1/project/project-id": "shop-prod",
+				"GET /computeMetadata/v1/instance/id":        "0087",
+				"GET /computeMetadata/v1/instance/region":    "projects/123/regions/europe-west1",
+			},
+			env: map[string]string{"K_SERVICE": "orders", "K_REVISION": "orders-00042"},
+			want: map[attribute.Key]string{
+				"cloud.provider": "gcp",
+				"cloud.platform": "gcp_cloud_run",
+				"cloud.region":   "europe-west1",
+				"faas.name":      "orders",
+				"faas.version":   "orders-00042",
+				"faas.instance":  "0087",
+				"host.id":        "",
+			},
+			wantComponents: []string{"gcp.shop-prod", "gcp.shop-prod.europe-west1"},
+		},
+		{
+			name:        "gcp compute engine",
+			newDetector: NewGCPDetector,
+			header:      "Metadata-Flavor",
+			bodies: map[string]string{
+				"GET /computeMetadata/v1/project/project-id": "shop-prod",
+				"GET /computeMetadata/v1/instance/id":        "0087",
+				"GET /computeMetadata/v1/instance/zone":      "projects/123/zones/europe-west1-b",
+			},
+			want: map[attribute.Key]string{
+				"cloud.platform":          "gcp_compute_engine",
+				"cloud.region":            "europe-west1",
+				"cloud.availability_zone": "europe-west1-b",
+				"host.id":                 "0087",
+			},
+			wantComponents: []string{"gcp.shop-prod", "gcp.shop-prod.europe-west1"},
+		},
+		{
+			name:        "azure vm",
+			newDetector: NewAzureDetector,
+			header:      "Metadata",
+			bodies: map[string]string{
+				"GET /metadata/instance/compute": `{"location": "westeurope", "subscriptionId": "sub-1", "vmId": "vm-1", "resourceId": "/subscriptions/sub-1/vm"}`,
+			},
+			want: map[attribute.Key]string{
+				"cloud.provider":    "azure",
+				"cloud.platform":    "azure_vm",
+				"cloud.region":      "westeurope",
+				"host.id":           "vm-1",
+				"cloud.resource_id": "/subscriptions/sub-1/vm",
+			},
+			wantComponents: []string{"azure.sub-1", "azure.sub-1.westeurope"},
+		},
+		{
+			name:        "azure functions",
+			newDetector: NewAzureDetector,
+			env: map[string]string{
+				"WEBSITE_SITE_NAME":        "thumbnails",
+				"WEBSITE_OWNER_NAME":       "sub-1+shop-westeuropewebspace",
+				"REGION_NAME":              "West Europe",
+				"FUNCTIONS_WORKER_RUNTIME": "custom",
+			},
+			want: map[attribute.Key]string{
+				"cloud.platform":   "azure_functions",
+				"cloud.account.id": "sub-1",
+				"faas.name":        "thumbnails",
+			},
+			wantComponents: []string{"azure.sub-1", "azure.sub-1.West Europe"},
+		},
+		{
+			name:           "outside of the cloud",
+			newDetector:    NewAzureDetector,
+			want:           map[attribute.Key]string{"cloud.provider": ""},
+			wantComponents: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, env := range cloudEnvs {
+				t.Setenv(env, tt.env[env])
+			}
+
+			if tt.ecsFile != "" {
+				file := filepath.Join(t.TempDir(), "ecs.json")
+				if err := os.WriteFile(file, []byte(tt.ecsFile), 0o600); err != nil {
+					t.Fatal(err)
+				}
+				t.Setenv("ECS_CONTAINER_METADATA_FILE", file)
+			}
+
+			server := metadataStub(t, tt.header, tt.bodies)
+
+			detector, err := tt.newDetector(WithCloudMetadataEndpoint(server.URL))
+			if err != nil {
+				t.Fatalf("new detector error = %v", err)
+			}
+
+			res, err := detector.Detect(context.Background())
+			if err != nil {
+				t.Fatalf("Detect() error = %v", err)
+			}
+
+			for key, want := range tt.want {
+				got, _ := res.Set().Value(key)
+				if got.AsString() != want {
+					t.Errorf("%s = %q, want %q", key, got.AsString(), want)
+				}
+			}
+
+			components := ParseResourceComponents(res.Attributes())
+			if len(components) != len(tt.wantComponents) {
+				t.Fatalf("components = %v, want %v", components, tt.wantComponents)
+			}
+			for i, component := range components {
+				if component.InternalID != tt.wantComponents[i] {
+					t.Errorf("component %d = %v, want %s", i, component.InternalID, tt.wantComponents[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCloudAndK8SContainersNest(t *testing.T) {
+	cloud, err := (cloudInfo{provider: "aws", account: "123", region: "eu-west-1"}).resource()
+	if err != nil {
+		t.Fatalf("resource() error = %v", err)
+	}
+
+	pod, err := ResourceComponents(K8SResourceSource,
+		CMComponent{Name: "orders-0", InternalID: "prod.shop.orders-0", Type: ComponentTypeK8SPod},
+		CMComponent{Name: "node-1", InternalID: "prod.node-1", Type: ComponentTypeK8SNode},
+	)
+	if err != nil {
+		t.Fatalf("ResourceComponents() error = %v", err)
+	}
+
+	merged, err := resource.Merge(resource.NewSchemaless(pod), cloud)
+	if err != nil {
+		t.Fatalf("Merge() error = %v", err)
+	}
+
+	want := []string{"aws.123", "aws.123.eu-west-1", "prod.node-1", "prod.shop.orders-0"}
+	components := ParseResourceComponents(merged.Attributes())
+	if len(components) != len(want) {
+		t.Fatalf("components = %v, want %v", components, want)
+	}
+	for i, component := range components {
+		if component.InternalID != want[i] {
+			t.Errorf("component %d = %v, want %s", i, component.InternalID, want[i])
+		}
+	}
+}
+
+func TestWithCloudMetadataEndpoint(t *testing.T) {
+	for _, endpoint := range []string{"", "169.254.169.254", "://bad"} {
+		if _, err := NewAWSDetector(WithCloudMetadataEndpoint(endpoint)); err == nil {
+			t.Errorf("NewAWSDetector(%q) error = nil, want an error", endpoint)
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	cmotel "github.com/coordimap/cm-otel-go"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// exit codes of the decode commands
+const (
+	exitOK      = 0
+	exitInvalid = 1
+	exitUsage   = 2
+)
+
+// InternalName an internal name split into its parts, see cmotel.SplitInternalName
+type InternalName struct {
+	Name          string `json:"name"`
+	ServicePrefix string `json:"service_prefix"`
+	Service       string `json:"service"`
+	Span          string `json:"span"`
+}
+
+// TraceHeader a decoded trace header
+type TraceHeader struct {
+	Value   string `json:"value"`
+	Format  string `json:"format,omitempty"`
+	TraceID string `json:"trace_id,omitempty"`
+	SpanID  string `json:"span_id,omitempty"`
+	Sampled bool   `json:"sampled"`
+	Valid   bool   `json:"valid"`
+	Error   string `json:"error,omitempty"`
+}
+
+// SpanMapEntry a span of a decoded span map header
+type SpanMapEntry struct {
+	InternalName
+	Header TraceHeader `json:"header"`
+}
+
+// decodeFlags parses the common flags of the decode commands and returns the value to decode
+func decodeFlags(name string, args []string, stdin io.Reader, stderr io.Writer) (string, bool, error) {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	asJSON := flags.Bool("json", false, "print the result as JSON")
+
+	if err := flags.Parse(args); err != nil {
+		return "", false, err
+	}
+
+	if flags.NArg() > 1 {
+		return "", false, fmt.Errorf("%s expects at most one value, got %d", name, flags.NArg())
+	}
+
+	if flags.NArg() == 1 && flags.Arg(0) != "-" {
+		return strings.TrimSpace(flags.Arg(0)), *asJSON, nil
+	}
+
+	value, errRead := io.ReadAll(stdin)
+	if errRead != nil {
+		return "", false, errors.Join(errors.New("could not read the standard input"), errRead)
+	}
+
+	return strings.TrimSpace(string(value)), *asJSON, nil
+}
+
+func writeJSON(w io.Writer, value interface{}) {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(value)
+}
+
+func splitName(name string) InternalName {
+	prefix, service, span := cmotel.SplitInternalName(name)
+
+	return InternalName{
+		Name:          name,
+		ServicePrefix: prefix,
+		Service:       service,
+		Span:          span,
+	}
+}
+
+// decodeTraceHeader validates W3C traceparent values with cmotel.ParseTraceParent and the other formats with cmotel.ParseTraceHeader
+func decodeTraceHeader(value string) TraceHeader {
+	format := cmotel.DetectTraceHeaderFormat(value)
+	header := TraceHeader{Value: value, Format: string(format)}
+
+	parse := cmotel.ParseTraceHeader
+	if format == cmotel.TraceHeaderFormatW3C {
+		parse = cmotel.ParseTraceParent
+	}
+
+	ctx, errParse := parse(value)
+	if errParse != nil {
+		header.Error = errParse.Error()
+		return header
+	}
+
+	spanCtx := trace.SpanContextFromContext(ctx)
+	if !spanCtx.IsValid() {
+		header.Error = "the trace or span ID is all zeros"
+		return header
+	}
+
+	header.TraceID = spanCtx.TraceID().String()
+	header.SpanID = spanCtx.SpanID().String()
+	header.Sampled = spanCtx.IsSampled()
+	header.Valid = true
+
+	return header
+}
+
+func printTraceHeader(w io.Writer, header TraceHeader) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "format:\t%s\n", header.Format)
+
+	if header.Valid {
+		fmt.Fprintf(tw, "trace id:\t%s\n", header.TraceID)
+		fmt.Fprintf(tw, "span id:\t%s\n", header.SpanID)
+		fmt.Fprintf(tw, "sampled:\t%t\n", header.Sampled)
+	} else {
+		fmt.Fprintf(tw, "error:\t%s\n", header.Error)
+	}
+
+	tw.Flush()
+}
+
+func runSpanMap(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	value, asJSON, errFlags := decodeFlags("spanmap", args, stdin, stderr)
+	if errFlags != nil {
+		fmt.Fprintln(stderr, errFlags)
+		return exitUsage
+	}
+
+	spanMap, errUnmarshal := cmotel.UnmarshalToSpanMap(value)
+	if errUnmarshal != nil {
+		fmt.Fprintln(stderr, errUnmarshal)
+		return exitInvalid
+	}
+
+	names := make([]string, 0, len(spanMap))
+	for name := range spanMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	entries := make([]SpanMapEntry, 0, len(names))
+	exitCode := exitOK
+
+	for _, name := range names {
+		entry := SpanMapEntry{InternalName: splitName(name), Header: decodeTraceHeader(spanMap[name])}
+		if !entry.Header.Valid || entry.Span == "" {
+			exitCode = exitInvalid
+		}
+
+		entries = append(entries, entry)
+	}
+
+	if asJSON {
+		writeJSON(stdout, entries)
+		return exitCode
+	}
+
+	tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SERVICE PREFIX\tSERVICE\tSPAN\tFORMAT\tTRACE ID\tSPAN ID\tSAMPLED\tERROR")
+
+	for _, entry := range entries {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%t\t%s\n", entry.ServicePrefix, entry.Service, entry.Span, entry.Header.Format, entry.Header.TraceID, entry.Header.SpanID, entry.Header.Sampled, entry.Header.Error)
+	}
+
+	tw.Flush()
+
+	return exitCode
+}
+
+func runTraceParent(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	value, asJSON, errFlags := decodeFlags("traceparent", args, stdin, stderr)
+	if errFlags != nil {
+		fmt.Fprintln(stderr, errFlags)
+		return exitUsage
+	}
+
+	header := decodeTraceHeader(value)
+
+	if asJSON {
+		writeJSON(stdout, header)
+	} else {
+		printTraceHeader(stdout, header)
+	}
+
+	if !header.Valid {
+		return exitInvalid
+	}
+
+	return exitOK
+}
+
+func runName(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	value, asJSON, errFlags := decodeFlags("name", args, stdin, stderr)
+	if errFlags != nil {
+		fmt.Fprintln(stderr, errFlags)
+		return exitUsage
+	}
+
+	name := splitName(value)
+
+	if asJSON {
+		writeJSON(stdout, name)
+	} else {
+		tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintf(tw, "service prefix:\t%s\n", name.ServicePrefix)
+		fmt.Fprintf(tw, "service:\t%s\n", name.Service)
+		fmt.Fprintf(tw, "span:\t%s\n", name.Span)
+		tw.Flush()
+	}
+
+	if name.Span == "" {
+		fmt.Fprintf(stderr, "%s is not an internal name, expected <service prefix>.<service>@<span>\n", value)
+		return exitInvalid
+	}
+
+	return exitOK
+}
+
+func runComponent(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	value, asJSON, errFlags := decodeFlags("component", args, stdin, stderr)
+	if errFlags != nil {
+		fmt.Fprintln(stderr, errFlags)
+		return exitUsage
+	}
+
+	component, errComponent := cmotel.ParseComponent(value)
+	if errComponent != nil {
+		fmt.Fprintln(stderr, errComponent)
+		return exitInvalid
+	}
+
+	if asJSON {
+		writeJSON(stdout, component)
+		return exitOK
+	}
+
+	name := splitName(component.InternalID)
+
+	tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "name:\t%s\n", component.Name)
+	fmt.Fprintf(tw, "internal id:\t%s\n", component.InternalID)
+	fmt.Fprintf(tw, "service prefix:\t%s\n", name.ServicePrefix)
+	fmt.Fprintf(tw, "service:\t%s\n", name.Service)
+	fmt.Fprintf(tw, "type:\t%s\n", component.Type)
+	fmt.Fprintf(tw, "container:\t%t\n", component.IsContainer)
+
+	keys := make([]string, 0, len(component.Data))
+	for key := range component.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		fmt.Fprintf(tw, "data.%s:\t%s\n", key, component.Data[key])
+	}
+
+	tw.Flush()
+
+	return exitOK
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name         string
+		args         []string
+		stdin        string
+		wantCode     int
+		wantContains string
+	}{
+		{
+			name:         "span map",
+			args:         []string{"spanmap", `{"cluster.namespace.orders@handler":"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}`},
+			wantCode:     exitOK,
+			wantContains: "cluster.namespace  orders   handler  w3c",
+		},
+		{
+			name:         "span map with an invalid entry",
+			args:         []string{"spanmap", "-json"},
+			stdin:        `{"cluster.namespace.orders@handler":"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01"}`,
+			wantCode:     exitInvalid,
+			wantContains: `"valid": false`,
+		},
+		{
+			name:         "b3 trace header",
+			args:         []string{"traceparent", "4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-1"},
+			wantCode:     exitOK,
+			wantContains: "format:    b3",
+		},
+		{
+			name:         "invalid traceparent",
+			args:         []string{"traceparent", "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+			wantCode:     exitInvalid,
+			wantContains: "error:",
+		},
+		{
+			name:         "internal name",
+			args:         []string{"name", "-json", "cluster.namespace.orders@handler"},
+			wantCode:     exitOK,
+			wantContains: `"service_prefix": "cluster.namespace"`,
+		},
+		{
+			name:         "component",
+			args:         []string{"component", `{"name":"handler","internal_id":"cluster.namespace.orders@handler","type":"coordimap.asset.http_rest","data":{"http.method":"GET"}}`},
+			wantCode:     exitOK,
+			wantContains: "data.http.method:  GET",
+		},
+		{
+			name:     "component without internal id",
+			args:     []string{"component", `{"name":"handler"}`},
+			wantCode: exitInvalid,
+		},
+		{
+			name:     "unknown command",
+			args:     []string{"decode"},
+			wantCode: exitUsage,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout := &bytes.Buffer{}
+			stderr := &bytes.Buffer{}
+
+			if got := run(tt.args, strings.NewReader(tt.stdin), stdout, stderr); got != tt.wantCode {
+				t.Errorf("run() = %d, want %d, stderr %s", got, tt.wantCode, stderr)
+			}
+
+			if !strings.Contains(stdout.String(), tt.wantContains) {
+				t.Errorf("run() stdout = %s, want it to contain %s", stdout, tt.wantContains)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+// Command cmotel decodes and validates the Coordimap headers and span attributes found in logs.
+//
+// Usage:
+//
+//	cmotel <command> [-json] [value]
+//	cmotel topology [-format dot|mermaid|json] [file ...]
+//
+// The value, or the trace dumps, are read from the standard input when omitted.
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+const usage = `usage: cmotel <command> [-json] [value]
+       cmotel topology [-format dot|mermaid|json] [file ...]
+
+commands:
+  spanmap      decode an x-COORDIMAP-SPANS header and validate every trace header it holds
+  traceparent  validate a traceparent header
+  name         split an internal name into its service prefix, service and span name
+  component    decode a coordimap.span_attr.component attribute
+  topology     build the topology of OTLP/JSON or stdout exporter trace dumps
+`
+
+// command runs a subcommand with its arguments and returns the exit code
+type command = func(args []string, stdin io.Reader, stdout, stderr io.Writer) int
+
+func commands() map[string]command {
+	return map[string]command{
+		"spanmap":     runSpanMap,
+		"traceparent": runTraceParent,
+		"name":        runName,
+		"component":   runComponent,
+		"topology":    runTopology,
+	}
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+
+	if args[0] == "help" || args[0] == "-h" || args[0] == "-help" || args[0] == "--help" {
+		fmt.Fprint(stdout, usage)
+		return 0
+	}
+
+	cmd, ok := commands()[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown command %s\n\n%s", args[0], usage)
+		return 2
+	}
+
+	return cmd(args[1:], stdin, stdout, stderr)
+}
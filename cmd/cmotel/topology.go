@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/coordimap/cm-otel-go/topology"
+	"github.com/coordimap/cm-otel-go/topology/render"
+)
+
+// runTopology builds the topology of OTLP/JSON or stdout exporter trace dumps and prints it
+func runTopology(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("topology", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	format := flags.String("format", "dot", "the output format, one of dot, mermaid or json")
+	noServiceGroups := flags.Bool("no-service-groups", false, "do not group the nodes by service prefix")
+	noContainerGroups := flags.Bool("no-container-groups", false, "do not group the nodes by container component")
+
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: cmotel topology [-format dot|mermaid|json] [-no-service-groups] [-no-container-groups] [file ...]")
+		fmt.Fprintln(stderr, "\nThe trace dumps are read from the standard input when no file is given.")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	var renderGraph func(w io.Writer, graph *topology.Graph, opts ...render.Option) error
+
+	switch *format {
+	case "dot":
+		renderGraph = render.DOT
+	case "mermaid":
+		renderGraph = render.Mermaid
+	case "json":
+		renderGraph = render.JSON
+	default:
+		fmt.Fprintf(stderr, "unknown format %s, expected dot, mermaid or json\n", *format)
+		return exitUsage
+	}
+
+	renderOpts := []render.Option{}
+	if *noServiceGroups {
+		renderOpts = append(renderOpts, render.WithoutServiceGroups())
+	}
+
+	if *noContainerGroups {
+		renderOpts = append(renderOpts, render.WithoutContainerGroups())
+	}
+
+	graph := topology.NewGraph()
+
+	if flags.NArg() == 0 {
+		if errRead := addDump(graph, stdin, "the standard input"); errRead != nil {
+			fmt.Fprintln(stderr, errRead)
+			return exitInvalid
+		}
+	}
+
+	for _, path := range flags.Args() {
+		if errRead := addDumpFile(graph, path); errRead != nil {
+			fmt.Fprintln(stderr, errRead)
+			return exitInvalid
+		}
+	}
+
+	if errRender := renderGraph(stdout, graph, renderOpts...); errRender != nil {
+		fmt.Fprintln(stderr, errRender)
+		return exitInvalid
+	}
+
+	return exitOK
+}
+
+func addDumpFile(graph *topology.Graph, path string) error {
+	file, errOpen := os.Open(path)
+	if errOpen != nil {
+		return errors.Join(fmt.Errorf("could not open %s", path), errOpen)
+	}
+	defer file.Close()
+
+	return addDump(graph, file, path)
+}
+
+func addDump(graph *topology.Graph, r io.Reader, source string) error {
+	spans, errRead := topology.ReadDump(r)
+	if errRead != nil {
+		return errors.Join(fmt.Errorf("could not read the spans of %s", source), errRead)
+	}
+
+	for _, span := range spans {
+		graph.Add(span)
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunTopology(t *testing.T) {
+	dump := filepath.Join(t.TempDir(), "traces.json")
+	otlp := `{"resourceSpans":[{"scopeSpans":[{"spans":[{"traceId":"4bf92f3577b34da6a3ce929d0e0e4736","spanId":"00f067aa0ba902b7","name":"cluster.namespace.orders@query","attributes":[{"key":"coordimap.span_attr.parent_name","value":{"stringValue":"cluster.namespace.orders@handler"}}]}]}]}]}`
+	if err := os.WriteFile(dump, []byte(otlp+"\n"), 0o600); err != nil {
+		t.Fatalf("os.WriteFile() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		args     []string
+		stdin    string
+		wantCode int
+		want     string
+	}{
+		{
+			name:     "mermaid from a file",
+			args:     []string{"topology", "-format", "mermaid", "-no-service-groups", dump},
+			wantCode: exitOK,
+			want:     "flowchart LR\n  n0[\"handler\"]\n  n1[\"query\"]\n  n0 -->|parent| n1\n",
+		},
+		{
+			name:     "dot from the standard input",
+			args:     []string{"topology"},
+			stdin:    otlp,
+			wantCode: exitOK,
+			want:     `"cluster.namespace.orders@handler" -> "cluster.namespace.orders@query" [label="parent"];`,
+		},
+		{
+			name:     "invalid dump",
+			args:     []string{"topology"},
+			stdin:    "not json",
+			wantCode: exitInvalid,
+		},
+		{
+			name:     "unknown format",
+			args:     []string{"topology", "-format", "svg"},
+			wantCode: exitUsage,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout := &bytes.Buffer{}
+			stderr := &bytes.Buffer{}
+
+			if got := run(tt.args, strings.NewReader(tt.stdin), stdout, stderr); got != tt.wantCode {
+				t.Fatalf("run() = %d, want %d, stderr %s", got, tt.wantCode, stderr)
+			}
+
+			if !strings.Contains(stdout.String(), tt.want) {
+				t.Errorf("run() stdout = %s, want it to contain %s", stdout, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+// Package cmoteltest helps testing Coordimap instrumentation by recording the spans in memory and asserting the topology they carry.
+package cmoteltest
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	cmotel "github.com/coordimap/cm-otel-go"
+	"github.com/coordimap/cm-otel-go/topology"
+	"github.com/coordimap/cm-otel-go/topology/render"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// EnvUpdateGolden rewrites the golden files instead of comparing them when set to any value
+const EnvUpdateGolden = "CMOTELTEST_UPDATE"
+
+// Harness a CMOtel object backed by an in-memory span recorder. Only the ended spans are taken into account by the assertions.
+type Harness struct {
+	cmotel.ExtendedCMOtel
+
+	// Recorder the recorder of all the spans created through the harness
+	Recorder *tracetest.SpanRecorder
+
+	// Provider the tracer provider of the harness, shut down when the test ends
+	Provider *sdktrace.TracerProvider
+
+	serviceName string
+}
+
+// New creates a harness for the service. The SERVICE_NAME_PREFIX environment variable, if any, is honoured so t.Setenv can be used to get stable internal names.
+func New(t testing.TB, serviceName string, opts ...cmotel.Option) *Harness {
+	t.Helper()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	t.Cleanup(func() {
+		provider.Shutdown(context.Background())
+	})
+
+	return &Harness{
+		ExtendedCMOtel: cmotel.New(provider.Tracer("cmoteltest"), serviceName, opts...),
+		Recorder:       recorder,
+		Provider:       provider,
+		serviceName:    serviceName,
+	}
+}
+
+// InternalName returns the internal name of a span of the harness. Names that are already internal, i.e. contain @, are returned as is.
+func (h *Harness) InternalName(name string) string {
+	if strings.Contains(name, "@") {
+		return name
+	}
+
+	return cmotel.GetServiceName(h.serviceName) + "@" + name
+}
+
+// Graph returns the topology carried by the ended spans
+func (h *Harness) Graph() *topology.Graph {
+	graph := topology.NewGraph()
+
+	for _, span := range h.Recorder.Ended() {
+		graph.Add(topology.FromReadOnlySpan(span))
+	}
+
+	return graph
+}
+
+// AssertComponent checks that the span was registered as a component of the type
+func (h *Harness) AssertComponent(t testing.TB, name, componentType string) {
+	t.Helper()
+
+	node, ok := h.Graph().Node(h.InternalName(name))
+	if !ok || node.Type == "" {
+		t.Errorf("component %s not found", h.InternalName(name))
+		return
+	}
+
+	if node.Type != componentType {
+		t.Errorf("component %s has type %s, want %s", node.ID, node.Type, componentType)
+	}
+}
+
+// AssertRelationship checks that a relationship was drawn from one span to another
+func (h *Harness) AssertRelationship(t testing.TB, from, to string) {
+	t.Helper()
+	h.assertEdge(t, h.InternalName(from), h.InternalName(to), topology.EdgeKindRelationship)
+}
+
+// AssertParent checks that the child span was created with the parent span name
+func (h *Harness) AssertParent(t testing.TB, child, parent string) {
+	t.Helper()
+	h.assertEdge(t, h.InternalName(parent), h.InternalName(child), topology.EdgeKindParent)
+}
+
+// AssertTargetService checks that the span recorded a call to the target service, see cmotel.SpanAttrTargetService
+func (h *Harness) AssertTargetService(t testing.TB, name, service string) {
+	t.Helper()
+	h.assertEdge(t, h.InternalName(name), service, topology.EdgeKindTarget)
+}
+
+func (h *Harness) assertEdge(t testing.TB, from, to string, kind topology.EdgeKind) {
+	t.Helper()
+
+	graph := h.Graph()
+	if _, ok := graph.Edge(from, to, kind); ok {
+		return
+	}
+
+	found := []string{}
+	for _, edge := range graph.EdgesFrom(from) {
+		found = append(found, string(edge.Kind)+" to "+edge.To)
+	}
+
+	t.Errorf("%s edge from %s to %s not found, the edges from %s are %v", kind, from, to, from, found)
+}
+
+// AssertGolden compares the topology, rendered with render.JSON, to the golden file. The file is written instead when the CMOTELTEST_UPDATE environment variable is set.
+func (h *Harness) AssertGolden(t testing.TB, path string, opts ...render.Option) {
+	t.Helper()
+
+	got := &bytes.Buffer{}
+	if err := render.JSON(got, h.Graph(), opts...); err != nil {
+		t.Fatalf("could not render the topology: %v", err)
+	}
+
+	if _, update := os.LookupEnv(EnvUpdateGolden); update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("could not create the directory of the golden file: %v", err)
+		}
+
+		if err := os.WriteFile(path, got.Bytes(), 0o644); err != nil {
+			t.Fatalf("could not write the golden file: %v", err)
+		}
+
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("could not read the golden file, run the test with %s=1 to create it: %v", EnvUpdateGolden, err)
+	}
+
+	if !bytes.Equal(got.Bytes(), want) {
+		t.Errorf("the topology does not match %s, run the test with %s=1 to update it\ngot:\n%s\nwant:\n%s", path, EnvUpdateGolden, got, want)
+	}
+}
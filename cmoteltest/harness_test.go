@@ -0,0 +1,72 @@
+package cmoteltest
+
+import (
+	"path/filepath"
+	"testing"
+
+	cmotel "github.com/coordimap/cm-otel-go"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestHarness(t *testing.T) {
+	t.Setenv(cmotel.EnvServiceNamePrefix, "cluster.namespace")
+
+	h := New(t, "orders")
+
+	h.NewSpan(cmotel.WithSpanName("handler"))
+	if err := h.AddComponent(cmotel.WithAddComponentSpanName("handler"), cmotel.WithAddComponentType(cmotel.ComponentTypeHTTPRestGeneric)); err != nil {
+		t.Fatalf("AddComponent() error = %v", err)
+	}
+
+	query, _ := h.NewSpan(cmotel.WithSpanName("query"), cmotel.WithParentSpanName("handler"))
+	query.SetAttributes(attribute.String(cmotel.SpanAttrTargetService, "postgres"))
+	h.NewSpan(cmotel.WithSpanName("publish"), cmotel.WithSpanInternalRelationshipFrom("query"))
+
+	for _, name := range []string{"publish", "query", "handler"} {
+		h.EndSpan(name)
+	}
+
+	h.AssertComponent(t, "handler", cmotel.ComponentTypeHTTPRestGeneric)
+	h.AssertParent(t, "query", "handler")
+	h.AssertRelationship(t, "query", "publish")
+	h.AssertTargetService(t, "query", "postgres")
+	h.AssertGolden(t, filepath.Join("testdata", "topology.golden.json"))
+
+	tests := []struct {
+		name   string
+		assert func(t testing.TB)
+	}{
+		{
+			name:   "wrong component type",
+			assert: func(t testing.TB) { h.AssertComponent(t, "handler", cmotel.ComponentTypeGenericContainer) },
+		},
+		{
+			name:   "missing relationship",
+			assert: func(t testing.TB) { h.AssertRelationship(t, "publish", "query") },
+		},
+		{
+			name:   "missing parent",
+			assert: func(t testing.TB) { h.AssertParent(t, "handler", "query") },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := &failureRecorder{TB: t}
+			tt.assert(recorder)
+
+			if !recorder.failed {
+				t.Errorf("the assertion did not fail")
+			}
+		})
+	}
+}
+
+// failureRecorder records failures instead of failing the test
+type failureRecorder struct {
+	testing.TB
+	failed bool
+}
+
+func (r *failureRecorder) Errorf(format string, args ...interface{}) {
+	r.failed = true
+}
@@ -0,0 +1,186 @@
+package cmotel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// CMSpan a handle to a span of a CMOtel object. It gives direct access to the span instead of looking it up by name.
+type CMSpan interface {
+	// Name the name the span is registered under, which can be used with the name based API of CMOtel
+	Name() string
+
+	// InternalName the name of the span prefixed by the unique service name
+	InternalName() string
+
+	// Span the underlying otel span
+	Span() trace.Span
+
+	// Context the context holding the span, to be used for child spans created by other instrumentation
+	Context() context.Context
+
+	// Traceparent the W3C traceparent header of the span
+	Traceparent() string
+
+	// End ends the span
+	End(opts ...trace.SpanEndOption) error
+
+	// AddComponent registers the span as a component, see CMOtel.AddComponent
+	AddComponent(opts ...addComponentOptionType) error
+
+	// RelateFrom records a relationship from the other span to this one. The span must not have ended.
+	RelateFrom(from CMSpan) error
+
+	// RelateTo records a relationship from this span to the other one. The span must not have ended.
+	RelateTo(to CMSpan) error
+
+	// RelateFromService records a relationship from the unique name of another service, see GetServiceName, to this span. The span must not have ended.
+	RelateFromService(service string) error
+
+	// SetTargetService records a call or connection to another service, see SpanAttrTargetService
+	SetTargetService(service string)
+}
+
+type cmSpanHandle struct {
+	cm            *cmOtel
+	name          string
+	span          trace.Span
+	ctx           context.Context
+	relationships *[]string
+}
+
+// NewCMSpan creates a span like NewSpan and returns a handle to it. It fails when the span cannot be created, e.g. because the parent does not exist.
+func (cm *cmOtel) NewCMSpan(opts ...SpanOption) (CMSpan, error) {
+	name, span, ctx, errSpan := cm.newSpan(opts...)
+	if errSpan != nil {
+		return nil, errors.Join(errors.New("could not create the span"), errSpan)
+	}
+
+	return &cmSpanHandle{
+		cm:            cm,
+		name:          name,
+		span:          span,
+		ctx:           ctx,
+		relationships: cm.spans[name].relationships,
+	}, nil
+}
+
+// Span returns a handle to an existing span, local or remote. Under the DuplicateSpanNameStack policy a span name refers to its most recent instance that has not ended.
+func (cm *cmOtel) Span(name string) (CMSpan, error) {
+	name = cm.resolveInstance(name)
+
+	span, ok := cm.spans[name]
+	if !ok {
+		return nil, fmt.Errorf("span %s does not exist", name)
+	}
+
+	return &cmSpanHandle{
+		cm:            cm,
+		name:          name,
+		span:          span.span,
+		ctx:           span.ctx,
+		relationships: span.relationships,
+	}, nil
+}
+
+func (h *cmSpanHandle) Name() string {
+	return h.name
+}
+
+func (h *cmSpanHandle) InternalName() string {
+	return h.cm.generateInternalName(h.name)
+}
+
+func (h *cmSpanHandle) Span() trace.Span {
+	return h.span
+}
+
+func (h *cmSpanHandle) Context() context.Context {
+	return h.ctx
+}
+
+func (h *cmSpanHandle) Traceparent() string {
+	spanCtx := h.span.SpanContext()
+
+	return fmt.Sprintf("00-%s-%s-%s", spanCtx.TraceID().String(), spanCtx.SpanID().String(), spanCtx.TraceFlags().String())
+}
+
+func (h *cmSpanHandle) End(opts ...trace.SpanEndOption) error {
+	// the name refers to a newer span when it was reused, e.g. under the DuplicateSpanNameReplace policy, whose registry entry must be left alone
+	if !h.current() {
+		h.span.End(opts...)
+
+		return nil
+	}
+
+	return h.cm.EndSpan(h.name, opts...)
+}
+
+// current whether the name of the handle still refers to its span
+func (h *cmSpanHandle) current() bool {
+	current, ok := h.cm.spans[h.name]
+
+	// remote spans are not comparable so their span contexts are compared instead
+	return ok && current.span.SpanContext().Equal(h.span.SpanContext())
+}
+
+func (h *cmSpanHandle) AddComponent(opts ...addComponentOptionType) error {
+	return h.cm.AddComponent(append(opts, WithAddComponentSpan(h.span))...)
+}
+
+func (h *cmSpanHandle) RelateFrom(from CMSpan) error {
+	if from == nil {
+		return errors.New("the span to relate from must not be nil")
+	}
+
+	unverified := false
+	if fromHandle, ok := from.(*cmSpanHandle); ok && fromHandle.current() {
+		unverified = fromHandle.cm.spans[fromHandle.name].unverified
+	}
+
+	return h.relate(FormatRelationship(from.InternalName(), h.InternalName()), unverified)
+}
+
+func (h *cmSpanHandle) RelateTo(to CMSpan) error {
+	if to == nil {
+		return errors.New("the span to relate to must not be nil")
+	}
+
+	return h.relate(FormatRelationship(h.InternalName(), to.InternalName()), false)
+}
+
+func (h *cmSpanHandle) RelateFromService(service string) error {
+	if service == "" {
+		return errors.New("the service to relate from must not be empty")
+	}
+
+	return h.relate(FormatRelationship(service, h.InternalName()), false)
+}
+
+// relate records the relationship on the span itself since links can only be added when a span starts. An unverified relationship, drawn from a span of an unverified span map, tags the span with SpanAttrUnverified.
+func (h *cmSpanHandle) relate(relationship string, unverified bool) error {
+	if !h.span.IsRecording() {
+		return fmt.Errorf("span %s is not recording, either it ended, it was not sampled or it is remote", h.name)
+	}
+
+	*h.relationships = append(*h.relationships, relationship)
+
+	h.span.SetAttributes(attribute.StringSlice(SpanAttrRelationships, *h.relationships))
+	if unverified {
+		h.span.SetAttributes(attribute.Bool(SpanAttrUnverified, true))
+	}
+
+	h.cm.registry.updateSpan(h.span.SpanContext().SpanID(), func(info *SpanInfo) {
+		info.Relationships = append(info.Relationships, relationship)
+	})
+
+	return nil
+}
+
+func (h *cmSpanHandle) SetTargetService(service string) {
+	h.span.SetAttributes(attribute.String(SpanAttrTargetService, service))
+}
@@ -0,0 +1,212 @@
+package cmotel
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestCMSpan(t *testing.T) {
+	t.Setenv(EnvServiceNamePrefix, "cluster.namespace")
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	cm := New(provider.Tracer("test"), "orders")
+
+	if _, err := cm.NewCMSpan(WithSpanName("query"), WithParentSpanName("handler")); err == nil {
+		t.Errorf("NewCMSpan() with an unknown parent error = nil, want an error")
+	}
+
+	handler, errHandler := cm.NewCMSpan(WithSpanName("handler"))
+	if errHandler != nil {
+		t.Fatalf("NewCMSpan() error = %v", errHandler)
+	}
+
+	query, errQuery := cm.NewCMSpan(WithSpanName("query"), WithParentSpanName(handler.Name()))
+	if errQuery != nil {
+		t.Fatalf("NewCMSpan() error = %v", errQuery)
+	}
+
+	if err := query.AddComponent(WithAddComponentType(ComponentTypeHTTPRestGeneric)); err != nil {
+		t.Errorf("AddComponent() error = %v", err)
+	}
+	if err := query.RelateFrom(handler); err != nil {
+		t.Errorf("RelateFrom() error = %v", err)
+	}
+	if err := query.RelateTo(handler); err != nil {
+		t.Errorf("RelateTo() error = %v", err)
+	}
+	query.SetTargetService("postgres")
+
+	byName, errByName := cm.Span("query")
+	if errByName != nil || byName.Traceparent() != query.Traceparent() || byName.Context() != query.Context() {
+		t.Errorf("Span(query) = %v, %v, want the handle of the query span", byName, errByName)
+	}
+
+	if err := query.End(); err != nil {
+		t.Fatalf("End() error = %v", err)
+	}
+	if err := query.RelateTo(handler); err == nil {
+		t.Errorf("RelateTo() after End() error = nil, want an error")
+	}
+
+	ended := recorder.Ended()
+	if len(ended) != 1 {
+		t.Fatalf("got %d ended spans, want 1", len(ended))
+	}
+
+	want := map[attribute.Key]string{
+		SpanAttrParentName:    "cluster.namespace.orders@handler",
+		SpanAttrRelationships: "[cluster.namespace.orders@handler@@@cluster.namespace.orders@query cluster.namespace.orders@query@@@cluster.namespace.orders@handler]",
+		SpanAttrTargetService: "postgres",
+	}
+	for _, attr := range ended[0].Attributes() {
+		if value, ok := want[attr.Key]; ok {
+			if got := attr.Value.Emit(); got != value {
+				t.Errorf("attribute %s = %s, want %s", attr.Key, got, value)
+			}
+			delete(want, attr.Key)
+		}
+	}
+	if len(want) != 0 {
+		t.Errorf("missing attributes %v", want)
+	}
+}
+
+func TestCMSpanReusedName(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	cm := New(provider.Tracer("test"), "orders")
+
+	first, errFirst := cm.NewCMSpan(WithSpanName("query"))
+	if errFirst != nil {
+		t.Fatalf("NewCMSpan() error = %v", errFirst)
+	}
+
+	second, errSecond := cm.NewCMSpan(WithSpanName("query"))
+	if errSecond != nil {
+		t.Fatalf("NewCMSpan() error = %v", errSecond)
+	}
+
+	if err := first.End(); err != nil {
+		t.Fatalf("End() error = %v", err)
+	}
+
+	if first.Span().IsRecording() || !second.Span().IsRecording() {
+		t.Errorf("first recording = %v and second recording = %v, want only the second span recording", first.Span().IsRecording(), second.Span().IsRecording())
+	}
+
+	if err := cm.EndSpan("query"); err != nil || second.Span().IsRecording() {
+		t.Errorf("EndSpan(query) error = %v, want the second span ended", err)
+	}
+}
+
+func TestCMSpanRelationshipsNotCarriedOver(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	cm := New(provider.Tracer("test"), "orders")
+
+	handler, _ := cm.NewCMSpan(WithSpanName("handler"))
+
+	for i := 0; i < 2; i++ {
+		query, errQuery := cm.NewCMSpan(WithSpanName("query"))
+		if errQuery != nil {
+			t.Fatalf("NewCMSpan() error = %v", errQuery)
+		}
+
+		if err := query.RelateFrom(handler); err != nil {
+			t.Fatalf("RelateFrom() error = %v", err)
+		}
+
+		if err := query.End(); err != nil {
+			t.Fatalf("End() error = %v", err)
+		}
+	}
+
+	for _, span := range recorder.Ended() {
+		for _, attr := range span.Attributes() {
+			if attr.Key == SpanAttrRelationships && len(attr.Value.AsStringSlice()) != 1 {
+				t.Errorf("relationships = %v, want only the relationship of the span itself", attr.Value.AsStringSlice())
+			}
+		}
+	}
+}
+
+func TestCMSpanRelateFromReusedName(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	cm := New(provider.Tracer("test"), "orders")
+
+	handler, _ := cm.NewCMSpan(WithSpanName("handler"))
+	old, _ := cm.NewCMSpan(WithSpanName("query"))
+	newer, _ := cm.NewCMSpan(WithSpanName("query"))
+
+	if err := newer.RelateTo(handler); err != nil {
+		t.Fatalf("RelateTo() error = %v", err)
+	}
+	if err := old.RelateFrom(handler); err != nil {
+		t.Fatalf("RelateFrom() error = %v", err)
+	}
+
+	old.End()
+	newer.End()
+
+	for i, span := range recorder.Ended() {
+		for _, attr := range span.Attributes() {
+			if attr.Key == SpanAttrRelationships && len(attr.Value.AsStringSlice()) != 1 {
+				t.Errorf("relationships of span %d = %v, want only the relationship of the span itself", i, attr.Value.AsStringSlice())
+			}
+		}
+	}
+
+	for _, info := range cm.Spans() {
+		if info.Name == "query" && (len(info.Relationships) != 1 || info.Relationships[0] != FormatRelationship(newer.InternalName(), handler.InternalName())) {
+			t.Errorf("relationships of the newer span = %v, want only its own", info.Relationships)
+		}
+	}
+}
+
+func TestCMSpanRelateFromUnverified(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	cm := New(provider.Tracer("test"), "orders")
+
+	remoteCtx := trace.ContextWithRemoteSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x01},
+		SpanID:     trace.SpanID{0x01},
+		TraceFlags: trace.FlagsSampled,
+		Remote:     true,
+	}))
+	if err := cm.AddRemoteSpanCtx(remoteCtx, "cluster.namespace.payments@charge"); err != nil {
+		t.Fatalf("AddRemoteSpanCtx() error = %v", err)
+	}
+	if err := cm.MarkSpanUnverified("cluster.namespace.payments@charge"); err != nil {
+		t.Fatalf("MarkSpanUnverified() error = %v", err)
+	}
+
+	remote, errRemote := cm.Span("cluster.namespace.payments@charge")
+	if errRemote != nil {
+		t.Fatalf("Span() error = %v", errRemote)
+	}
+
+	query, _ := cm.NewCMSpan(WithSpanName("query"))
+	if err := query.RelateFrom(remote); err != nil {
+		t.Fatalf("RelateFrom() error = %v", err)
+	}
+	query.End()
+
+	unverified := false
+	for _, attr := range recorder.Ended()[0].Attributes() {
+		if attr.Key == SpanAttrUnverified {
+			unverified = attr.Value.AsBool()
+		}
+	}
+
+	if !unverified {
+		t.Errorf("the span related from an unverified span is not tagged with %s", SpanAttrUnverified)
+	}
+}
@@ -0,0 +1,238 @@
+package cmotel
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"gopkg.in/yaml.v3"
+
+	"github.com/coordimap/cm-otel-go/internal/semconv"
+)
+
+const (
+	// ComponentTypePostgres a PostgreSQL database
+	ComponentTypePostgres = "coordimap.asset.postgres"
+
+	// ComponentTypeMySQL a MySQL database
+	ComponentTypeMySQL = "coordimap.asset.mysql"
+
+	// ComponentTypeRedis a Redis database
+	ComponentTypeRedis = "coordimap.asset.redis"
+
+	// ComponentTypeMongoDB a MongoDB database
+	ComponentTypeMongoDB = "coordimap.asset.mongodb"
+
+	// ComponentTypeNatsSubject a NATS subject
+	ComponentTypeNatsSubject = "coordimap.asset.nats_subject"
+
+	// ComponentTypeKafkaTopic a Kafka topic
+	ComponentTypeKafkaTopic = "coordimap.asset.kafka_topic"
+
+	// ComponentTypeGRPCService a gRPC service
+	ComponentTypeGRPCService = "coordimap.asset.grpc_service"
+)
+
+// ComponentRule maps the spans carrying some semantic convention attributes onto a Coordimap component type
+type ComponentRule struct {
+	// Name identifies the rule in errors
+	Name string `yaml:"name" json:"name"`
+
+	// Match the attributes the span must all carry. An empty value matches any value, otherwise values are compared case insensitively.
+	Match map[string]string `yaml:"match" json:"match"`
+
+	// SpanKinds the kinds of spans, e.g. server or client, the rule applies to, all of them when empty
+	SpanKinds []string `yaml:"span_kinds,omitempty" json:"span_kinds,omitempty"`
+
+	// Type the component type
+	Type string `yaml:"type" json:"type"`
+
+	// Data the attributes copied into the data of the component when present
+	Data []string `yaml:"data,omitempty" json:"data,omitempty"`
+}
+
+// ComponentRules an ordered set of component rules where the first matching rule wins
+type ComponentRules struct {
+	rules []ComponentRule
+}
+
+// ComponentRuleMatch the component derived from the attributes of a span
+type ComponentRuleMatch struct {
+	Rule string
+	Type string
+	Data map[string]string
+}
+
+// DefaultComponentRuleSet the rules of DefaultComponentRules. It can be appended to custom rules to extend them.
+func DefaultComponentRuleSet() []ComponentRule {
+	database := append(append(semconv.ServerAddressKeys(), semconv.ServerPortKeys()...), semconv.DBNameKeys()...)
+	messaging := append(semconv.MessagingDestinationKeys(), semconv.ServerAddressKeys()...)
+
+	return []ComponentRule{
+		{Name: "postgres", Match: map[string]string{"db.system": "postgresql"}, Type: ComponentTypePostgres, Data: database},
+		{Name: "mysql", Match: map[string]string{"db.system": "mysql"}, Type: ComponentTypeMySQL, Data: database},
+		{Name: "redis", Match: map[string]string{"db.system": "redis"}, Type: ComponentTypeRedis, Data: database},
+		{Name: "mongodb", Match: map[string]string{"db.system": "mongodb"}, Type: ComponentTypeMongoDB, Data: database},
+		{Name: "nats", Match: map[string]string{string(CmOtelMessagingSystemNats.Key): CmOtelMessagingSystemNats.Value.AsString()}, Type: ComponentTypeNatsSubject, Data: messaging},
+		{Name: "kafka", Match: map[string]string{"messaging.system": "kafka"}, Type: ComponentTypeKafkaTopic, Data: messaging},
+		{Name: "grpc", Match: map[string]string{"rpc.system": "grpc", "rpc.service": ""}, SpanKinds: []string{"server"}, Type: ComponentTypeGRPCService, Data: []string{"rpc.service"}},
+		{Name: "http", Match: map[string]string{"http.route": ""}, SpanKinds: []string{"server"}, Type: ComponentTypeHTTPRestGeneric, Data: append([]string{"http.route"}, semconv.HTTPMethodKeys()...)},
+	}
+}
+
+// DefaultComponentRules the rules for the common databases, messaging systems, gRPC and HTTP servers
+func DefaultComponentRules() *ComponentRules {
+	rules, _ := NewComponentRules(DefaultComponentRuleSet()...)
+
+	return rules
+}
+
+// NewComponentRules validates the rules and returns them as a set
+func NewComponentRules(rules ...ComponentRule) (*ComponentRules, error) {
+	for i, rule := range rules {
+		if rule.Type == "" {
+			return nil, fmt.Errorf("component rule %d %s has no type", i, rule.Name)
+		}
+
+		if len(rule.Match) == 0 {
+			return nil, fmt.Errorf("component rule %d %s matches no attribute", i, rule.Name)
+		}
+
+		for _, kind := range rule.SpanKinds {
+			if _, errKind := parseSpanKind(kind); errKind != nil {
+				return nil, errors.Join(fmt.Errorf("component rule %d %s is invalid", i, rule.Name), errKind)
+			}
+		}
+	}
+
+	return &ComponentRules{
+		rules: append([]ComponentRule{}, rules...),
+	}, nil
+}
+
+// componentRulesFile the YAML document read by LoadComponentRules
+type componentRulesFile struct {
+	// IncludeDefaults appends the DefaultComponentRuleSet after the rules of the file
+	IncludeDefaults bool            `yaml:"include_defaults"`
+	Rules           []ComponentRule `yaml:"rules"`
+}
+
+// LoadComponentRules reads the rules from a YAML document like:
+//
+//	include_defaults: true
+//	rules:
+//	  - name: billing-db
+//	    match:
+//	      db.system: postgresql
+//	      db.name: billing
+//	    span_kinds: [client]
+//	    type: coordimap.asset.postgres
+//	    data: [server.address, db.name]
+func LoadComponentRules(r io.Reader) (*ComponentRules, error) {
+	file := componentRulesFile{}
+
+	decoder := yaml.NewDecoder(r)
+	decoder.KnownFields(true)
+
+	if errDecode := decoder.Decode(&file); errDecode != nil && !errors.Is(errDecode, io.EOF) {
+		return nil, errors.Join(errors.New("could not decode the component rules"), errDecode)
+	}
+
+	rules := file.Rules
+	if file.IncludeDefaults {
+		rules = append(rules, DefaultComponentRuleSet()...)
+	}
+
+	return NewComponentRules(rules...)
+}
+
+// LoadComponentRulesFile reads the rules from a YAML file, see LoadComponentRules
+func LoadComponentRulesFile(path string) (*ComponentRules, error) {
+	file, errOpen := os.Open(path)
+	if errOpen != nil {
+		return nil, errors.Join(fmt.Errorf("could not open the component rules file %s", path), errOpen)
+	}
+	defer file.Close()
+
+	return LoadComponentRules(file)
+}
+
+// Rules returns a copy of the rules
+func (r *ComponentRules) Rules() []ComponentRule {
+	return append([]ComponentRule{}, r.rules...)
+}
+
+// Match returns the component derived by the first rule matching the span kind and attributes. The unspecified span kind matches every rule.
+func (r *ComponentRules) Match(kind trace.SpanKind, attributes []attribute.KeyValue) (ComponentRuleMatch, bool) {
+	values := map[string]string{}
+	for _, attr := range attributes {
+		values[string(attr.Key)] = attr.Value.Emit()
+	}
+
+	for _, rule := range r.rules {
+		if !rule.matches(kind, values) {
+			continue
+		}
+
+		data := map[string]string{}
+		for _, key := range rule.Data {
+			if value, ok := values[key]; ok && value != "" {
+				data[key] = value
+			}
+		}
+
+		return ComponentRuleMatch{
+			Rule: rule.Name,
+			Type: rule.Type,
+			Data: data,
+		}, true
+	}
+
+	return ComponentRuleMatch{}, false
+}
+
+func (rule ComponentRule) matches(kind trace.SpanKind, values map[string]string) bool {
+	if len(rule.SpanKinds) != 0 && kind != trace.SpanKindUnspecified {
+		kindMatches := false
+
+		for _, ruleKind := range rule.SpanKinds {
+			if parsed, _ := parseSpanKind(ruleKind); parsed == kind {
+				kindMatches = true
+			}
+		}
+
+		if !kindMatches {
+			return false
+		}
+	}
+
+	for key, want := range rule.Match {
+		value, ok := values[key]
+		if !ok || value == "" || (want != "" && !strings.EqualFold(value, want)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func parseSpanKind(kind string) (trace.SpanKind, error) {
+	switch strings.ToLower(kind) {
+	case "internal":
+		return trace.SpanKindInternal, nil
+	case "server":
+		return trace.SpanKindServer, nil
+	case "client":
+		return trace.SpanKindClient, nil
+	case "producer":
+		return trace.SpanKindProducer, nil
+	case "consumer":
+		return trace.SpanKindConsumer, nil
+	}
+
+	return trace.SpanKindUnspecified, fmt.Errorf("unknown span kind %s, expected internal, server, client, producer or consumer", kind)
+}
@@ -0,0 +1,127 @@
+package cmotel
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const testComponentRules = `
+include_defaults: true
+rules:
+  - name: billing-db
+    match:
+      db.system: postgresql
+      db.name: billing
+    span_kinds: [client]
+    type: coordimap.asset.billing_db
+    data: [db.name]
+`
+
+func TestComponentRules(t *testing.T) {
+	rules, errLoad := LoadComponentRules(strings.NewReader(testComponentRules))
+	if errLoad != nil {
+		t.Fatalf("LoadComponentRules() error = %v", errLoad)
+	}
+
+	tests := []struct {
+		name       string
+		kind       trace.SpanKind
+		attributes []attribute.KeyValue
+		want       ComponentRuleMatch
+		wantOk     bool
+	}{
+		{
+			name:       "custom rule",
+			kind:       trace.SpanKindClient,
+			attributes: []attribute.KeyValue{attribute.String("db.system", "postgresql"), attribute.String("db.name", "billing")},
+			want:       ComponentRuleMatch{Rule: "billing-db", Type: "coordimap.asset.billing_db", Data: map[string]string{"db.name": "billing"}},
+			wantOk:     true,
+		},
+		{
+			name:       "default postgres rule",
+			kind:       trace.SpanKindClient,
+			attributes: []attribute.KeyValue{attribute.String("db.system", "postgresql"), attribute.String("db.name", "orders"), attribute.String("server.address", "orders-db"), attribute.Int("server.port", 5432)},
+			want:       ComponentRuleMatch{Rule: "postgres", Type: ComponentTypePostgres, Data: map[string]string{"db.name": "orders", "server.address": "orders-db", "server.port": "5432"}},
+			wantOk:     true,
+		},
+		{
+			name:       "default postgres rule with stable names",
+			kind:       trace.SpanKindClient,
+			attributes: []attribute.KeyValue{attribute.String("db.system", "postgresql"), attribute.String("db.namespace", "orders")},
+			want:       ComponentRuleMatch{Rule: "postgres", Type: ComponentTypePostgres, Data: map[string]string{"db.namespace": "orders"}},
+			wantOk:     true,
+		},
+		{
+			name:       "nats subject",
+			kind:       trace.SpanKindProducer,
+			attributes: []attribute.KeyValue{CmOtelMessagingSystemNats, attribute.String("messaging.destination.name", "orders.created")},
+			want:       ComponentRuleMatch{Rule: "nats", Type: ComponentTypeNatsSubject, Data: map[string]string{"messaging.destination.name": "orders.created"}},
+			wantOk:     true,
+		},
+		{
+			name:       "http route of a client span",
+			kind:       trace.SpanKindClient,
+			attributes: []attribute.KeyValue{attribute.String("http.route", "/orders")},
+			wantOk:     false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := rules.Match(tt.kind, tt.attributes)
+			if ok != tt.wantOk {
+				t.Fatalf("Match() ok = %v, want %v", ok, tt.wantOk)
+			}
+
+			if ok && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadComponentRulesErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules string
+	}{
+		{name: "unknown field", rules: "rules:\n  - name: x\n    matches: {db.system: redis}\n    type: x\n"},
+		{name: "missing type", rules: "rules:\n  - name: x\n    match: {db.system: redis}\n"},
+		{name: "unknown span kind", rules: "rules:\n  - name: x\n    match: {db.system: redis}\n    type: x\n    span_kinds: [outgoing]\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := LoadComponentRules(strings.NewReader(tt.rules)); err == nil {
+				t.Errorf("LoadComponentRules() error = nil, want an error")
+			}
+		})
+	}
+}
+
+func TestAddComponentFromAttributes(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	cm := New(provider.Tracer("test"), "orders")
+
+	cm.NewSpan(WithSpanName("query"))
+	if err := cm.AddComponent(WithAddComponentSpanName("query"), WithAddComponentFromAttributes(nil, attribute.String("db.system", "redis"), attribute.String("server.address", "cache"))); err != nil {
+		t.Fatalf("AddComponent() error = %v", err)
+	}
+	cm.EndSpan("query")
+
+	component := CMComponent{}
+	for _, attr := range recorder.Ended()[0].Attributes() {
+		if attr.Key == SpanAttrComponent {
+			component, _ = ParseComponent(attr.Value.AsString())
+		}
+	}
+
+	if component.Type != ComponentTypeRedis || component.Data["server.address"] != "cache" {
+		t.Errorf("component = %+v, want a redis component at cache", component)
+	}
+}
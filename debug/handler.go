@@ -0,0 +1,164 @@
+// Package debug provides an opt-in HTTP endpoint that shows the spans registered in a CMOtel object and the topology seen by the process, e.g. to find out why an edge is missing.
+package debug
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	cmotel "github.com/coordimap/cm-otel-go"
+	"github.com/coordimap/cm-otel-go/topology"
+	"github.com/coordimap/cm-otel-go/topology/render"
+)
+
+// DefaultPath the path the handler is usually mounted on
+const DefaultPath = "/debug/coordimap"
+
+type handlerOpts struct {
+	cm      cmotel.CMOtel
+	graph   *topology.Graph
+	enabled bool
+}
+
+// Option the function parameter for NewHandler
+type Option = func(opt *handlerOpts) error
+
+// WithCMOtel the object whose spans are listed. It defaults to the singleton, when it was created, otherwise no span is listed.
+func WithCMOtel(cm cmotel.CMOtel) Option {
+	return func(opt *handlerOpts) error {
+		if cm == nil {
+			return errors.New("the cmOtel object must not be nil")
+		}
+
+		opt.cm = cm
+
+		return nil
+	}
+}
+
+// WithGraph the topology served under /topology, e.g. the graph of a topology.Processor
+func WithGraph(graph *topology.Graph) Option {
+	return func(opt *handlerOpts) error {
+		if graph == nil {
+			return errors.New("the graph must not be nil")
+		}
+
+		opt.graph = graph
+
+		return nil
+	}
+}
+
+// WithEnabled whether the endpoint responds. It defaults to the DEBUG_ENDPOINT environment variable, honouring CM_PREFIX, and is disabled when it is not set.
+func WithEnabled(enabled bool) Option {
+	return func(opt *handlerOpts) error {
+		opt.enabled = enabled
+
+		return nil
+	}
+}
+
+// Handler serves the debug endpoint
+type Handler struct {
+	options *handlerOpts
+}
+
+// NewHandler creates the debug endpoint. Once mounted under a path it serves:
+//   - <path> or <path>/spans the spans of the CMOtel object as JSON
+//   - <path>/topology the topology as JSON, or as DOT or Mermaid with ?format=dot or ?format=mermaid
+//
+// Every request gets a 404 Not Found while the endpoint is not enabled.
+func NewHandler(opts ...Option) (*Handler, error) {
+	enabled, _ := strconv.ParseBool(cmotel.GetEnvWithPrefix(os.Getenv(cmotel.EnvCmPrefix), cmotel.EnvDebugEndpoint))
+
+	options := &handlerOpts{
+		cm:      nil,
+		graph:   nil,
+		enabled: enabled,
+	}
+
+	for _, opt := range opts {
+		if err := opt(options); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Handler{
+		options: options,
+	}, nil
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.options.enabled {
+		http.NotFound(w, r)
+		return
+	}
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	path := strings.TrimSuffix(r.URL.Path, "/")
+
+	switch {
+	case strings.HasSuffix(path, "/topology"):
+		h.serveTopology(w, r)
+	case strings.HasSuffix(path, "/spans"), path == "", strings.HasSuffix(path, DefaultPath):
+		h.serveSpans(w)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *Handler) serveSpans(w http.ResponseWriter) {
+	cm := h.options.cm
+	if cm == nil {
+		// the singleton is not created here, otherwise mounting the handler would name the service
+		cm, _ = cmotel.ExistingSingleton()
+	}
+
+	// other implementations of CMOtel have no registry to list
+	spans := []cmotel.SpanInfo{}
+	if extended, ok := cm.(cmotel.ExtendedCMOtel); ok {
+		spans = extended.Spans()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(spans)
+}
+
+func (h *Handler) serveTopology(w http.ResponseWriter, r *http.Request) {
+	if h.options.graph == nil {
+		http.Error(w, "no topology graph was configured, see WithGraph", http.StatusNotFound)
+		return
+	}
+
+	var errRender error
+
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "json":
+		w.Header().Set("Content-Type", "application/json")
+		errRender = render.JSON(w, h.options.graph)
+	case "dot":
+		w.Header().Set("Content-Type", "text/vnd.graphviz")
+		errRender = render.DOT(w, h.options.graph)
+	case "mermaid":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		errRender = render.Mermaid(w, h.options.graph)
+	default:
+		http.Error(w, "unknown format "+format+", expected json, dot or mermaid", http.StatusBadRequest)
+		return
+	}
+
+	if errRender != nil {
+		http.Error(w, errRender.Error(), http.StatusInternalServerError)
+	}
+}
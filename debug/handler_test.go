@@ -0,0 +1,128 @@
+package debug
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	cmotel "github.com/coordimap/cm-otel-go"
+	"github.com/coordimap/cm-otel-go/topology"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestHandler(t *testing.T) {
+	t.Setenv(cmotel.EnvServiceNamePrefix, "cluster.namespace")
+
+	processor := topology.NewProcessor()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(processor))
+	cm := cmotel.New(provider.Tracer("test"), "orders")
+
+	if err := cm.SetSpanFromTraceparent("cluster.namespace.gateway@request", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"); err != nil {
+		t.Fatalf("SetSpanFromTraceparent() error = %v", err)
+	}
+	cm.NewSpan(cmotel.WithSpanName("handler"), cmotel.WithSpanExternalRelationshipFrom("cluster.namespace.gateway@request"))
+	if err := cm.AddComponent(cmotel.WithAddComponentSpanName("handler"), cmotel.WithAddComponentType(cmotel.ComponentTypeHTTPRestGeneric)); err != nil {
+		t.Fatalf("AddComponent() error = %v", err)
+	}
+	cm.EndSpan("handler")
+
+	enabled, errEnabled := NewHandler(WithCMOtel(cm), WithGraph(processor.Graph()), WithEnabled(true))
+	if errEnabled != nil {
+		t.Fatalf("NewHandler() error = %v", errEnabled)
+	}
+
+	disabled, errDisabled := NewHandler(WithCMOtel(cm))
+	if errDisabled != nil {
+		t.Fatalf("NewHandler() error = %v", errDisabled)
+	}
+
+	tests := []struct {
+		name         string
+		handler      http.Handler
+		target       string
+		wantStatus   int
+		wantContains string
+	}{
+		{
+			name:       "disabled",
+			handler:    disabled,
+			target:     DefaultPath,
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:         "spans",
+			handler:      enabled,
+			target:       DefaultPath + "/spans",
+			wantStatus:   http.StatusOK,
+			wantContains: `"internal_name": "cluster.namespace.orders@handler"`,
+		},
+		{
+			name:         "topology as dot",
+			handler:      enabled,
+			target:       DefaultPath + "/topology?format=dot",
+			wantStatus:   http.StatusOK,
+			wantContains: `"cluster.namespace.gateway@request" -> "cluster.namespace.orders@handler" [label="relationship"];`,
+		},
+		{
+			name:       "unknown topology format",
+			handler:    enabled,
+			target:     DefaultPath + "/topology?format=svg",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			tt.handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("ServeHTTP() status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+
+			if !strings.Contains(rec.Body.String(), tt.wantContains) {
+				t.Errorf("ServeHTTP() body = %s, want it to contain %s", rec.Body.String(), tt.wantContains)
+			}
+		})
+	}
+
+	rec := httptest.NewRecorder()
+	enabled.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, DefaultPath, nil))
+
+	spans := []cmotel.SpanInfo{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &spans); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+
+	if len(spans) != 2 {
+		t.Fatalf("spans = %+v, want the remote and the local span", spans)
+	}
+
+	remote, local := spans[0], spans[1]
+	if !remote.Remote || remote.SpanID != "00f067aa0ba902b7" {
+		t.Errorf("remote span = %+v, want a remote span with ID 00f067aa0ba902b7", remote)
+	}
+
+	if local.Remote || !local.Ended || local.Recording || len(local.Components) != 1 || len(local.Relationships) != 1 {
+		t.Errorf("local span = %+v, want an ended local span with one component and one relationship", local)
+	}
+}
+
+func TestHandlerWithoutSingleton(t *testing.T) {
+	handler, err := NewHandler(WithEnabled(true))
+	if err != nil {
+		t.Fatalf("NewHandler() error = %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, DefaultPath, nil))
+
+	if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != "[]" {
+		t.Errorf("ServeHTTP() = %d %s, want an empty list", rec.Code, rec.Body.String())
+	}
+
+	if _, ok := cmotel.ExistingSingleton(); ok {
+		t.Errorf("ServeHTTP() created the singleton")
+	}
+}
@@ -0,0 +1,177 @@
+package cmotel
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DuplicateSpanNamePolicy decides what NewSpan does when a span with the same name already exists, e.g. in loops, retries or concurrent sub-requests
+type DuplicateSpanNamePolicy string
+
+const (
+	// DuplicateSpanNameReplace the new span replaces the existing one, which can no longer be reached by name. It is the default.
+	DuplicateSpanNameReplace DuplicateSpanNamePolicy = "replace"
+
+	// DuplicateSpanNameError the new span is not created, NewSpan returns a nil span
+	DuplicateSpanNameError DuplicateSpanNamePolicy = "error"
+
+	// DuplicateSpanNameSuffix the new span is registered as <name>#2, <name>#3 and so on while the name keeps referring to the first span
+	DuplicateSpanNameSuffix DuplicateSpanNamePolicy = "suffix"
+
+	// DuplicateSpanNameStack every span is registered as <name>#1, <name>#2 and so on while the name refers to the most recent span that has not ended, so that EndSpan(name) ends the spans in reverse order. Once they all ended the name no longer refers to a span.
+	DuplicateSpanNameStack DuplicateSpanNamePolicy = "stack"
+)
+
+// SpanInstanceSeparator separates the span name from the instance number, see SpanInstanceName
+const SpanInstanceSeparator = "#"
+
+// ErrDuplicateSpanName is returned when a span with the same name exists under the DuplicateSpanNameError policy
+var ErrDuplicateSpanName = errors.New("a span with the same name already exists")
+
+// WithDuplicateSpanNamePolicy what happens when a span is created with the name of an existing span. It defaults to DuplicateSpanNameReplace.
+// Instances of a span, e.g. <name>#2, share the internal name of the span so they are reported as the same component.
+// An unknown policy makes NewSpan fail since Option cannot return an error.
+func WithDuplicateSpanNamePolicy(policy DuplicateSpanNamePolicy) Option {
+	return func(cm *cmOtel) {
+		switch policy {
+		case DuplicateSpanNameReplace, DuplicateSpanNameError, DuplicateSpanNameSuffix, DuplicateSpanNameStack:
+			cm.duplicatePolicy = policy
+		default:
+			cm.optionsErr = errors.Join(cm.optionsErr, fmt.Errorf("unknown duplicate span name policy %s", policy))
+		}
+	}
+}
+
+// SpanInstanceName returns the name used to refer to a specific instance of a span, e.g. query#2
+func SpanInstanceName(name string, instance int) string {
+	return name + SpanInstanceSeparator + strconv.Itoa(instance)
+}
+
+// spanInstanceBase returns the span name of an instance name, or the name itself when it is not one
+func spanInstanceBase(name string) string {
+	i := strings.LastIndex(name, SpanInstanceSeparator)
+	if i <= 0 {
+		return name
+	}
+
+	if _, errAtoi := strconv.Atoi(name[i+1:]); errAtoi != nil {
+		return name
+	}
+
+	return name[:i]
+}
+
+// checkInstanceName rejects the names ending with an instance number, e.g. retry#2, under the policies registering the spans as instances since they would be reported as another span
+func (cm *cmOtel) checkInstanceName(name string) error {
+	if cm.duplicatePolicy != DuplicateSpanNameSuffix && cm.duplicatePolicy != DuplicateSpanNameStack {
+		return nil
+	}
+
+	if spanInstanceBase(name) != name {
+		return fmt.Errorf("name %s must not end with %s and a number under the %s policy", name, SpanInstanceSeparator, cm.duplicatePolicy)
+	}
+
+	return nil
+}
+
+// spanKey returns the name the new span is registered under according to the duplicate span name policy
+func (cm *cmOtel) spanKey(name string) (string, error) {
+	switch cm.duplicatePolicy {
+	case DuplicateSpanNameError:
+		// a span that ended, e.g. in the previous iteration of a loop, is not a duplicate
+		if cm.SpanExists(name) && !cm.registry.ended(name) {
+			return "", fmt.Errorf("%w: %s", ErrDuplicateSpanName, name)
+		}
+
+	case DuplicateSpanNameSuffix:
+		if !cm.SpanExists(name) {
+			return name, nil
+		}
+
+		for instance := 2; ; instance++ {
+			if key := SpanInstanceName(name, instance); !cm.SpanExists(key) {
+				return key, nil
+			}
+		}
+
+	case DuplicateSpanNameStack:
+		cm.instances[name]++
+
+		return SpanInstanceName(name, cm.instances[name]), nil
+
+	default:
+		if cm.SpanExists(name) {
+			cm.lint(LintDuplicateSpanName, name, "the span replaces an existing span with the same name")
+		}
+	}
+
+	return name, nil
+}
+
+// pushInstance makes the instance the one referred to by the span name under the DuplicateSpanNameStack policy
+func (cm *cmOtel) pushInstance(name, key string) {
+	if cm.duplicatePolicy != DuplicateSpanNameStack {
+		return
+	}
+
+	cm.stacks[name] = append(cm.stacks[name], key)
+	cm.aliases[name] = key
+	cm.spans[name] = cm.spans[key]
+}
+
+// popInstance removes the ended instance from the stack of its span name. The name refers to the previous instance that has not ended, if any, otherwise it no longer refers to a span.
+func (cm *cmOtel) popInstance(key string) {
+	name := spanInstanceBase(key)
+
+	stack := cm.stacks[name]
+	for i := range stack {
+		if stack[i] == key {
+			stack = append(stack[:i], stack[i+1:]...)
+			break
+		}
+	}
+	cm.stacks[name] = stack
+
+	if cm.aliases[name] != key {
+		return
+	}
+
+	if len(stack) == 0 {
+		delete(cm.aliases, name)
+		delete(cm.spans, name)
+		delete(cm.stacks, name)
+
+		return
+	}
+
+	top := stack[len(stack)-1]
+	cm.aliases[name] = top
+	cm.spans[name] = cm.spans[top]
+}
+
+// resolveInstance returns the name of the instance a span name refers to
+func (cm *cmOtel) resolveInstance(name string) string {
+	if key, ok := cm.aliases[name]; ok {
+		return key
+	}
+
+	return name
+}
+
+// SpanName returns the name to use to refer to the span, e.g. the instance name given by the DuplicateSpanNameSuffix policy
+func (cm *cmOtel) SpanName(span trace.Span) (string, error) {
+	if span == nil {
+		return "", errors.New("the span must not be nil")
+	}
+
+	name, ok := cm.spanIDToNameMapper[span.SpanContext().SpanID().String()]
+	if !ok {
+		return "", fmt.Errorf("the span with spanID %s does not exist", span.SpanContext().SpanID().String())
+	}
+
+	return name, nil
+}
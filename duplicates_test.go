@@ -0,0 +1,165 @@
+package cmotel
+
+import (
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestDuplicateSpanNamePolicy(t *testing.T) {
+	tests := []struct {
+		name          string
+		policy        DuplicateSpanNamePolicy
+		wantSecond    bool
+		wantNames     []string
+		wantEndedLast string
+	}{
+		{
+			name:          "replace",
+			policy:        DuplicateSpanNameReplace,
+			wantSecond:    true,
+			wantNames:     []string{"query", "query"},
+			wantEndedLast: "query",
+		},
+		{
+			name:       "error",
+			policy:     DuplicateSpanNameError,
+			wantSecond: false,
+			wantNames:  []string{"query"},
+		},
+		{
+			name:          "suffix",
+			policy:        DuplicateSpanNameSuffix,
+			wantSecond:    true,
+			wantNames:     []string{"query", "query#2"},
+			wantEndedLast: "query",
+		},
+		{
+			name:          "stack",
+			policy:        DuplicateSpanNameStack,
+			wantSecond:    true,
+			wantNames:     []string{"query#1", "query#2"},
+			wantEndedLast: "query#2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := sdktrace.NewTracerProvider()
+			cm := New(provider.Tracer("test"), "orders", WithDuplicateSpanNamePolicy(tt.policy))
+
+			first, _ := cm.NewSpan(WithSpanName("query"))
+			second, _ := cm.NewSpan(WithSpanName("query"))
+
+			if (second != nil) != tt.wantSecond {
+				t.Fatalf("NewSpan() second span = %v, want created %v", second, tt.wantSecond)
+			}
+
+			for i, span := range []trace.Span{first, second}[:len(tt.wantNames)] {
+				name, err := cm.SpanName(span)
+				if err != nil || name != tt.wantNames[i] {
+					t.Errorf("SpanName() of span %d = %s, %v, want %s", i, name, err, tt.wantNames[i])
+				}
+			}
+
+			if tt.wantEndedLast == "" {
+				return
+			}
+
+			if err := cm.EndSpan("query"); err != nil {
+				t.Fatalf("EndSpan() error = %v", err)
+			}
+
+			if tt.policy == DuplicateSpanNameStack {
+				if err := cm.EndSpan("query"); err != nil {
+					t.Fatalf("EndSpan() of the first instance error = %v", err)
+				}
+
+				if first.IsRecording() || second.IsRecording() {
+					t.Errorf("EndSpan(query) twice did not end both instances")
+				}
+			}
+
+			for _, info := range cm.Spans() {
+				if info.Name == tt.wantEndedLast && !info.Ended {
+					t.Errorf("EndSpan(query) did not end %s", tt.wantEndedLast)
+				}
+
+				if info.InternalName != GetServiceName("orders")+"@query" {
+					t.Errorf("internal name of %s = %s, want the internal name of query", info.Name, info.InternalName)
+				}
+			}
+		})
+	}
+}
+
+func TestSpanNameWithInstanceSeparator(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   DuplicateSpanNamePolicy
+		spanName string
+		want     bool
+	}{
+		{name: "anchor under replace", policy: DuplicateSpanNameReplace, spanName: "GET /items#anchor", want: true},
+		{name: "instance number under replace", policy: DuplicateSpanNameReplace, spanName: "retry#2", want: true},
+		{name: "anchor under suffix", policy: DuplicateSpanNameSuffix, spanName: "GET /items#anchor", want: true},
+		{name: "instance number under suffix", policy: DuplicateSpanNameSuffix, spanName: "retry#2", want: false},
+		{name: "instance number under stack", policy: DuplicateSpanNameStack, spanName: "retry#2", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cm := New(sdktrace.NewTracerProvider().Tracer("test"), "orders", WithDuplicateSpanNamePolicy(tt.policy))
+
+			if span, _ := cm.NewSpan(WithSpanName(tt.spanName)); (span != nil) != tt.want {
+				t.Errorf("NewSpan(%s) created = %v, want %v", tt.spanName, span != nil, tt.want)
+			}
+		})
+	}
+}
+
+func TestDuplicateSpanNameErrorEndedSpan(t *testing.T) {
+	cm := New(sdktrace.NewTracerProvider().Tracer("test"), "orders", WithDuplicateSpanNamePolicy(DuplicateSpanNameError))
+
+	for i := 0; i < 2; i++ {
+		span, _ := cm.NewSpan(WithSpanName("query"))
+		if span == nil {
+			t.Fatalf("NewSpan(query) %d did not create the span after the previous one ended", i)
+		}
+
+		if err := cm.EndSpan("query"); err != nil {
+			t.Fatalf("EndSpan(query) %d error = %v", i, err)
+		}
+	}
+}
+
+func TestUnknownDuplicateSpanNamePolicy(t *testing.T) {
+	cm := New(sdktrace.NewTracerProvider().Tracer("test"), "orders", WithDuplicateSpanNamePolicy("keep"))
+
+	if _, err := cm.NewCMSpan(WithSpanName("query")); err == nil {
+		t.Errorf("NewCMSpan() error = nil, want the unknown policy reported")
+	}
+}
+
+func TestDuplicateSpanNameStackEmptied(t *testing.T) {
+	issues := []LintIssue{}
+	cm := New(sdktrace.NewTracerProvider().Tracer("test"), "orders",
+		WithDuplicateSpanNamePolicy(DuplicateSpanNameStack),
+		WithLint(func(issue LintIssue) { issues = append(issues, issue) }),
+	)
+
+	cm.NewSpan(WithSpanName("query"))
+	cm.NewSpan(WithSpanName("query"))
+
+	for i := 0; i < 2; i++ {
+		if err := cm.EndSpan("query"); err != nil {
+			t.Fatalf("EndSpan(query) %d error = %v", i, err)
+		}
+	}
+
+	if err := cm.EndSpan("query"); err == nil {
+		t.Errorf("EndSpan(query) once all instances ended error = nil, want the span not to exist")
+	}
+	if len(issues) != 0 {
+		t.Errorf("issues = %v, want none", issues)
+	}
+}
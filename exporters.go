@@ -0,0 +1,360 @@
+package cmotel
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"google.golang.org/grpc/credentials"
+)
+
+const (
+	// ExporterOTLP exports the spans with OTLP, over gRPC or HTTP depending on the protocol
+	ExporterOTLP = "otlp"
+
+	// ExporterStdout writes the spans as JSON to the standard output
+	ExporterStdout = "stdout"
+
+	// ExporterFile writes the spans as JSON to the file set in TRACES_FILE
+	ExporterFile = "file"
+
+	// ExporterNone drops all the spans
+	ExporterNone = "none"
+
+	// OTLPProtocolGRPC sends OTLP over gRPC
+	OTLPProtocolGRPC = "grpc"
+
+	// OTLPProtocolHTTPProtobuf sends OTLP over HTTP with a protobuf body
+	OTLPProtocolHTTPProtobuf = "http/protobuf"
+
+	// defaultOTLPTracesPath the path appended to base OTLP HTTP endpoints
+	defaultOTLPTracesPath = "v1/traces"
+)
+
+// otlpEnvFallbacks the standard OpenTelemetry variables read, in order, when the Coordimap one is not set. The signal specific variable always comes first.
+var otlpEnvFallbacks = map[string][]string{
+	EnvTracesExporter:   {"OTEL_TRACES_EXPORTER"},
+	EnvOTLPEndpoint:     {"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "OTEL_EXPORTER_OTLP_ENDPOINT"},
+	EnvOTLPProtocol:     {"OTEL_EXPORTER_OTLP_TRACES_PROTOCOL", "OTEL_EXPORTER_OTLP_PROTOCOL"},
+	EnvOTLPHeaders:      {"OTEL_EXPORTER_OTLP_TRACES_HEADERS", "OTEL_EXPORTER_OTLP_HEADERS"},
+	EnvOTLPInsecure:     {"OTEL_EXPORTER_OTLP_TRACES_INSECURE", "OTEL_EXPORTER_OTLP_INSECURE"},
+	EnvOTLPCertificate:  {"OTEL_EXPORTER_OTLP_TRACES_CERTIFICATE", "OTEL_EXPORTER_OTLP_CERTIFICATE"},
+	EnvOTLPCompression:  {"OTEL_EXPORTER_OTLP_TRACES_COMPRESSION", "OTEL_EXPORTER_OTLP_COMPRESSION"},
+	EnvOTLPTimeout:      {"OTEL_EXPORTER_OTLP_TRACES_TIMEOUT", "OTEL_EXPORTER_OTLP_TIMEOUT"},
+	EnvTracesExportFile: {},
+}
+
+// exporterEnv the exporter settings read from the environment
+type exporterEnv struct {
+	exporter    string
+	endpoint    string
+	isBase      bool
+	protocol    string
+	headers     map[string]string
+	insecure    bool
+	certificate string
+	compression string
+	timeout     time.Duration
+	file        string
+}
+
+// InitFromEnv creates the exporter described by the environment, see NewExporterFromEnv, and initializes the tracer provider with it
+func InitFromEnv(opts ...ProviderOption) (*Provider, error) {
+	exporter, errExporter := NewExporterFromEnv(context.Background())
+	if errExporter != nil {
+		return nil, errExporter
+	}
+
+	provider, errProvider := InitTracerProvider(exporter, opts...)
+	if errProvider != nil {
+		return nil, errors.Join(errProvider, exporter.Shutdown(context.Background()))
+	}
+
+	return provider, nil
+}
+
+// NewExporterFromEnv creates an OTLP gRPC, OTLP HTTP, stdout or file exporter from the CM_PREFIX aware environment variables:
+// TRACES_EXPORTER, OTLP_ENDPOINT, OTLP_PROTOCOL, OTLP_HEADERS, OTLP_INSECURE, OTLP_CERTIFICATE, OTLP_COMPRESSION, OTLP_TIMEOUT_MS and TRACES_FILE.
+// When one of them is not set the respective OTEL_EXPORTER_OTLP_TRACES_* and then OTEL_EXPORTER_OTLP_* variable is used.
+func NewExporterFromEnv(ctx context.Context) (sdktrace.SpanExporter, error) {
+	env, errEnv := loadExporterEnv(GetEnvWithPrefix("", EnvCmPrefix))
+	if errEnv != nil {
+		return nil, errEnv
+	}
+
+	switch env.exporter {
+	case ExporterOTLP:
+		if env.protocol == OTLPProtocolGRPC {
+			return newOTLPGRPCExporter(ctx, env)
+		}
+
+		return newOTLPHTTPExporter(ctx, env)
+
+	case ExporterStdout, "console":
+		return stdouttrace.New()
+
+	case ExporterFile:
+		if env.file == "" {
+			return nil, fmt.Errorf("%s must be set when using the file exporter", EnvTracesExportFile)
+		}
+
+		file, errOpen := os.OpenFile(env.file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if errOpen != nil {
+			return nil, errors.Join(fmt.Errorf("could not open the traces file %s", env.file), errOpen)
+		}
+
+		exporter, errExporter := stdouttrace.New(stdouttrace.WithWriter(file))
+		if errExporter != nil {
+			return nil, errors.Join(errExporter, file.Close())
+		}
+
+		return &fileExporter{SpanExporter: exporter, file: file}, nil
+
+	case ExporterNone:
+		return noopExporter{}, nil
+	}
+
+	return nil, fmt.Errorf("unknown traces exporter %s", env.exporter)
+}
+
+func loadExporterEnv(prefix string) (*exporterEnv, error) {
+	env := &exporterEnv{
+		exporter:    strings.ToLower(lookupExporterEnv(prefix, EnvTracesExporter)),
+		protocol:    lookupExporterEnv(prefix, EnvOTLPProtocol),
+		headers:     map[string]string{},
+		certificate: lookupExporterEnv(prefix, EnvOTLPCertificate),
+		compression: strings.ToLower(lookupExporterEnv(prefix, EnvOTLPCompression)),
+		file:        lookupExporterEnv(prefix, EnvTracesExportFile),
+	}
+
+	if env.exporter == "" {
+		env.exporter = ExporterOTLP
+	}
+
+	if env.protocol == "" {
+		env.protocol = OTLPProtocolHTTPProtobuf
+	}
+
+	if env.protocol != OTLPProtocolGRPC && env.protocol != OTLPProtocolHTTPProtobuf {
+		return nil, fmt.Errorf("unsupported OTLP protocol %s", env.protocol)
+	}
+
+	if env.compression != "" && env.compression != "gzip" && env.compression != "none" {
+		return nil, fmt.Errorf("unsupported OTLP compression %s", env.compression)
+	}
+
+	// only the signal specific OpenTelemetry variable is used as is, every other endpoint is a base to which the traces path is appended
+	env.endpoint = GetEnvWithPrefix(prefix, EnvOTLPEndpoint)
+	env.isBase = true
+	if env.endpoint == "" {
+		env.endpoint = os.Getenv(otlpEnvFallbacks[EnvOTLPEndpoint][0])
+		env.isBase = env.endpoint == ""
+	}
+	if env.endpoint == "" {
+		env.endpoint = os.Getenv(otlpEnvFallbacks[EnvOTLPEndpoint][1])
+	}
+
+	if headers := lookupExporterEnv(prefix, EnvOTLPHeaders); headers != "" {
+		for _, pair := range strings.Split(headers, ",") {
+			keyValue := strings.SplitN(pair, "=", 2)
+			if len(keyValue) != 2 || strings.TrimSpace(keyValue[0]) == "" {
+				return nil, fmt.Errorf("invalid OTLP header %s", pair)
+			}
+
+			value, errUnescape := url.QueryUnescape(strings.TrimSpace(keyValue[1]))
+			if errUnescape != nil {
+				return nil, errors.Join(fmt.Errorf("invalid OTLP header value for %s", keyValue[0]), errUnescape)
+			}
+
+			env.headers[strings.TrimSpace(keyValue[0])] = value
+		}
+	}
+
+	if insecure := lookupExporterEnv(prefix, EnvOTLPInsecure); insecure != "" {
+		parsed, errParse := strconv.ParseBool(insecure)
+		if errParse != nil {
+			return nil, errors.Join(fmt.Errorf("invalid OTLP insecure value %s", insecure), errParse)
+		}
+
+		env.insecure = parsed
+	}
+
+	if timeout := lookupExporterEnv(prefix, EnvOTLPTimeout); timeout != "" {
+		parsed, errParse := strconv.Atoi(timeout)
+		if errParse != nil || parsed <= 0 {
+			return nil, fmt.Errorf("the OTLP timeout must be a positive number of milliseconds, got %s", timeout)
+		}
+
+		env.timeout = time.Duration(parsed) * time.Millisecond
+	}
+
+	return env, nil
+}
+
+// lookupExporterEnv returns <prefix><env> or the first of its OpenTelemetry fallbacks that is set
+func lookupExporterEnv(prefix, env string) string {
+	if value := GetEnvWithPrefix(prefix, env); value != "" {
+		return value
+	}
+
+	for _, fallback := range otlpEnvFallbacks[env] {
+		if value := os.Getenv(fallback); value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
+
+// splitEndpoint returns the host:port, the path and whether the scheme asks for plain text of an endpoint which may or may not be a URL
+func splitEndpoint(endpoint string) (string, string, bool, error) {
+	if !strings.Contains(endpoint, "://") {
+		return endpoint, "", false, nil
+	}
+
+	parsed, errParse := url.Parse(endpoint)
+	if errParse != nil {
+		return "", "", false, errors.Join(fmt.Errorf("invalid OTLP endpoint %s", endpoint), errParse)
+	}
+
+	return parsed.Host, parsed.Path, parsed.Scheme == "http", nil
+}
+
+func (env *exporterEnv) tlsConfig() (*tls.Config, error) {
+	if env.certificate == "" {
+		return nil, nil
+	}
+
+	pem, errRead := os.ReadFile(env.certificate)
+	if errRead != nil {
+		return nil, errors.Join(fmt.Errorf("could not read the OTLP certificate %s", env.certificate), errRead)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificate found in %s", env.certificate)
+	}
+
+	return &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}, nil
+}
+
+func newOTLPGRPCExporter(ctx context.Context, env *exporterEnv) (sdktrace.SpanExporter, error) {
+	opts := []otlptracegrpc.Option{}
+
+	if env.endpoint != "" {
+		host, _, plainText, errEndpoint := splitEndpoint(env.endpoint)
+		if errEndpoint != nil {
+			return nil, errEndpoint
+		}
+
+		opts = append(opts, otlptracegrpc.WithEndpoint(host))
+		env.insecure = env.insecure || plainText
+	}
+
+	tlsConfig, errTLS := env.tlsConfig()
+	if errTLS != nil {
+		return nil, errTLS
+	}
+
+	if env.insecure {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	} else if tlsConfig != nil {
+		opts = append(opts, otlptracegrpc.WithTLSCredentials(credentials.NewTLS(tlsConfig)))
+	}
+
+	if len(env.headers) != 0 {
+		opts = append(opts, otlptracegrpc.WithHeaders(env.headers))
+	}
+
+	if env.compression == "gzip" {
+		opts = append(opts, otlptracegrpc.WithCompressor("gzip"))
+	}
+
+	if env.timeout != 0 {
+		opts = append(opts, otlptracegrpc.WithTimeout(env.timeout))
+	}
+
+	return otlptracegrpc.New(ctx, opts...)
+}
+
+func newOTLPHTTPExporter(ctx context.Context, env *exporterEnv) (sdktrace.SpanExporter, error) {
+	opts := []otlptracehttp.Option{}
+
+	if env.endpoint != "" {
+		host, urlPath, plainText, errEndpoint := splitEndpoint(env.endpoint)
+		if errEndpoint != nil {
+			return nil, errEndpoint
+		}
+
+		if env.isBase {
+			urlPath = path.Join("/", urlPath, defaultOTLPTracesPath)
+		}
+
+		opts = append(opts, otlptracehttp.WithEndpoint(host))
+		if urlPath != "" {
+			opts = append(opts, otlptracehttp.WithURLPath(urlPath))
+		}
+
+		env.insecure = env.insecure || plainText
+	}
+
+	tlsConfig, errTLS := env.tlsConfig()
+	if errTLS != nil {
+		return nil, errTLS
+	}
+
+	if env.insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	} else if tlsConfig != nil {
+		opts = append(opts, otlptracehttp.WithTLSClientConfig(tlsConfig))
+	}
+
+	if len(env.headers) != 0 {
+		opts = append(opts, otlptracehttp.WithHeaders(env.headers))
+	}
+
+	if env.compression == "gzip" {
+		opts = append(opts, otlptracehttp.WithCompression(otlptracehttp.GzipCompression))
+	} else if env.compression == "none" {
+		opts = append(opts, otlptracehttp.WithCompression(otlptracehttp.NoCompression))
+	}
+
+	if env.timeout != 0 {
+		opts = append(opts, otlptracehttp.WithTimeout(env.timeout))
+	}
+
+	return otlptracehttp.New(ctx, opts...)
+}
+
+// fileExporter closes the file the spans are written to once the exporter is shut down
+type fileExporter struct {
+	sdktrace.SpanExporter
+	file *os.File
+}
+
+func (e *fileExporter) Shutdown(ctx context.Context) error {
+	return errors.Join(e.SpanExporter.Shutdown(ctx), e.file.Close())
+}
+
+// noopExporter drops all the spans
+type noopExporter struct{}
+
+func (noopExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	return nil
+}
+
+func (noopExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
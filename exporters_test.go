@@ -0,0 +1,185 @@
+package cmotel
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// otlpGRPCReceiver a stand-in for the collector that records the spans and headers it receives over gRPC
+type otlpGRPCReceiver struct {
+	collectortrace.UnimplementedTraceServiceServer
+
+	mu       sync.Mutex
+	spans    []string
+	metadata metadata.MD
+}
+
+func (r *otlpGRPCReceiver) Export(ctx context.Context, req *collectortrace.ExportTraceServiceRequest) (*collectortrace.ExportTraceServiceResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.metadata, _ = metadata.FromIncomingContext(ctx)
+	for _, resourceSpans := range req.ResourceSpans {
+		for _, scopeSpans := range resourceSpans.ScopeSpans {
+			for _, span := range scopeSpans.Spans {
+				r.spans = append(r.spans, span.Name)
+			}
+		}
+	}
+
+	return &collectortrace.ExportTraceServiceResponse{}, nil
+}
+
+func exportOneSpan(t *testing.T) {
+	t.Helper()
+
+	provider, err := InitFromEnv(WithGlobalTracerProvider(false))
+	if err != nil {
+		t.Fatalf("InitFromEnv() error = %v", err)
+	}
+
+	_, span := provider.Tracer("test").Start(context.Background(), "exported")
+	span.End()
+
+	if err := provider.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+}
+
+func TestInitFromEnvOTLPGRPC(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen() error = %v", err)
+	}
+
+	receiver := &otlpGRPCReceiver{}
+	server := grpc.NewServer()
+	collectortrace.RegisterTraceServiceServer(server, receiver)
+	go server.Serve(listener)
+	defer server.Stop()
+
+	t.Setenv(EnvOTLPProtocol, OTLPProtocolGRPC)
+	t.Setenv(EnvOTLPEndpoint, "http://"+listener.Addr().String())
+	t.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "x-api-key=secret%20key")
+
+	exportOneSpan(t)
+
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+
+	if len(receiver.spans) != 1 || receiver.spans[0] != "exported" {
+		t.Errorf("received spans = %v, want [exported]", receiver.spans)
+	}
+	if got := receiver.metadata.Get("x-api-key"); len(got) != 1 || got[0] != "secret key" {
+		t.Errorf("received x-api-key = %v, want [secret key]", got)
+	}
+}
+
+func TestInitFromEnvOTLPHTTP(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      map[string]string
+		wantPath string
+	}{
+		{
+			name:     "coordimap base endpoint",
+			env:      map[string]string{EnvOTLPEndpoint: "http://%s/otlp"},
+			wantPath: "/otlp/v1/traces",
+		},
+		{
+			name:     "otel signal endpoint",
+			env:      map[string]string{"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT": "http://%s/custom/traces"},
+			wantPath: "/custom/traces",
+		},
+		{
+			name:     "otel base endpoint with gzip",
+			env:      map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": "http://%s", EnvOTLPCompression: "gzip"},
+			wantPath: "/v1/traces",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			gotPaths := []string{}
+
+			server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				io.Copy(io.Discard, r.Body)
+
+				mu.Lock()
+				gotPaths = append(gotPaths, r.URL.Path)
+				mu.Unlock()
+
+				rw.Header().Set("Content-Type", "application/x-protobuf")
+				rw.WriteHeader(http.StatusOK)
+			}))
+			defer server.Close()
+
+			host := strings.TrimPrefix(server.URL, "http://")
+			for key, value := range tt.env {
+				if strings.Contains(value, "%s") {
+					value = strings.Replace(value, "%s", host, 1)
+				}
+				t.Setenv(key, value)
+			}
+
+			exportOneSpan(t)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if len(gotPaths) != 1 || gotPaths[0] != tt.wantPath {
+				t.Errorf("received paths = %v, want [%s]", gotPaths, tt.wantPath)
+			}
+		})
+	}
+}
+
+func TestInitFromEnvFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "traces.json")
+	t.Setenv(EnvCmPrefix, "APP_")
+	t.Setenv("APP_"+EnvTracesExporter, ExporterFile)
+	t.Setenv("APP_"+EnvTracesExportFile, file)
+
+	exportOneSpan(t)
+
+	contents, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("os.ReadFile() error = %v", err)
+	}
+	if !strings.Contains(string(contents), `"Name":"exported"`) {
+		t.Errorf("traces file = %s, want the exported span", contents)
+	}
+}
+
+func TestNewExporterFromEnvInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		env   string
+		value string
+	}{
+		{name: "unknown exporter", env: EnvTracesExporter, value: "zipkin"},
+		{name: "unknown protocol", env: EnvOTLPProtocol, value: "http/json"},
+		{name: "invalid header", env: EnvOTLPHeaders, value: "novalue"},
+		{name: "invalid timeout", env: EnvOTLPTimeout, value: "10s"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(tt.env, tt.value)
+
+			if _, err := NewExporterFromEnv(context.Background()); err == nil {
+				t.Errorf("NewExporterFromEnv() error = nil, want an error for %s=%s", tt.env, tt.value)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package cmotel
 
 import (
 	"sync"
+	"time"
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/trace"
@@ -10,19 +11,46 @@ import (
 var lock = &sync.Mutex{}
 var singleton *cmOtel
 
+// WithTraceHeaderFormats the formats used by InjectSpanHeaders to propagate the span context to downstream services. It defaults to W3C.
+func WithTraceHeaderFormats(formats ...TraceHeaderFormat) Option {
+	return func(cm *cmOtel) {
+		cm.headerFormats = append(cm.headerFormats, formats...)
+	}
+}
+
+// WithSpanMapOptions the options used by InjectSpanHeaders to encode the span map header, e.g. WithSpanMapEncoding(SpanMapEncodingV1)
+func WithSpanMapOptions(opts ...SpanMapOption) Option {
+	return func(cm *cmOtel) {
+		cm.spanMapOpts = append(cm.spanMapOpts, opts...)
+	}
+}
+
+// WithSpanMapSigning signs the span map header set by InjectSpanHeaders with the provided key. The signature expires after ttl, or DefaultSpanMapSignatureTTL when ttl is zero.
+func WithSpanMapSigning(key SpanMapKey, ttl time.Duration) Option {
+	return func(cm *cmOtel) {
+		if ttl == 0 {
+			ttl = DefaultSpanMapSignatureTTL
+		}
+
+		cm.signingKey = &key
+		cm.signingTTL = ttl
+	}
+}
+
+// WithPodBaggage adds the name of the pod to the identity InjectSpanHeaders sends to downstream services in the W3C baggage, see BaggageKeyK8SPod
+func WithPodBaggage() Option {
+	return func(cm *cmOtel) {
+		cm.podBaggage = true
+	}
+}
+
 // CreateSingleton create a singleton structure
-func CreateSingleton(intialTracer trace.Tracer, serviceName string) CMOtel {
+func CreateSingleton(intialTracer trace.Tracer, serviceName string, opts ...Option) CMOtel {
 	if singleton == nil {
 		lock.Lock()
 		defer lock.Unlock()
 
-		singleton = &cmOtel{
-			tracer:             intialTracer,
-			serviceName:        serviceName,
-			spans:              map[string]cmSpan{},
-			relationships:      map[string]string{},
-			spanIDToNameMapper: map[string]string{},
-		}
+		singleton = newCMOtel(intialTracer, serviceName, opts...)
 	}
 
 	return singleton
@@ -37,14 +65,42 @@ func Singleton() CMOtel {
 	return singleton
 }
 
+// ExistingSingleton returns the singleton object when it was created, unlike Singleton which creates it
+func ExistingSingleton() (CMOtel, bool) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if singleton == nil {
+		return nil, false
+	}
+
+	return singleton, true
+}
+
 // New creates a new object to handle the traces
-func New(initialTracer trace.Tracer, serviceName string) CMOtel {
-	return &cmOtel{
+func New(initialTracer trace.Tracer, serviceName string, opts ...Option) ExtendedCMOtel {
+	return newCMOtel(initialTracer, serviceName, opts...)
+}
+
+func newCMOtel(initialTracer trace.Tracer, serviceName string, opts ...Option) *cmOtel {
+	cm := &cmOtel{
 		tracer:             initialTracer,
 		serviceName:        serviceName,
 		spans:              map[string]cmSpan{},
 		relationships:      map[string]string{},
 		spanIDToNameMapper: map[string]string{},
+		headerFormats:      []TraceHeaderFormat{},
+		spanMapOpts:        []SpanMapOption{},
+		registry:           newSpanRegistry(),
+		duplicatePolicy:    DuplicateSpanNameReplace,
+		instances:          map[string]int{},
+		stacks:             map[string][]string{},
+		aliases:            map[string]string{},
+	}
+
+	for _, opt := range opts {
+		opt(cm)
 	}
 
+	return cm
 }
@@ -0,0 +1,291 @@
+// Package semconv is the single place the library takes its semantic convention attribute names from.
+// It emits the HTTP attributes under the names of semconv v1.21.0 by default and the stable names, or both, according to OTEL_SEMCONV_STABILITY_OPT_IN like the OTel instrumentation libraries do.
+// The library emits no database nor messaging attribute whose name changed, e.g. messaging.system is the same in both, so it only reads those under both names.
+package semconv
+
+import (
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+)
+
+// SchemaURL the schema of the attributes of the resources
+const SchemaURL = semconv.SchemaURL
+
+// EnvStabilityOptIn the OTel variable holding the comma separated domains, e.g. http or http/dup, that emit the stable attribute names. Only the http domain is taken into account.
+const EnvStabilityOptIn = "OTEL_SEMCONV_STABILITY_OPT_IN"
+
+// Domain a group of conventions whose stable names are opted in together
+type Domain string
+
+// DomainHTTP the HTTP client and server conventions
+const DomainHTTP Domain = "http"
+
+// Mode which attribute names are emitted
+type Mode int
+
+const (
+	// ModeOld only the names of semconv v1.21.0
+	ModeOld Mode = iota
+
+	// ModeStable only the stable names
+	ModeStable
+
+	// ModeDuplicate both the old and the stable names, to migrate dashboards and queries
+	ModeDuplicate
+)
+
+// Conventions emits the attributes according to the mode of every domain
+type Conventions struct {
+	modes map[Domain]Mode
+}
+
+// New returns conventions using the same mode for every domain
+func New(mode Mode) Conventions {
+	return Conventions{
+		modes: map[Domain]Mode{
+			DomainHTTP: mode,
+		},
+	}
+}
+
+// FromEnv returns the conventions selected by OTEL_SEMCONV_STABILITY_OPT_IN, where http selects ModeStable and http/dup ModeDuplicate. The HTTP attributes use ModeOld otherwise.
+func FromEnv() Conventions {
+	conventions := New(ModeOld)
+
+	for _, value := range strings.Split(os.Getenv(EnvStabilityOptIn), ",") {
+		domain, dup := strings.CutSuffix(strings.TrimSpace(value), "/dup")
+
+		if _, ok := conventions.modes[Domain(domain)]; !ok {
+			continue
+		}
+
+		// the duplicate mode wins when both are listed
+		if dup {
+			conventions.modes[Domain(domain)] = ModeDuplicate
+		} else if conventions.modes[Domain(domain)] != ModeDuplicate {
+			conventions.modes[Domain(domain)] = ModeStable
+		}
+	}
+
+	return conventions
+}
+
+// Mode returns the mode of the domain
+func (c Conventions) Mode(domain Domain) Mode {
+	return c.modes[domain]
+}
+
+// pick returns the attribute under the old name, the stable name or both depending on the mode of the domain
+func (c Conventions) pick(domain Domain, old, stable attribute.KeyValue) []attribute.KeyValue {
+	switch c.modes[domain] {
+	case ModeStable:
+		return []attribute.KeyValue{stable}
+	case ModeDuplicate:
+		return []attribute.KeyValue{old, stable}
+	}
+
+	return []attribute.KeyValue{old}
+}
+
+// HTTPMethod http.method or http.request.method
+func (c Conventions) HTTPMethod(method string) []attribute.KeyValue {
+	return c.pick(DomainHTTP, semconv.HTTPMethod(method), attribute.String("http.request.method", method))
+}
+
+// HTTPTarget http.url with the request target of a server span, or url.path and url.query. The stable url.full must be absolute, which a server does not know for sure.
+func (c Conventions) HTTPTarget(requestURI, path, query string) []attribute.KeyValue {
+	stable := []attribute.KeyValue{attribute.String("url.path", path)}
+	if query != "" {
+		stable = append(stable, attribute.String("url.query", query))
+	}
+
+	switch c.modes[DomainHTTP] {
+	case ModeStable:
+		return stable
+	case ModeDuplicate:
+		return append([]attribute.KeyValue{semconv.HTTPURL(requestURI)}, stable...)
+	}
+
+	return []attribute.KeyValue{semconv.HTTPURL(requestURI)}
+}
+
+// HTTPRoute http.route, which has the same name in both conventions
+func (c Conventions) HTTPRoute(route string) []attribute.KeyValue {
+	return []attribute.KeyValue{semconv.HTTPRoute(route)}
+}
+
+// HTTPMethodKeys http.method and http.request.method, to read the spans of instrumentations using either conventions
+func HTTPMethodKeys() []string {
+	return []string{string(semconv.HTTPMethodKey), "http.request.method"}
+}
+
+// ServerAddressKeys net.peer.name and server.address, to read the spans of instrumentations using either conventions
+func ServerAddressKeys() []string {
+	return []string{string(semconv.NetPeerNameKey), string(semconv.ServerAddressKey)}
+}
+
+// ServerPortKeys net.peer.port and server.port, to read the spans of instrumentations using either conventions
+func ServerPortKeys() []string {
+	return []string{string(semconv.NetPeerPortKey), string(semconv.ServerPortKey)}
+}
+
+// DBNameKeys db.name and db.namespace, to read the spans of instrumentations using either conventions
+func DBNameKeys() []string {
+	return []string{string(semconv.DBNameKey), "db.namespace"}
+}
+
+// MessagingDestinationKeys messaging.destination.name, which has the same name in both conventions
+func MessagingDestinationKeys() []string {
+	return []string{string(semconv.MessagingDestinationNameKey)}
+}
+
+// MessagingSystemKey the messaging.system attribute key
+const MessagingSystemKey = semconv.MessagingSystemKey
+
+// ServiceName service.name
+func ServiceName(name string) attribute.KeyValue {
+	return semconv.ServiceName(name)
+}
+
+// K8SNamespaceName k8s.namespace.name
+func K8SNamespaceName(name string) attribute.KeyValue {
+	return semconv.K8SNamespaceName(name)
+}
+
+// K8SNodeName k8s.node.name
+func K8SNodeName(name string) attribute.KeyValue {
+	return semconv.K8SNodeName(name)
+}
+
+// K8SPodName k8s.pod.name
+func K8SPodName(name string) attribute.KeyValue {
+	return semconv.K8SPodName(name)
+}
+
+// K8SClusterName k8s.cluster.name
+func K8SClusterName(name string) attribute.KeyValue {
+	return semconv.K8SClusterName(name)
+}
+
+// K8SPodUID k8s.pod.uid
+func K8SPodUID(uid string) attribute.KeyValue {
+	return semconv.K8SPodUID(uid)
+}
+
+// K8SContainerName k8s.container.name
+func K8SContainerName(name string) attribute.KeyValue {
+	return semconv.K8SContainerName(name)
+}
+
+// K8SDeploymentName k8s.deployment.name
+func K8SDeploymentName(name string) attribute.KeyValue {
+	return semconv.K8SDeploymentName(name)
+}
+
+// K8SReplicaSetName k8s.replicaset.name
+func K8SReplicaSetName(name string) attribute.KeyValue {
+	return semconv.K8SReplicaSetName(name)
+}
+
+// K8SStatefulSetName k8s.statefulset.name
+func K8SStatefulSetName(name string) attribute.KeyValue {
+	return semconv.K8SStatefulSetName(name)
+}
+
+// K8SPodLabel k8s.pod.label.<key>
+func K8SPodLabel(key, value string) attribute.KeyValue {
+	return attribute.String("k8s.pod.label."+key, value)
+}
+
+// K8SPodAnnotation k8s.pod.annotation.<key>
+func K8SPodAnnotation(key, value string) attribute.KeyValue {
+	return attribute.String("k8s.pod.annotation."+key, value)
+}
+
+// CloudProvider cloud.provider, e.g. aws, gcp or azure
+func CloudProvider(provider string) attribute.KeyValue {
+	return semconv.CloudProviderKey.String(provider)
+}
+
+// CloudPlatform cloud.platform, e.g. aws_lambda or gcp_cloud_run
+func CloudPlatform(platform string) attribute.KeyValue {
+	return semconv.CloudPlatformKey.String(platform)
+}
+
+// CloudRegion cloud.region
+func CloudRegion(region string) attribute.KeyValue {
+	return semconv.CloudRegion(region)
+}
+
+// CloudAvailabilityZone cloud.availability_zone
+func CloudAvailabilityZone(zone string) attribute.KeyValue {
+	return semconv.CloudAvailabilityZone(zone)
+}
+
+// CloudAccountID cloud.account.id
+func CloudAccountID(id string) attribute.KeyValue {
+	return semconv.CloudAccountID(id)
+}
+
+// CloudResourceID cloud.resource_id
+func CloudResourceID(id string) attribute.KeyValue {
+	return semconv.CloudResourceID(id)
+}
+
+// HostID host.id
+func HostID(id string) attribute.KeyValue {
+	return semconv.HostID(id)
+}
+
+// FaaSName faas.name
+func FaaSName(name string) attribute.KeyValue {
+	return semconv.FaaSName(name)
+}
+
+// FaaSVersion faas.version
+func FaaSVersion(version string) attribute.KeyValue {
+	return semconv.FaaSVersion(version)
+}
+
+// FaaSInstance faas.instance
+func FaaSInstance(instance string) attribute.KeyValue {
+	return semconv.FaaSInstance(instance)
+}
+
+// AWSECSClusterARN aws.ecs.cluster.arn
+func AWSECSClusterARN(arn string) attribute.KeyValue {
+	return semconv.AWSECSClusterARN(arn)
+}
+
+// AWSECSTaskARN aws.ecs.task.arn
+func AWSECSTaskARN(arn string) attribute.KeyValue {
+	return semconv.AWSECSTaskARN(arn)
+}
+
+// AWSECSTaskFamily aws.ecs.task.family
+func AWSECSTaskFamily(family string) attribute.KeyValue {
+	return semconv.AWSECSTaskFamily(family)
+}
+
+// AWSECSTaskRevision aws.ecs.task.revision
+func AWSECSTaskRevision(revision string) attribute.KeyValue {
+	return semconv.AWSECSTaskRevision(revision)
+}
+
+// AWSECSLaunchType aws.ecs.launchtype, either ec2 or fargate
+func AWSECSLaunchType(launchType string) attribute.KeyValue {
+	return semconv.AWSECSLaunchtypeKey.String(launchType)
+}
+
+// GCPCloudRunJobExecution gcp.cloud_run.job.execution
+func GCPCloudRunJobExecution(execution string) attribute.KeyValue {
+	return semconv.GCPCloudRunJobExecution(execution)
+}
+
+// GCPCloudRunJobTaskIndex gcp.cloud_run.job.task_index
+func GCPCloudRunJobTaskIndex(index int) attribute.KeyValue {
+	return semconv.GCPCloudRunJobTaskIndex(index)
+}
@@ -0,0 +1,96 @@
+package semconv
+
+import (
+	"reflect"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestFromEnv(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  map[Domain]Mode
+	}{
+		{
+			name:  "unset",
+			value: "",
+			want:  map[Domain]Mode{DomainHTTP: ModeOld},
+		},
+		{
+			name:  "stable http",
+			value: "http",
+			want:  map[Domain]Mode{DomainHTTP: ModeStable},
+		},
+		{
+			name:  "duplicate wins",
+			value: "http, http/dup,database",
+			want:  map[Domain]Mode{DomainHTTP: ModeDuplicate},
+		},
+		{
+			name:  "domain without emitted attributes",
+			value: "database/dup",
+			want:  map[Domain]Mode{DomainHTTP: ModeOld},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(EnvStabilityOptIn, tt.value)
+
+			conventions := FromEnv()
+			for domain, want := range tt.want {
+				if got := conventions.Mode(domain); got != want {
+					t.Errorf("Mode(%v) = %v, want %v", domain, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestConventions(t *testing.T) {
+	tests := []struct {
+		name string
+		mode Mode
+		got  func(Conventions) []attribute.KeyValue
+		want []attribute.KeyValue
+	}{
+		{
+			name: "old method",
+			mode: ModeOld,
+			got:  func(c Conventions) []attribute.KeyValue { return c.HTTPMethod("GET") },
+			want: []attribute.KeyValue{attribute.String("http.method", "GET")},
+		},
+		{
+			name: "old target",
+			mode: ModeOld,
+			got:  func(c Conventions) []attribute.KeyValue { return c.HTTPTarget("/users?id=1", "/users", "id=1") },
+			want: []attribute.KeyValue{attribute.String("http.url", "/users?id=1")},
+		},
+		{
+			name: "stable target",
+			mode: ModeStable,
+			got:  func(c Conventions) []attribute.KeyValue { return c.HTTPTarget("/users?id=1", "/users", "id=1") },
+			want: []attribute.KeyValue{attribute.String("url.path", "/users"), attribute.String("url.query", "id=1")},
+		},
+		{
+			name: "duplicate target without query",
+			mode: ModeDuplicate,
+			got:  func(c Conventions) []attribute.KeyValue { return c.HTTPTarget("/users", "/users", "") },
+			want: []attribute.KeyValue{attribute.String("http.url", "/users"), attribute.String("url.path", "/users")},
+		},
+		{
+			name: "route is not duplicated",
+			mode: ModeDuplicate,
+			got:  func(c Conventions) []attribute.KeyValue { return c.HTTPRoute("/users") },
+			want: []attribute.KeyValue{attribute.String("http.route", "/users")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.got(New(tt.mode)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
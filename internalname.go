@@ -0,0 +1,36 @@
+package cmotel
+
+import (
+	"context"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type internalNameContextKey struct{}
+
+// ContextWithInternalName stores the internal name of the current span in the context so that spans created from it by any CMOtel object get it as SpanAttrParentName.
+// The contexts returned by NewSpan, NewCMSpan and GetSpanContext already carry it.
+func ContextWithInternalName(ctx context.Context, internalName string) context.Context {
+	return context.WithValue(ctx, internalNameContextKey{}, internalName)
+}
+
+// InternalNameFromContext returns the internal name of the nearest Coordimap span of the context.
+// The SpanAttrInternalName attribute of the current span is used first, which allows spans of other OTel instrumentation to be named, and then the name stored by ContextWithInternalName, which may belong to an ancestor when other instrumentation created spans in between.
+func InternalNameFromContext(ctx context.Context) (string, bool) {
+	if ctx == nil {
+		return "", false
+	}
+
+	if span, ok := trace.SpanFromContext(ctx).(sdktrace.ReadOnlySpan); ok {
+		for _, attr := range span.Attributes() {
+			if attr.Key == SpanAttrInternalName && attr.Value.AsString() != "" {
+				return attr.Value.AsString(), true
+			}
+		}
+	}
+
+	internalName, ok := ctx.Value(internalNameContextKey{}).(string)
+
+	return internalName, ok && internalName != ""
+}
@@ -0,0 +1,103 @@
+package cmotel
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestParentFromContext(t *testing.T) {
+	t.Setenv(EnvServiceNamePrefix, "cluster.namespace")
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	tracer := provider.Tracer("third-party")
+
+	orders := New(provider.Tracer("test"), "orders")
+	_, ordersCtx := orders.NewSpan(WithSpanName("handler"))
+
+	tests := []struct {
+		name string
+		ctx  func() context.Context
+		want string
+	}{
+		{
+			name: "span of another CMOtel object",
+			ctx:  func() context.Context { return ordersCtx },
+			want: "cluster.namespace.orders@handler",
+		},
+		{
+			name: "third party span in between",
+			ctx: func() context.Context {
+				ctx, _ := tracer.Start(ordersCtx, "HTTP GET")
+				return ctx
+			},
+			want: "cluster.namespace.orders@handler",
+		},
+		{
+			name: "named third party span",
+			ctx: func() context.Context {
+				ctx, _ := tracer.Start(ordersCtx, "HTTP GET", trace.WithAttributes(attribute.String(SpanAttrInternalName, "cluster.namespace.orders@http-client")))
+				return ctx
+			},
+			want: "cluster.namespace.orders@http-client",
+		},
+		{
+			name: "no coordimap span",
+			ctx: func() context.Context {
+				ctx, _ := tracer.Start(context.Background(), "HTTP GET")
+				return ctx
+			},
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payments := New(provider.Tracer("test"), "payments")
+			span, _ := payments.NewSpan(WithSpanName("charge"), WithSpanContext(tt.ctx()))
+			span.End()
+
+			ended := recorder.Ended()
+			got := ""
+			for _, attr := range ended[len(ended)-1].Attributes() {
+				if attr.Key == SpanAttrParentName {
+					got = attr.Value.AsString()
+				}
+			}
+
+			if got != tt.want {
+				t.Errorf("parent name = %s, want %s", got, tt.want)
+			}
+
+			if infos := payments.Spans(); len(infos) != 1 || infos[0].ParentName != "" || infos[0].ParentInternalName != tt.want {
+				t.Errorf("Spans() = %v, want no local parent and the parent internal name %s", infos, tt.want)
+			}
+		})
+	}
+}
+
+func TestParentNameSpanInfo(t *testing.T) {
+	t.Setenv(EnvServiceNamePrefix, "cluster.namespace")
+
+	cm := New(sdktrace.NewTracerProvider().Tracer("test"), "orders")
+	cm.NewSpan(WithSpanName("handler"))
+	cm.NewSpan(WithSpanName("query"), WithParentSpanName("handler"))
+
+	for _, info := range cm.Spans() {
+		if info.Name != "query" {
+			continue
+		}
+
+		if info.ParentName != "handler" || info.ParentInternalName != "cluster.namespace.orders@handler" {
+			t.Errorf("parent = %s, %s, want handler, cluster.namespace.orders@handler", info.ParentName, info.ParentInternalName)
+		}
+
+		return
+	}
+
+	t.Errorf("Spans() did not return the query span")
+}
@@ -0,0 +1,336 @@
+package cmotel
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+
+	"github.com/coordimap/cm-otel-go/internal/semconv"
+)
+
+const (
+	// ComponentTypeK8SNode a Kubernetes node
+	ComponentTypeK8SNode = "coordimap.asset.k8s_node"
+
+	// ComponentTypeK8SPod a Kubernetes pod
+	ComponentTypeK8SPod = "coordimap.asset.k8s_pod"
+
+	// DefaultDownwardAPIPath the directory the downward API volume is usually mounted at
+	DefaultDownwardAPIPath = "/etc/podinfo"
+
+	// K8SResourceSource the name of the Kubernetes detector in ResourceComponentsKey
+	K8SResourceSource = "k8s"
+)
+
+// the names of the files of the downward API volume
+const (
+	downwardAPILabels      = "labels"
+	downwardAPIAnnotations = "annotations"
+	downwardAPIUID         = "uid"
+	downwardAPIName        = "name"
+	downwardAPINamespace   = "namespace"
+)
+
+// the alphabet of the random suffixes Kubernetes appends to the names of replica sets and pods
+const k8sNameSuffixAlphabet = "bcdfghjklmnpqrstvwxz2456789"
+
+type k8sDetectorOpts struct {
+	prefix      string
+	downwardAPI string
+}
+
+// K8SDetectorOption configures the Kubernetes resource detector
+type K8SDetectorOption func(*k8sDetectorOpts) error
+
+// WithK8SEnvPrefix the prefix of the environment variables, CM_PREFIX by default
+func WithK8SEnvPrefix(prefix string) K8SDetectorOption {
+	return func(opts *k8sDetectorOpts) error {
+		opts.prefix = prefix
+
+		return nil
+	}
+}
+
+// WithK8SDownwardAPIPath the directory of the downward API volume. It overrides DOWNWARD_API_PATH.
+func WithK8SDownwardAPIPath(path string) K8SDetectorOption {
+	return func(opts *k8sDetectorOpts) error {
+		if path == "" {
+			return errors.New("the downward API path must not be empty")
+		}
+
+		opts.downwardAPI = path
+
+		return nil
+	}
+}
+
+type k8sDetector struct {
+	prefix      string
+	downwardAPI string
+}
+
+// NewK8SDetector creates a resource detector reading the pod metadata from the environment variables and the downward API volume. The environment variables win over the files.
+// Besides the k8s.* attributes, the resource holds the node and the pod as Coordimap containers, see ResourceComponents.
+func NewK8SDetector(opts ...K8SDetectorOption) (resource.Detector, error) {
+	options := &k8sDetectorOpts{
+		prefix:      GetEnvWithPrefix("", EnvCmPrefix),
+		downwardAPI: "",
+	}
+
+	for _, opt := range opts {
+		if err := opt(options); err != nil {
+			return nil, err
+		}
+	}
+
+	if options.downwardAPI == "" {
+		options.downwardAPI = GetEnvWithPrefix(options.prefix, EnvDownwardAPIPath)
+	}
+
+	if options.downwardAPI == "" {
+		options.downwardAPI = DefaultDownwardAPIPath
+	}
+
+	return &k8sDetector{
+		prefix:      options.prefix,
+		downwardAPI: options.downwardAPI,
+	}, nil
+}
+
+// Detect returns an empty resource when no pod metadata is found. The files of the downward API that cannot be read are skipped and reported with a resource.ErrPartialResource error along with the resource.
+func (d *k8sDetector) Detect(ctx context.Context) (*resource.Resource, error) {
+	cluster := GetEnvWithPrefix(d.prefix, EnvK8SClusterName)
+	node := GetEnvWithPrefix(d.prefix, EnvNodeNameType)
+	container := GetEnvWithPrefix(d.prefix, EnvContainerNameType)
+
+	namespace, errNamespace := d.value(EnvK8SNamespaceName, downwardAPINamespace)
+	pod, errPod := d.value(EnvPodNameType, downwardAPIName)
+	uid, errUID := d.value(EnvPodUIDType, downwardAPIUID)
+	labels, errLabels := d.readMap(downwardAPILabels)
+	annotations, errAnnotations := d.readMap(downwardAPIAnnotations)
+
+	// an unreadable file is skipped so that the rest, e.g. the environment variables, is still detected
+	errPartial := error(nil)
+	if errRead := errors.Join(errNamespace, errPod, errUID, errLabels, errAnnotations); errRead != nil {
+		errPartial = errors.Join(fmt.Errorf("%w: could not read the downward API volume", resource.ErrPartialResource), errRead)
+	}
+
+	attributes := []attribute.KeyValue{}
+
+	for _, value := range []struct {
+		value     string
+		attribute func(string) attribute.KeyValue
+	}{
+		{value: cluster, attribute: semconv.K8SClusterName},
+		{value: namespace, attribute: semconv.K8SNamespaceName},
+		{value: node, attribute: semconv.K8SNodeName},
+		{value: pod, attribute: semconv.K8SPodName},
+		{value: uid, attribute: semconv.K8SPodUID},
+		{value: container, attribute: semconv.K8SContainerName},
+	} {
+		if value.value != "" {
+			attributes = append(attributes, value.attribute(value.value))
+		}
+	}
+
+	owner := k8sPodOwner(pod, labels)
+	switch {
+	case owner.deployment != "":
+		attributes = append(attributes, semconv.K8SDeploymentName(owner.deployment), semconv.K8SReplicaSetName(owner.replicaSet))
+	case owner.statefulSet != "":
+		attributes = append(attributes, semconv.K8SStatefulSetName(owner.statefulSet))
+	}
+
+	for _, key := range sortedKeys(labels) {
+		attributes = append(attributes, semconv.K8SPodLabel(key, labels[key]))
+	}
+
+	for _, key := range sortedKeys(annotations) {
+		attributes = append(attributes, semconv.K8SPodAnnotation(key, annotations[key]))
+	}
+
+	components := []CMComponent{}
+
+	if node != "" {
+		components = append(components, CMComponent{
+			Name:        node,
+			InternalID:  joinNonEmpty(".", cluster, node),
+			Type:        ComponentTypeK8SNode,
+			Data:        map[string]string{"cluster": cluster},
+			IsContainer: true,
+		})
+	}
+
+	if pod != "" {
+		components = append(components, CMComponent{
+			Name:       pod,
+			InternalID: joinNonEmpty(".", cluster, namespace, pod),
+			Type:       ComponentTypeK8SPod,
+			Data: map[string]string{
+				"cluster":     cluster,
+				"namespace":   namespace,
+				"uid":         uid,
+				"container":   container,
+				"deployment":  owner.deployment,
+				"statefulset": owner.statefulSet,
+			},
+			IsContainer: true,
+		})
+	}
+
+	if len(components) > 0 {
+		componentsAttribute, errComponents := ResourceComponents(K8SResourceSource, components...)
+		if errComponents != nil {
+			return nil, errComponents
+		}
+
+		attributes = append(attributes, componentsAttribute)
+	}
+
+	if len(attributes) == 0 {
+		return resource.Empty(), errPartial
+	}
+
+	return resource.NewWithAttributes(semconv.SchemaURL, attributes...), errPartial
+}
+
+// value returns the environment variable or else the content of the downward API file
+func (d *k8sDetector) value(env, file string) (string, error) {
+	if value := GetEnvWithPrefix(d.prefix, env); value != "" {
+		return value, nil
+	}
+
+	contents, errRead := os.ReadFile(filepath.Join(d.downwardAPI, file))
+	if errors.Is(errRead, os.ErrNotExist) {
+		return "", nil
+	}
+
+	if errRead != nil {
+		return "", errRead
+	}
+
+	return strings.TrimSpace(string(contents)), nil
+}
+
+// readMap parses a labels or annotations file of the downward API, one key="value" pair per line
+func (d *k8sDetector) readMap(file string) (map[string]string, error) {
+	values := map[string]string{}
+
+	f, errOpen := os.Open(filepath.Join(d.downwardAPI, file))
+	if errors.Is(errOpen, os.ErrNotExist) {
+		return values, nil
+	}
+
+	if errOpen != nil {
+		return nil, errOpen
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		key, quoted, found := strings.Cut(line, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid line %s in %s", line, file)
+		}
+
+		value, errUnquote := strconv.Unquote(quoted)
+		if errUnquote != nil {
+			return nil, errors.Join(fmt.Errorf("invalid value of %s in %s", key, file), errUnquote)
+		}
+
+		values[key] = value
+	}
+
+	return values, scanner.Err()
+}
+
+type k8sOwner struct {
+	deployment  string
+	replicaSet  string
+	statefulSet string
+}
+
+// k8sPodOwner derives the deployment or the stateful set from the pod name, <deployment>-<replica set hash>-<suffix> or <stateful set>-<ordinal>.
+// The pod-template-hash and controller-revision-hash labels, when known, settle which one it is.
+func k8sPodOwner(pod string, labels map[string]string) k8sOwner {
+	owner := k8sOwner{}
+
+	parts := strings.Split(pod, "-")
+	if len(parts) < 2 {
+		return owner
+	}
+
+	last := parts[len(parts)-1]
+	_, isReplicaSetPod := labels["pod-template-hash"]
+	_, isStatefulSetPod := labels["controller-revision-hash"]
+
+	if hash, ok := labels["pod-template-hash"]; ok && len(parts) >= 3 && parts[len(parts)-2] == hash {
+		owner.deployment = strings.Join(parts[:len(parts)-2], "-")
+		owner.replicaSet = strings.Join(parts[:len(parts)-1], "-")
+
+		return owner
+	}
+
+	// the random suffix can be all digits so the <hash>-<suffix> pattern is checked before the ordinal
+	hash := parts[len(parts)-2]
+	if !isStatefulSetPod && len(parts) >= 3 && len(last) == 5 && isK8SNameSuffix(last) && len(hash) >= 6 && len(hash) <= 10 && isK8SNameSuffix(hash) {
+		owner.deployment = strings.Join(parts[:len(parts)-2], "-")
+		owner.replicaSet = strings.Join(parts[:len(parts)-1], "-")
+
+		return owner
+	}
+
+	if _, errOrdinal := strconv.Atoi(last); errOrdinal == nil && !isReplicaSetPod {
+		owner.statefulSet = strings.Join(parts[:len(parts)-1], "-")
+	}
+
+	return owner
+}
+
+func isK8SNameSuffix(value string) bool {
+	for _, r := range value {
+		if !strings.ContainsRune(k8sNameSuffixAlphabet, r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func sortedKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// joinNonEmpty joins the non empty values with the separator
+func joinNonEmpty(separator string, values ...string) string {
+	nonEmpty := []string{}
+
+	for _, value := range values {
+		if value != "" {
+			nonEmpty = append(nonEmpty, value)
+		}
+	}
+
+	return strings.Join(nonEmpty, separator)
+}
@@ -0,0 +1,170 @@
+package cmotel
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+func TestK8SDetector(t *testing.T) {
+	tests := []struct {
+		name           string
+		env            map[string]string
+		files          map[string]string
+		wantAttributes map[attribute.Key]string
+		wantComponents []string
+	}{
+		{
+			name: "environment variables",
+			env: map[string]string{
+				EnvK8SClusterName:    "prod",
+				EnvK8SNamespaceName:  "shop",
+				EnvNodeNameType:      "node-1",
+				EnvPodNameType:       "orders-0",
+				EnvContainerNameType: "app",
+			},
+			wantAttributes: map[attribute.Key]string{
+				"k8s.pod.name":         "orders-0",
+				"k8s.container.name":   "app",
+				"k8s.statefulset.name": "orders",
+			},
+			wantComponents: []string{"prod.node-1", "prod.shop.orders-0"},
+		},
+		{
+			name: "downward API files",
+			env:  map[string]string{EnvK8SClusterName: "prod"},
+			files: map[string]string{
+				"name":        "orders-6b7f9c8d5-x2k4z",
+				"namespace":   "shop",
+				"uid":         "8a0b1c2d\n",
+				"labels":      "app=\"orders\"\npod-template-hash=\"6b7f9c8d5\"\n",
+				"annotations": "team=\"checkout \\\"core\\\"\"\n",
+			},
+			wantAttributes: map[attribute.Key]string{
+				"k8s.namespace.name":        "shop",
+				"k8s.pod.uid":               "8a0b1c2d",
+				"k8s.deployment.name":       "orders",
+				"k8s.replicaset.name":       "orders-6b7f9c8d5",
+				"k8s.pod.label.app":         "orders",
+				"k8s.pod.annotation.team":   `checkout "core"`,
+				"k8s.pod.label.nonexistent": "",
+			},
+			wantComponents: []string{"prod.shop.orders-6b7f9c8d5-x2k4z"},
+		},
+		{
+			name: "environment variables win over files",
+			env:  map[string]string{EnvPodNameType: "payments-7c9d4b6f8b-q8w7r"},
+			files: map[string]string{
+				"name": "ignored-0",
+			},
+			wantAttributes: map[attribute.Key]string{
+				"k8s.pod.name":         "payments-7c9d4b6f8b-q8w7r",
+				"k8s.deployment.name":  "payments",
+				"k8s.statefulset.name": "",
+			},
+			wantComponents: []string{"payments-7c9d4b6f8b-q8w7r"},
+		},
+		{
+			name:           "outside of kubernetes",
+			wantAttributes: map[attribute.Key]string{"k8s.pod.name": ""},
+			wantComponents: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(EnvCmPrefix, "")
+			for _, env := range []string{EnvK8SClusterName, EnvK8SNamespaceName, EnvNodeNameType, EnvPodNameType, EnvPodUIDType, EnvContainerNameType} {
+				t.Setenv(env, tt.env[env])
+			}
+
+			dir := t.TempDir()
+			for name, contents := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			detector, err := NewK8SDetector(WithK8SDownwardAPIPath(dir))
+			if err != nil {
+				t.Fatalf("NewK8SDetector() error = %v", err)
+			}
+
+			res, err := detector.Detect(context.Background())
+			if err != nil {
+				t.Fatalf("Detect() error = %v", err)
+			}
+
+			for key, want := range tt.wantAttributes {
+				got, _ := res.Set().Value(key)
+				if got.AsString() != want {
+					t.Errorf("%s = %q, want %q", key, got.AsString(), want)
+				}
+			}
+
+			components := ParseResourceComponents(res.Attributes())
+			if len(components) != len(tt.wantComponents) {
+				t.Fatalf("components = %v, want %v", components, tt.wantComponents)
+			}
+			for i, component := range components {
+				if component.InternalID != tt.wantComponents[i] || !component.IsContainer {
+					t.Errorf("component %d = %v, want the %s container", i, component, tt.wantComponents[i])
+				}
+			}
+		})
+	}
+}
+
+func TestK8SPodOwner(t *testing.T) {
+	tests := []struct {
+		pod    string
+		labels map[string]string
+		want   k8sOwner
+	}{
+		{pod: "orders-6b7f9c8d5-x2k4z", want: k8sOwner{deployment: "orders", replicaSet: "orders-6b7f9c8d5"}},
+		{pod: "orders-api-6b7f9c8d5-x2k4z", labels: map[string]string{"pod-template-hash": "6b7f9c8d5"}, want: k8sOwner{deployment: "orders-api", replicaSet: "orders-api-6b7f9c8d5"}},
+		{pod: "api-7d4f8b9c6-24579", want: k8sOwner{deployment: "api", replicaSet: "api-7d4f8b9c6"}},
+		{pod: "postgres-12", want: k8sOwner{statefulSet: "postgres"}},
+		{pod: "orders-backend-worker", want: k8sOwner{}},
+		{pod: "standalone", want: k8sOwner{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pod, func(t *testing.T) {
+			if got := k8sPodOwner(tt.pod, tt.labels); got != tt.want {
+				t.Errorf("k8sPodOwner() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestK8SDetectorInvalidFile(t *testing.T) {
+	t.Setenv(EnvK8SClusterName, "prod")
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "labels"), []byte("app=orders\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "name"), []byte("orders-0\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	detector, err := NewK8SDetector(WithK8SDownwardAPIPath(dir))
+	if err != nil {
+		t.Fatalf("NewK8SDetector() error = %v", err)
+	}
+
+	res, err := detector.Detect(context.Background())
+	if !errors.Is(err, resource.ErrPartialResource) {
+		t.Errorf("Detect() error = %v, want a partial resource error for the unquoted label", err)
+	}
+
+	for key, want := range map[attribute.Key]string{"k8s.cluster.name": "prod", "k8s.pod.name": "orders-0"} {
+		if value, _ := res.Set().Value(key); value.AsString() != want {
+			t.Errorf("%s = %q, want %q kept despite the unreadable labels", key, value.AsString(), want)
+		}
+	}
+}
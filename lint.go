@@ -0,0 +1,111 @@
+package cmotel
+
+import (
+	"fmt"
+	"sync"
+)
+
+// LintIssueKind the kind of instrumentation problem found by the linter, see WithLint
+type LintIssueKind string
+
+const (
+	// LintUnknownParent a span was created with a parent name that does not exist, in which case no span is created
+	LintUnknownParent LintIssueKind = "unknown_parent"
+
+	// LintUnknownRelationshipSource a relationship was drawn from a span that does not exist, in which case the relationship is lost
+	LintUnknownRelationshipSource LintIssueKind = "unknown_relationship_source"
+
+	// LintDuplicateSpanName a span was created with the name of an existing span, which is no longer reachable by name
+	LintDuplicateSpanName LintIssueKind = "duplicate_span_name"
+
+	// LintSpanEndedTwice a span was ended after it had already ended
+	LintSpanEndedTwice LintIssueKind = "span_ended_twice"
+
+	// LintComponentOnEndedSpan a component was added to a span that had already ended, in which case it is not exported
+	LintComponentOnEndedSpan LintIssueKind = "component_on_ended_span"
+
+	// LintUnendedSpan a local span was still recording when the report was requested, e.g. at the end of a request
+	LintUnendedSpan LintIssueKind = "unended_span"
+)
+
+// LintIssue an instrumentation problem
+type LintIssue struct {
+	Kind   LintIssueKind `json:"kind"`
+	Span   string        `json:"span"`
+	Detail string        `json:"detail"`
+}
+
+func (i LintIssue) String() string {
+	return fmt.Sprintf("%s: %s: %s", i.Kind, i.Span, i.Detail)
+}
+
+// LintReporter is called for every issue as soon as it is found
+type LintReporter = func(issue LintIssue)
+
+// WithLint enables the strict mode which records instrumentation problems, reports them to the reporter, which may be nil, and returns them from ExtendedCMOtel.LintReport
+func WithLint(reporter LintReporter) Option {
+	return func(cm *cmOtel) {
+		cm.linter = &linter{
+			reporter: reporter,
+			issues:   []LintIssue{},
+			unended:  map[string]bool{},
+		}
+	}
+}
+
+type linter struct {
+	mu       sync.Mutex
+	reporter LintReporter
+	issues   []LintIssue
+
+	// unended the spans already reported as unended so that every report does not repeat them
+	unended map[string]bool
+}
+
+// lint records an issue when the strict mode is enabled
+func (cm *cmOtel) lint(kind LintIssueKind, span string, format string, args ...interface{}) {
+	if cm.linter == nil {
+		return
+	}
+
+	issue := LintIssue{
+		Kind:   kind,
+		Span:   span,
+		Detail: fmt.Sprintf(format, args...),
+	}
+
+	cm.linter.mu.Lock()
+	cm.linter.issues = append(cm.linter.issues, issue)
+	cm.linter.mu.Unlock()
+
+	if cm.linter.reporter != nil {
+		cm.linter.reporter(issue)
+	}
+}
+
+// LintReport checks that every local span has ended and returns all the issues found so far. It returns nothing unless WithLint is used.
+func (cm *cmOtel) LintReport() []LintIssue {
+	if cm.linter == nil {
+		return []LintIssue{}
+	}
+
+	for _, info := range cm.registry.snapshot() {
+		if info.Remote || info.Ended || !info.Recording {
+			continue
+		}
+
+		cm.linter.mu.Lock()
+		reported := cm.linter.unended[info.Name]
+		cm.linter.unended[info.Name] = true
+		cm.linter.mu.Unlock()
+
+		if !reported {
+			cm.lint(LintUnendedSpan, info.Name, "the span has not been ended")
+		}
+	}
+
+	cm.linter.mu.Lock()
+	defer cm.linter.mu.Unlock()
+
+	return append([]LintIssue{}, cm.linter.issues...)
+}
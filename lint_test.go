@@ -0,0 +1,80 @@
+package cmotel
+
+import (
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		name       string
+		instrument func(cm CMOtel)
+		want       []LintIssueKind
+	}{
+		{
+			name: "no issues",
+			instrument: func(cm CMOtel) {
+				cm.NewSpan(WithSpanName("handler"))
+				cm.EndSpan("handler")
+			},
+			want: []LintIssueKind{},
+		},
+		{
+			name: "unknown parent and relationship source",
+			instrument: func(cm CMOtel) {
+				cm.NewSpan(WithSpanName("query"), WithParentSpanName("handler"))
+				cm.NewSpan(WithSpanName("publish"), WithSpanInternalRelationshipFrom("handler"))
+				cm.EndSpan("publish")
+			},
+			want: []LintIssueKind{LintUnknownParent, LintUnknownRelationshipSource},
+		},
+		{
+			name: "duplicate name and span ended twice",
+			instrument: func(cm CMOtel) {
+				span, _ := cm.NewSpan(WithSpanName("handler"))
+				span.End()
+				cm.NewSpan(WithSpanName("handler"))
+				cm.EndSpan("handler")
+				cm.EndSpan("handler")
+			},
+			want: []LintIssueKind{LintDuplicateSpanName, LintSpanEndedTwice},
+		},
+		{
+			name: "component on ended span and unended span",
+			instrument: func(cm CMOtel) {
+				cm.NewSpan(WithSpanName("handler"))
+				cm.EndSpan("handler")
+				cm.AddComponent(WithAddComponentSpanName("handler"), WithAddComponentType(ComponentTypeHTTPRestGeneric))
+				cm.NewSpan(WithSpanName("query"))
+			},
+			want: []LintIssueKind{LintComponentOnEndedSpan, LintUnendedSpan},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reported := []LintIssue{}
+			provider := sdktrace.NewTracerProvider()
+			cm := New(provider.Tracer("test"), "orders", WithLint(func(issue LintIssue) {
+				reported = append(reported, issue)
+			}))
+
+			tt.instrument(cm)
+
+			got := cm.LintReport()
+			if len(got) != len(tt.want) {
+				t.Fatalf("LintReport() = %v, want %v", got, tt.want)
+			}
+
+			for i, issue := range got {
+				if issue.Kind != tt.want[i] {
+					t.Errorf("LintReport()[%d] = %v, want %s", i, issue, tt.want[i])
+				}
+			}
+
+			if again := cm.LintReport(); len(again) != len(got) || len(reported) != len(got) {
+				t.Errorf("LintReport() again = %v and reported %v, want the same %d issues", again, reported, len(got))
+			}
+		})
+	}
+}
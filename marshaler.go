@@ -5,12 +5,182 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SpanMapEncoding the encoding used for the span map header
+type SpanMapEncoding string
+
+const (
+	// SpanMapEncodingJSON the legacy encoding, a plain JSON object of internal name to trace header
+	SpanMapEncodingJSON SpanMapEncoding = "json"
+
+	// SpanMapEncodingV1 the compact binary encoding that deduplicates the trace IDs. It is prefixed by SpanMapVersionPrefixV1.
+	SpanMapEncodingV1 SpanMapEncoding = "v1"
+)
+
+// SpanMapTruncatePolicy decides what happens when the encoded span map exceeds the maximum size
+type SpanMapTruncatePolicy string
+
+const (
+	// SpanMapTruncateDropLast sorts the spans by their internal name and drops the last ones until the header fits
+	SpanMapTruncateDropLast SpanMapTruncatePolicy = "drop_last"
+
+	// SpanMapTruncateError returns ErrSpanMapTooLarge instead of dropping any span
+	SpanMapTruncateError SpanMapTruncatePolicy = "error"
 )
 
+// ErrSpanMapTooLarge is returned when the span map does not fit in the configured maximum size
+var ErrSpanMapTooLarge = errors.New("the encoded span map exceeds the maximum size")
+
+type spanMapOpts struct {
+	format         TraceHeaderFormat
+	encoding       SpanMapEncoding
+	compress       bool
+	maxSize        int
+	truncatePolicy SpanMapTruncatePolicy
+}
+
+// SpanMapOption the function parameter for marshaling a span map
+type SpanMapOption = func(opt *spanMapOpts) error
+
+// WithSpanMapFormat converts every span of the map to the provided format, i.e. W3C traceparent, B3 single header or Jaeger uber-trace-id. It defaults to leaving the values untouched.
+// It only applies to the JSON encoding since the binary encoding stores the span contexts themselves.
+func WithSpanMapFormat(format TraceHeaderFormat) SpanMapOption {
+	return func(opt *spanMapOpts) error {
+		if format == TraceHeaderFormatB3Multi {
+			return errors.New("the b3 multi header format cannot be used in a span map")
+		}
+
+		opt.format = format
+
+		return nil
+	}
+}
+
+// WithSpanMapEncoding the encoding of the header. It defaults to SpanMapEncodingJSON.
+func WithSpanMapEncoding(encoding SpanMapEncoding) SpanMapOption {
+	return func(opt *spanMapOpts) error {
+		if encoding != SpanMapEncodingJSON && encoding != SpanMapEncodingV1 {
+			return fmt.Errorf("unknown span map encoding %s", encoding)
+		}
+
+		opt.encoding = encoding
+
+		return nil
+	}
+}
+
+// WithSpanMapCompression compresses the binary encoding with deflate. It has no effect on the JSON encoding.
+func WithSpanMapCompression(compress bool) SpanMapOption {
+	return func(opt *spanMapOpts) error {
+		opt.compress = compress
+
+		return nil
+	}
+}
+
+// WithSpanMapMaxSize the maximum length in bytes of the encoded header. Zero, the default, means no limit.
+func WithSpanMapMaxSize(maxSize int) SpanMapOption {
+	return func(opt *spanMapOpts) error {
+		if maxSize < 0 {
+			return errors.New("the maximum size must not be negative")
+		}
+
+		opt.maxSize = maxSize
+
+		return nil
+	}
+}
+
+// WithSpanMapTruncatePolicy what to do when the header exceeds the maximum size. It defaults to SpanMapTruncateDropLast.
+func WithSpanMapTruncatePolicy(policy SpanMapTruncatePolicy) SpanMapOption {
+	return func(opt *spanMapOpts) error {
+		if policy != SpanMapTruncateDropLast && policy != SpanMapTruncateError {
+			return fmt.Errorf("unknown truncate policy %s", policy)
+		}
+
+		opt.truncatePolicy = policy
+
+		return nil
+	}
+}
+
 // MarshalSpanMap marshals into a string the map of spans so that they can be passed as a header
-func MarshalSpanMap(spans map[string]string) (string, error) {
+func MarshalSpanMap(spans map[string]string, opts ...SpanMapOption) (string, error) {
+	options := &spanMapOpts{
+		format:         "",
+		encoding:       SpanMapEncodingJSON,
+		compress:       false,
+		maxSize:        0,
+		truncatePolicy: SpanMapTruncateDropLast,
+	}
+
+	for _, opt := range opts {
+		if err := opt(options); err != nil {
+			return "", err
+		}
+	}
+
+	if options.format != "" && options.encoding == SpanMapEncodingJSON {
+		converted := map[string]string{}
+
+		for name, value := range spans {
+			ctx, errParse := ParseTraceHeader(value)
+			if errParse != nil {
+				return "", errors.Join(fmt.Errorf("could not parse the span %s", name), errParse)
+			}
+
+			formatted, errFormat := FormatTraceHeader(trace.SpanContextFromContext(ctx), options.format)
+			if errFormat != nil {
+				return "", errFormat
+			}
+
+			converted[name] = formatted
+		}
+
+		spans = converted
+	}
+
+	names := make([]string, 0, len(spans))
+	for name := range spans {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for {
+		encoded, errEncode := encodeSpanMap(spans, names, options)
+		if errEncode != nil {
+			return "", errEncode
+		}
+
+		if options.maxSize == 0 || len(encoded) <= options.maxSize {
+			return encoded, nil
+		}
+
+		if options.truncatePolicy == SpanMapTruncateError || len(names) == 0 {
+			return "", fmt.Errorf("%w: %d bytes while the maximum is %d", ErrSpanMapTooLarge, len(encoded), options.maxSize)
+		}
+
+		names = names[:len(names)-1]
+	}
+}
+
+func encodeSpanMap(spans map[string]string, names []string, options *spanMapOpts) (string, error) {
+	if options.encoding == SpanMapEncodingV1 {
+		return encodeSpanMapV1(spans, names, options.compress)
+	}
+
+	selected := make(map[string]string, len(names))
+	for _, name := range names {
+		selected[name] = spans[name]
+	}
+
 	// Encoding the map
-	marshaled, err := json.Marshal(spans)
+	marshaled, err := json.Marshal(selected)
 	if err != nil {
 		return "", err
 	}
@@ -18,8 +188,12 @@ func MarshalSpanMap(spans map[string]string) (string, error) {
 	return string(marshaled), nil
 }
 
-// UnmarshalToSpanMap unmarshal the specified string to a map
+// UnmarshalToSpanMap unmarshal the specified string to a map. It accepts both the versioned binary encoding and the legacy JSON one.
 func UnmarshalToSpanMap(span string) (map[string]string, error) {
+	if strings.HasPrefix(span, SpanMapVersionPrefixV1) {
+		return decodeSpanMapV1(strings.TrimPrefix(span, SpanMapVersionPrefixV1))
+	}
+
 	b := new(bytes.Buffer)
 	countWrite, errWrite := b.WriteString(span)
 	if countWrite != len(span) {
@@ -0,0 +1,104 @@
+package cmotel
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMarshalSpanMap(t *testing.T) {
+	spans := map[string]string{}
+	for i := 0; i < 20; i++ {
+		spans[fmt.Sprintf("cluster.namespace.service@span-%02d", i)] = fmt.Sprintf("00-12345678901234567890123456789012-%016x-01", i+1)
+	}
+
+	tests := []struct {
+		name       string
+		opts       []SpanMapOption
+		wantPrefix string
+		wantSpans  int
+		wantErr    error
+	}{
+		{
+			name:       "legacy json",
+			opts:       []SpanMapOption{},
+			wantPrefix: "{",
+			wantSpans:  20,
+		},
+		{
+			name:       "v1",
+			opts:       []SpanMapOption{WithSpanMapEncoding(SpanMapEncodingV1)},
+			wantPrefix: SpanMapVersionPrefixV1,
+			wantSpans:  20,
+		},
+		{
+			name:       "v1 compressed",
+			opts:       []SpanMapOption{WithSpanMapEncoding(SpanMapEncodingV1), WithSpanMapCompression(true)},
+			wantPrefix: SpanMapVersionPrefixV1,
+			wantSpans:  20,
+		},
+		{
+			name:       "v1 truncated",
+			opts:       []SpanMapOption{WithSpanMapEncoding(SpanMapEncodingV1), WithSpanMapMaxSize(300)},
+			wantPrefix: SpanMapVersionPrefixV1,
+			wantSpans:  4,
+		},
+		{
+			name:    "v1 too large",
+			opts:    []SpanMapOption{WithSpanMapEncoding(SpanMapEncodingV1), WithSpanMapMaxSize(300), WithSpanMapTruncatePolicy(SpanMapTruncateError)},
+			wantErr: ErrSpanMapTooLarge,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MarshalSpanMap(spans, tt.opts...)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("MarshalSpanMap() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if !strings.HasPrefix(got, tt.wantPrefix) {
+				t.Errorf("MarshalSpanMap() = %v, want prefix %v", got, tt.wantPrefix)
+			}
+
+			decoded, err := UnmarshalToSpanMap(got)
+			if err != nil {
+				t.Fatalf("UnmarshalToSpanMap() error = %v", err)
+			}
+			if len(decoded) != tt.wantSpans {
+				t.Fatalf("UnmarshalToSpanMap() returned %d spans, want %d", len(decoded), tt.wantSpans)
+			}
+			for name, traceparent := range decoded {
+				if spans[name] != traceparent {
+					t.Errorf("UnmarshalToSpanMap()[%s] = %v, want %v", name, traceparent, spans[name])
+				}
+			}
+		})
+	}
+}
+
+func TestMarshalSpanMapTruncationIsDeterministic(t *testing.T) {
+	spans := map[string]string{
+		"b": "00-12345678901234567890123456789012-0000000000000002-01",
+		"a": "00-12345678901234567890123456789012-0000000000000001-01",
+		"c": "00-12345678901234567890123456789012-0000000000000003-01",
+	}
+
+	got, err := MarshalSpanMap(spans, WithSpanMapMaxSize(130))
+	if err != nil {
+		t.Fatalf("MarshalSpanMap() error = %v", err)
+	}
+
+	decoded, err := UnmarshalToSpanMap(got)
+	if err != nil {
+		t.Fatalf("UnmarshalToSpanMap() error = %v", err)
+	}
+
+	want := map[string]string{"a": spans["a"], "b": spans["b"]}
+	if !reflect.DeepEqual(decoded, want) {
+		t.Errorf("UnmarshalToSpanMap() = %v, want %v", decoded, want)
+	}
+}
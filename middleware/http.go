@@ -2,34 +2,276 @@ package middleware
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"strings"
+	"time"
 
 	cmotel "github.com/coordimap/cm-otel-go"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// DefaultServerSpanName the name of the span created for requests whose trace context is not trusted when WithServerSpan is not used
+const DefaultServerSpanName = "http-server"
+
+type middlewareOpts struct {
+	cmOtelOpts       []cmotel.Option
+	verifier         *cmotel.SpanMapVerifier
+	unverifiedPolicy cmotel.UnverifiedSpanMapPolicy
+	trust            *trustOpts
+	serverSpanName   string
+	lintReporter     LintReporter
+}
+
+// LintReporter receives the instrumentation issues of a request once the next handler returns, see WithLint
+type LintReporter = func(r *http.Request, issues []cmotel.LintIssue)
+
+// Option the function parameter for configuring the Coordimap middleware
+type Option = func(opt *middlewareOpts) error
+
+// WithTraceHeaderFormats the formats that the cmOtel object of every request uses to propagate spans to downstream services, see cmotel.ExtendedCMOtel.InjectSpanHeaders
+func WithTraceHeaderFormats(formats ...cmotel.TraceHeaderFormat) Option {
+	return func(opt *middlewareOpts) error {
+		for _, format := range formats {
+			switch format {
+			case cmotel.TraceHeaderFormatW3C, cmotel.TraceHeaderFormatB3, cmotel.TraceHeaderFormatB3Multi, cmotel.TraceHeaderFormatJaeger:
+			default:
+				return fmt.Errorf("unknown trace header format %s", format)
+			}
+		}
+
+		opt.cmOtelOpts = append(opt.cmOtelOpts, cmotel.WithTraceHeaderFormats(formats...))
+
+		return nil
+	}
+}
+
+// WithCMOtelOptions extra options used when creating the cmOtel object of every request
+func WithCMOtelOptions(opts ...cmotel.Option) Option {
+	return func(opt *middlewareOpts) error {
+		opt.cmOtelOpts = append(opt.cmOtelOpts, opts...)
+
+		return nil
+	}
+}
+
+// WithSpanMapVerifier verifies the signature of the incoming span map header and applies the policy to the spans of span maps that fail verification
+func WithSpanMapVerifier(verifier *cmotel.SpanMapVerifier, policy cmotel.UnverifiedSpanMapPolicy) Option {
+	return func(opt *middlewareOpts) error {
+		if verifier == nil {
+			return errors.New("the verifier must not be nil")
+		}
+
+		if policy != cmotel.UnverifiedSpanMapDrop && policy != cmotel.UnverifiedSpanMapTag {
+			return fmt.Errorf("unknown unverified span map policy %s", policy)
+		}
+
+		opt.verifier = verifier
+		opt.unverifiedPolicy = policy
+
+		return nil
+	}
+}
+
+// WithSpanMapSigning signs the span map header that the cmOtel object of every request sets on outgoing calls, see cmotel.WithSpanMapSigning
+func WithSpanMapSigning(key cmotel.SpanMapKey, ttl time.Duration) Option {
+	return func(opt *middlewareOpts) error {
+		opt.cmOtelOpts = append(opt.cmOtelOpts, cmotel.WithSpanMapSigning(key, ttl))
+
+		return nil
+	}
+}
+
+// WithServerSpan creates a span with the provided name for every request, parented to the incoming trace context when trusted. The span is ended once the next handler returns.
+func WithServerSpan(name string) Option {
+	return func(opt *middlewareOpts) error {
+		if name == "" || strings.Contains(name, "@") {
+			return errors.New("the server span name must not be empty nor contain @")
+		}
+
+		opt.serverSpanName = name
+
+		return nil
+	}
+}
+
+// WithLint enables the strict mode of the cmOtel object of every request, see cmotel.WithLint, and reports a summary of the issues once the next handler returns.
+// The issues are reported to issueReporter as soon as they are found and the summary to summaryReporter, either of which may be nil to only get the other report.
+func WithLint(issueReporter cmotel.LintReporter, summaryReporter LintReporter) Option {
+	return func(opt *middlewareOpts) error {
+		opt.cmOtelOpts = append(opt.cmOtelOpts, cmotel.WithLint(issueReporter))
+		opt.lintReporter = summaryReporter
+
+		return nil
+	}
+}
+
 // CoordimapMiddleware initiates the cmOtel object and creates the first span that holds information about the endpoint being called.
 func CoordimapMiddleware(next http.Handler) http.Handler {
+	return newCoordimapHandler(next, newMiddlewareOpts())
+}
+
+// NewCoordimapMiddleware returns a configurable version of CoordimapMiddleware
+func NewCoordimapMiddleware(opts ...Option) (func(http.Handler) http.Handler, error) {
+	options := newMiddlewareOpts()
+
+	for _, opt := range opts {
+		if err := opt(options); err != nil {
+			return nil, errors.Join(errors.New("could not configure the coordimap middleware"), err)
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return newCoordimapHandler(next, options)
+	}, nil
+}
+
+func newMiddlewareOpts() *middlewareOpts {
+	return &middlewareOpts{
+		cmOtelOpts:       []cmotel.Option{},
+		verifier:         nil,
+		unverifiedPolicy: cmotel.UnverifiedSpanMapDrop,
+		trust: &trustOpts{
+			cidrs:      []*net.IPNet{},
+			peers:      map[string]bool{},
+			predicates: []TrustPredicate{},
+		},
+		serverSpanName: "",
+		lintReporter:   nil,
+	}
+}
+
+func newCoordimapHandler(next http.Handler, options *middlewareOpts) http.Handler {
 	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
-		var cmOtel cmotel.CMOtel
+		var cmOtel cmotel.ExtendedCMOtel
 
 		prefix := cmotel.GetEnvWithPrefix("", cmotel.EnvCmPrefix)
 		cmOtel = cmotel.New(
 			otel.Tracer(cmotel.GetEnvWithPrefix(prefix, cmotel.EnvTracerName)),
 			cmotel.GetEnvWithPrefix(prefix, cmotel.EnvServiceName),
+			options.cmOtelOpts...,
 		)
 
-		traceParentsMap, errTraceParentsMap := cmotel.UnmarshalToSpanMap(r.Header.Get(cmotel.EnvTraceParentsMapHeaderName))
-		if errTraceParentsMap == nil {
+		// registered first so that it runs after the server span has ended
+		if options.lintReporter != nil {
+			defer func() {
+				if issues := cmOtel.LintReport(); len(issues) != 0 {
+					options.lintReporter(r, issues)
+				}
+			}()
+		}
+
+		untrustedReason := options.trust.evaluate(r)
+		spanMapHeader := r.Header.Get(cmotel.EnvTraceParentsMapHeaderName)
+		verified := true
+
+		if untrustedReason != "" {
+			spanMapHeader = ""
+		}
+
+		if options.verifier != nil && spanMapHeader != "" {
+			if errVerify := options.verifier.Verify(spanMapHeader, r.Header.Get(cmotel.EnvTraceParentsMapSignatureHeaderName)); errVerify != nil {
+				fmt.Printf("could not verify the span map because %s", errVerify.Error())
+				verified = false
+			}
+		}
+
+		traceParentsMap, errTraceParentsMap := cmotel.UnmarshalToSpanMap(spanMapHeader)
+		if errTraceParentsMap == nil && (verified || options.unverifiedPolicy == cmotel.UnverifiedSpanMapTag) {
 			for key, val := range traceParentsMap {
-				if errSet := cmOtel.SetSpanFromTraceparent(key, val); errSet != nil {
+				if errSet := cmOtel.SetSpanFromTraceHeader(key, val); errSet != nil {
 					fmt.Printf("could not set span %s from traceparent because %s", key, errSet.Error())
+					continue
+				}
+
+				if !verified {
+					if errMark := cmOtel.MarkSpanUnverified(key); errMark != nil {
+						fmt.Printf("could not mark span %s as unverified because %s", key, errMark.Error())
+					}
+				}
+			}
+		}
+
+		ctx := r.Context()
+		serverSpanName := options.serverSpanName
+
+		source, hasSource := cmotel.ServiceIdentity{}, false
+		if untrustedReason == "" {
+			ctx = cmotel.ExtractServiceBaggage(ctx, r.Header)
+			source, hasSource = cmotel.ServiceIdentityFromContext(ctx)
+		}
+
+		if untrustedReason != "" || serverSpanName != "" {
+			if serverSpanName == "" {
+				serverSpanName = DefaultServerSpanName
+			}
+
+			spanOpts := []cmotel.SpanOption{
+				cmotel.WithSpanName(serverSpanName),
+				cmotel.WithSpanStartOptions(trace.WithSpanKind(trace.SpanKindServer)),
+			}
+
+			incoming := incomingSpanContext(r)
+			if untrustedReason != "" {
+				startOpts := []trace.SpanStartOption{
+					trace.WithNewRoot(),
+					trace.WithAttributes(attribute.String(cmotel.SpanAttrUntrustedReason, untrustedReason)),
+				}
+
+				if incoming.IsValid() {
+					startOpts = append(startOpts, trace.WithLinks(trace.Link{SpanContext: incoming}))
 				}
+
+				spanOpts = append(spanOpts, cmotel.WithSpanStartOptions(startOpts...))
+			} else if incoming.IsValid() && !trace.SpanContextFromContext(ctx).IsValid() {
+				ctx = trace.ContextWithRemoteSpanContext(ctx, incoming)
 			}
+
+			span, spanCtx := cmOtel.NewSpan(append(spanOpts, cmotel.WithSpanContext(ctx))...)
+			if span != nil {
+				ctx = spanCtx
+				defer cmOtel.EndSpan(serverSpanName)
+			}
+		}
+
+		// the identity of the caller is only recorded on a server span enabled through WithServerSpan, so that calls do not add nodes to the map unless asked to
+		if hasSource && serverSpanName != "" {
+			recordSource(cmOtel, serverSpanName, source)
 		}
 
-		ctx := context.WithValue(r.Context(), cmotel.ContextKey, cmOtel)
+		ctx = context.WithValue(ctx, cmotel.ContextKey, cmOtel)
 		next.ServeHTTP(rw, r.WithContext(ctx))
 	})
 }
+
+// recordSource records the identity of the calling service and a relationship from it on the server span
+func recordSource(cmOtel cmotel.ExtendedCMOtel, serverSpanName string, source cmotel.ServiceIdentity) {
+	serverSpan, errSpan := cmOtel.Span(serverSpanName)
+	if errSpan != nil {
+		fmt.Printf("could not record the calling service because %s", errSpan.Error())
+		return
+	}
+
+	attributes := []attribute.KeyValue{attribute.String(cmotel.SpanAttrSourceService, source.Service)}
+	for key, value := range map[string]string{
+		cmotel.SpanAttrSourceCluster:   source.Cluster,
+		cmotel.SpanAttrSourceNamespace: source.Namespace,
+		cmotel.SpanAttrSourcePod:       source.Pod,
+	} {
+		if value != "" {
+			attributes = append(attributes, attribute.String(key, value))
+		}
+	}
+	serverSpan.Span().SetAttributes(attributes...)
+
+	// spans that were not sampled do not record so there is nothing to relate
+	if serverSpan.Span().IsRecording() {
+		if errRelate := serverSpan.RelateFromService(source.Service); errRelate != nil {
+			fmt.Printf("could not relate the calling service because %s", errRelate.Error())
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	cmotel "github.com/coordimap/cm-otel-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+const (
+	testTraceparent = "00-12345678901234567890123456789012-3456789012345678-01"
+	testSpanMap     = `{"cluster.namespace.caller@client":"00-12345678901234567890123456789012-3456789012345678-01"}`
+)
+
+func TestCoordimapMiddlewareTrust(t *testing.T) {
+	tests := []struct {
+		name          string
+		remoteAddr    string
+		wantRemote    bool
+		wantNewRoot   bool
+		wantUntrusted bool
+	}{
+		{
+			name:       "trusted network",
+			remoteAddr: "10.1.2.3:41234",
+			wantRemote: true,
+		},
+		{
+			name:          "untrusted network",
+			remoteAddr:    "203.0.113.7:41234",
+			wantNewRoot:   true,
+			wantUntrusted: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := tracetest.NewSpanRecorder()
+			otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+			middleware, err := NewCoordimapMiddleware(WithTrustedCIDRs("10.0.0.0/8"), WithServerSpan("server"))
+			if err != nil {
+				t.Fatalf("NewCoordimapMiddleware() error = %v", err)
+			}
+
+			gotRemote := false
+			handler := middleware(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				gotRemote = r.Context().Value(cmotel.ContextKey).(cmotel.CMOtel).SpanExists("cluster.namespace.caller@client")
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			req.Header.Set(cmotel.HeaderTraceparent, testTraceparent)
+			req.Header.Set(cmotel.EnvTraceParentsMapHeaderName, testSpanMap)
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if gotRemote != tt.wantRemote {
+				t.Errorf("remote span registered = %v, want %v", gotRemote, tt.wantRemote)
+			}
+
+			ended := recorder.Ended()
+			if len(ended) != 1 {
+				t.Fatalf("got %d ended spans, want 1", len(ended))
+			}
+
+			span := ended[0]
+			if isRoot := !span.Parent().IsValid(); isRoot != tt.wantNewRoot {
+				t.Errorf("span is root = %v, want %v", isRoot, tt.wantNewRoot)
+			}
+
+			hasReason := false
+			for _, attr := range span.Attributes() {
+				if attr.Key == cmotel.SpanAttrUntrustedReason {
+					hasReason = true
+				}
+			}
+			if hasReason != tt.wantUntrusted {
+				t.Errorf("span has untrusted reason = %v, want %v", hasReason, tt.wantUntrusted)
+			}
+
+			if tt.wantUntrusted && (len(span.Links()) != 1 || span.Links()[0].SpanContext.TraceID().String() != "12345678901234567890123456789012") {
+				t.Errorf("span links = %v, want a link to the incoming trace", span.Links())
+			}
+		})
+	}
+}
+
+func TestCoordimapMiddlewareSourceService(t *testing.T) {
+	tests := []struct {
+		name           string
+		opts           []Option
+		callerOpts     []cmotel.Option
+		wantServerSpan bool
+		wantPod        string
+	}{
+		{
+			name:           "server span",
+			opts:           []Option{WithServerSpan("server")},
+			callerOpts:     []cmotel.Option{cmotel.WithPodBaggage()},
+			wantServerSpan: true,
+			wantPod:        "caller-0",
+		},
+		{
+			name:           "pod not sent by default",
+			opts:           []Option{WithServerSpan("server")},
+			callerOpts:     []cmotel.Option{},
+			wantServerSpan: true,
+			wantPod:        "",
+		},
+		{
+			name:           "default middleware",
+			opts:           []Option{},
+			callerOpts:     []cmotel.Option{cmotel.WithPodBaggage()},
+			wantServerSpan: false,
+			wantPod:        "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(cmotel.EnvServiceNamePrefix, "cluster.namespace")
+			t.Setenv(cmotel.EnvK8SClusterName, "prod")
+			t.Setenv(cmotel.EnvPodNameType, "caller-0")
+
+			recorder := tracetest.NewSpanRecorder()
+			provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+			otel.SetTracerProvider(provider)
+
+			caller := cmotel.New(provider.Tracer("caller"), "caller", tt.callerOpts...)
+			span, _ := caller.NewSpan(cmotel.WithSpanName("client"))
+			defer span.End()
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if err := caller.InjectSpanHeaders(req.Header, []string{"client"}); err != nil {
+				t.Fatalf("InjectSpanHeaders() error = %v", err)
+			}
+			req.Header.Del(cmotel.EnvTraceParentsMapHeaderName)
+
+			middleware, err := NewCoordimapMiddleware(tt.opts...)
+			if err != nil {
+				t.Fatalf("NewCoordimapMiddleware() error = %v", err)
+			}
+			middleware(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {})).ServeHTTP(httptest.NewRecorder(), req)
+
+			ended := recorder.Ended()
+			if !tt.wantServerSpan {
+				if len(ended) != 0 {
+					t.Errorf("got %d ended spans, want none without a server span", len(ended))
+				}
+
+				return
+			}
+			if len(ended) != 1 {
+				t.Fatalf("got %d ended spans, want 1", len(ended))
+			}
+
+			got := map[attribute.Key]string{}
+			relationships := []string{}
+			for _, attr := range ended[0].Attributes() {
+				switch attr.Key {
+				case cmotel.SpanAttrRelationships:
+					relationships = attr.Value.AsStringSlice()
+				default:
+					got[attr.Key] = attr.Value.Emit()
+				}
+			}
+
+			want := map[attribute.Key]string{
+				cmotel.SpanAttrSourceService: "cluster.namespace.caller",
+				cmotel.SpanAttrSourceCluster: "prod",
+				cmotel.SpanAttrSourcePod:     tt.wantPod,
+			}
+			for key, value := range want {
+				if got[key] != value {
+					t.Errorf("%s = %q, want %q", key, got[key], value)
+				}
+			}
+
+			wantRelationship := cmotel.FormatRelationship("cluster.namespace.caller", ended[0].Name())
+			if len(relationships) != 1 || relationships[0] != wantRelationship {
+				t.Errorf("relationships = %v, want [%s]", relationships, wantRelationship)
+			}
+			if ended[0].Parent().SpanID() != span.SpanContext().SpanID() {
+				t.Errorf("server span parent = %v, want %v", ended[0].Parent().SpanID(), span.SpanContext().SpanID())
+			}
+		})
+	}
+}
+
+func TestCoordimapMiddlewareLint(t *testing.T) {
+	otel.SetTracerProvider(sdktrace.NewTracerProvider())
+
+	issues := []cmotel.LintIssue{}
+	middleware, err := NewCoordimapMiddleware(WithServerSpan("server"), WithLint(nil, func(r *http.Request, reported []cmotel.LintIssue) {
+		issues = append(issues, reported...)
+	}))
+	if err != nil {
+		t.Fatalf("NewCoordimapMiddleware() error = %v", err)
+	}
+
+	middleware(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		cm := r.Context().Value(cmotel.ContextKey).(cmotel.CMOtel)
+		cm.NewSpan(cmotel.WithSpanName("query"), cmotel.WithParentSpanName("server"))
+	})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if len(issues) != 1 || issues[0].Kind != cmotel.LintUnendedSpan || issues[0].Span != "query" {
+		t.Errorf("issues = %v, want the query span reported as unended", issues)
+	}
+}
@@ -0,0 +1,166 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	cmotel "github.com/coordimap/cm-otel-go"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type trustOpts struct {
+	cidrs      []*net.IPNet
+	peers      map[string]bool
+	predicates []TrustPredicate
+}
+
+// TrustPredicate decides whether the incoming trace context of a request can be trusted. It returns nil when trusted, otherwise an error describing why not.
+type TrustPredicate = func(r *http.Request) error
+
+// WithTrustedCIDRs trusts the incoming span map and traceparent of requests whose remote address is in one of the networks.
+// The address is taken from the connection, http.Request.RemoteAddr, and never from forwarding headers.
+func WithTrustedCIDRs(cidrs ...string) Option {
+	return func(opt *middlewareOpts) error {
+		for _, cidr := range cidrs {
+			_, network, errParse := net.ParseCIDR(cidr)
+			if errParse != nil {
+				return errors.Join(fmt.Errorf("could not parse the cidr %s", cidr), errParse)
+			}
+
+			opt.trust.cidrs = append(opt.trust.cidrs, network)
+		}
+
+		return nil
+	}
+}
+
+// WithTrustedPeers trusts the incoming span map and traceparent of requests whose verified mTLS client certificate has one of the identities as its common name, DNS or URI SAN, e.g. a SPIFFE ID.
+func WithTrustedPeers(identities ...string) Option {
+	return func(opt *middlewareOpts) error {
+		for _, identity := range identities {
+			if identity == "" {
+				return errors.New("the peer identity must not be empty")
+			}
+
+			opt.trust.peers[identity] = true
+		}
+
+		return nil
+	}
+}
+
+// WithTrustPredicate trusts the incoming span map and traceparent of requests for which the predicate returns nil
+func WithTrustPredicate(predicate TrustPredicate) Option {
+	return func(opt *middlewareOpts) error {
+		if predicate == nil {
+			return errors.New("the trust predicate must not be nil")
+		}
+
+		opt.trust.predicates = append(opt.trust.predicates, predicate)
+
+		return nil
+	}
+}
+
+func (t *trustOpts) configured() bool {
+	return len(t.cidrs) != 0 || len(t.peers) != 0 || len(t.predicates) != 0
+}
+
+// evaluate returns an empty reason when the request satisfies any of the configured trust rules, otherwise the reasons of all of them
+func (t *trustOpts) evaluate(r *http.Request) string {
+	if !t.configured() {
+		return ""
+	}
+
+	reasons := []string{}
+
+	if len(t.cidrs) != 0 {
+		host, _, errSplit := net.SplitHostPort(r.RemoteAddr)
+		if errSplit != nil {
+			host = r.RemoteAddr
+		}
+
+		ip := net.ParseIP(host)
+		for _, network := range t.cidrs {
+			if ip != nil && network.Contains(ip) {
+				return ""
+			}
+		}
+
+		reasons = append(reasons, fmt.Sprintf("remote address %s is not in a trusted network", host))
+	}
+
+	if len(t.peers) != 0 {
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+			reasons = append(reasons, "no verified peer certificate")
+		} else {
+			for _, identity := range peerIdentities(r) {
+				if t.peers[identity] {
+					return ""
+				}
+			}
+
+			reasons = append(reasons, fmt.Sprintf("peer %s is not trusted", r.TLS.PeerCertificates[0].Subject.CommonName))
+		}
+	}
+
+	for _, predicate := range t.predicates {
+		errPredicate := predicate(r)
+		if errPredicate == nil {
+			return ""
+		}
+
+		reasons = append(reasons, errPredicate.Error())
+	}
+
+	return strings.Join(reasons, "; ")
+}
+
+func peerIdentities(r *http.Request) []string {
+	cert := r.TLS.PeerCertificates[0]
+	identities := []string{cert.Subject.CommonName}
+	identities = append(identities, cert.DNSNames...)
+
+	for _, uri := range cert.URIs {
+		identities = append(identities, uri.String())
+	}
+
+	return identities
+}
+
+// incomingSpanContext returns the span context the caller sent, either already extracted in the request context by another middleware or from one of the supported trace headers
+func incomingSpanContext(r *http.Request) trace.SpanContext {
+	if spanCtx := trace.SpanContextFromContext(r.Context()); spanCtx.IsValid() {
+		return spanCtx
+	}
+
+	parsers := []struct {
+		header string
+		parse  func(string) (context.Context, error)
+	}{
+		{header: cmotel.HeaderTraceparent, parse: cmotel.ParseTraceParent},
+		{header: cmotel.HeaderB3, parse: cmotel.ParseB3},
+		{header: cmotel.HeaderUberTraceID, parse: cmotel.ParseUberTraceID},
+	}
+
+	for _, parser := range parsers {
+		value := r.Header.Get(parser.header)
+		if value == "" {
+			continue
+		}
+
+		if ctx, errParse := parser.parse(value); errParse == nil {
+			return trace.SpanContextFromContext(ctx)
+		}
+	}
+
+	if ctx, errParse := cmotel.ParseB3MultiHeader(r.Header); errParse == nil {
+		return trace.SpanContextFromContext(ctx)
+	}
+
+	return trace.SpanContext{}
+}
@@ -0,0 +1,391 @@
+package cmotel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/coordimap/cm-otel-go/internal/semconv"
+)
+
+const (
+	// DefaultMaxExportBatchSize the batch size used when neither an option nor an environment variable sets it
+	DefaultMaxExportBatchSize = 50
+
+	// envOtelBSPMaxExportBatchSize the standard OpenTelemetry variable that is honoured before falling back to DefaultMaxExportBatchSize
+	envOtelBSPMaxExportBatchSize = "OTEL_BSP_MAX_EXPORT_BATCH_SIZE"
+)
+
+// Provider gives access to the tracer provider created by InitTracerProvider so that it can be flushed and shut down
+type Provider struct {
+	tracerProvider *sdktrace.TracerProvider
+}
+
+// TracerProvider returns the underlying SDK tracer provider
+func (p *Provider) TracerProvider() *sdktrace.TracerProvider {
+	return p.tracerProvider
+}
+
+// Tracer returns a named tracer of the provider
+func (p *Provider) Tracer(name string, opts ...trace.TracerOption) trace.Tracer {
+	return p.tracerProvider.Tracer(name, opts...)
+}
+
+// ForceFlush exports all the spans that have not been exported yet
+func (p *Provider) ForceFlush(ctx context.Context) error {
+	return p.tracerProvider.ForceFlush(ctx)
+}
+
+// Shutdown flushes the remaining spans and stops the provider. It should be called before the process exits, e.g. on pod termination.
+func (p *Provider) Shutdown(ctx context.Context) error {
+	return p.tracerProvider.Shutdown(ctx)
+}
+
+type providerOpts struct {
+	serviceName        string
+	sampler            sdktrace.Sampler
+	maxExportBatchSize int
+	batchTimeout       time.Duration
+	exportTimeout      time.Duration
+	maxQueueSize       int
+	spanLimits         *sdktrace.SpanLimits
+	idGenerator        sdktrace.IDGenerator
+	resources          []*resource.Resource
+	spanProcessors     []sdktrace.SpanProcessor
+	setGlobal          bool
+
+	// ignoreEnvErrors keeps the defaults of the invalid environment variables instead of failing, for InitDefaultTracerProvider which never failed on them
+	ignoreEnvErrors bool
+}
+
+// ProviderOption the function parameter for InitTracerProvider
+type ProviderOption = func(opt *providerOpts) error
+
+// WithProviderServiceName the service name of the resource. It defaults to the SERVICE_NAME environment variable.
+func WithProviderServiceName(serviceName string) ProviderOption {
+	return func(opt *providerOpts) error {
+		if serviceName == "" {
+			return errors.New("service name must not be empty")
+		}
+
+		opt.serviceName = serviceName
+
+		return nil
+	}
+}
+
+// WithSampler the sampler of the provider. It defaults to the TRACES_SAMPLER_RATIO environment variable and then to the SDK default, which honours OTEL_TRACES_SAMPLER.
+func WithSampler(sampler sdktrace.Sampler) ProviderOption {
+	return func(opt *providerOpts) error {
+		if sampler == nil {
+			return errors.New("sampler must not be nil")
+		}
+
+		opt.sampler = sampler
+
+		return nil
+	}
+}
+
+// WithMaxExportBatchSize the maximum number of spans exported at once. It defaults to the BATCH_MAX_EXPORT_SIZE environment variable, then OTEL_BSP_MAX_EXPORT_BATCH_SIZE and then DefaultMaxExportBatchSize.
+func WithMaxExportBatchSize(size int) ProviderOption {
+	return func(opt *providerOpts) error {
+		if size <= 0 {
+			return errors.New("max export batch size must be positive")
+		}
+
+		opt.maxExportBatchSize = size
+
+		return nil
+	}
+}
+
+// WithBatchTimeout the maximum delay before a batch is exported. It defaults to the BATCH_TIMEOUT_MS environment variable and then to the SDK default, which honours OTEL_BSP_SCHEDULE_DELAY.
+func WithBatchTimeout(timeout time.Duration) ProviderOption {
+	return func(opt *providerOpts) error {
+		if timeout <= 0 {
+			return errors.New("batch timeout must be positive")
+		}
+
+		opt.batchTimeout = timeout
+
+		return nil
+	}
+}
+
+// WithExportTimeout the maximum duration of an export. It defaults to the SDK default, which honours OTEL_BSP_EXPORT_TIMEOUT.
+func WithExportTimeout(timeout time.Duration) ProviderOption {
+	return func(opt *providerOpts) error {
+		if timeout <= 0 {
+			return errors.New("export timeout must be positive")
+		}
+
+		opt.exportTimeout = timeout
+
+		return nil
+	}
+}
+
+// WithMaxQueueSize the maximum number of spans waiting to be exported. It defaults to the BATCH_MAX_QUEUE_SIZE environment variable and then to the SDK default, which honours OTEL_BSP_MAX_QUEUE_SIZE.
+func WithMaxQueueSize(size int) ProviderOption {
+	return func(opt *providerOpts) error {
+		if size <= 0 {
+			return errors.New("max queue size must be positive")
+		}
+
+		opt.maxQueueSize = size
+
+		return nil
+	}
+}
+
+// WithSpanLimits the limits of the spans. It defaults to the SDK default, which honours the OTEL_SPAN_* variables.
+func WithSpanLimits(limits sdktrace.SpanLimits) ProviderOption {
+	return func(opt *providerOpts) error {
+		opt.spanLimits = &limits
+
+		return nil
+	}
+}
+
+// WithIDGenerator the generator of the trace and span IDs
+func WithIDGenerator(generator sdktrace.IDGenerator) ProviderOption {
+	return func(opt *providerOpts) error {
+		if generator == nil {
+			return errors.New("id generator must not be nil")
+		}
+
+		opt.idGenerator = generator
+
+		return nil
+	}
+}
+
+// WithResource an additional resource merged into the default one, which holds the service name and the known environment variables
+func WithResource(res *resource.Resource) ProviderOption {
+	return func(opt *providerOpts) error {
+		if res == nil {
+			return errors.New("resource must not be nil")
+		}
+
+		opt.resources = append(opt.resources, res)
+
+		return nil
+	}
+}
+
+// WithResourceDetectors runs the detectors, e.g. NewAWSDetector or NewK8SDetector, for at most DefaultCloudMetadataTimeout and merges the resources they find into the default one.
+// A failing detector does not fail the provider: its error goes to the OTel error handler and the resources of the other detectors are kept.
+func WithResourceDetectors(detectors ...resource.Detector) ProviderOption {
+	return func(opt *providerOpts) error {
+		ctx, cancel := context.WithTimeout(context.Background(), DefaultCloudMetadataTimeout)
+		defer cancel()
+
+		res, errDetect := resource.Detect(ctx, detectors...)
+		if errDetect != nil {
+			otel.Handle(errors.Join(errors.New("could not detect the whole resource"), errDetect))
+		}
+
+		if res != nil {
+			opt.resources = append(opt.resources, res)
+		}
+
+		return nil
+	}
+}
+
+// WithSpanProcessor an additional span processor registered next to the batcher of the exporter
+func WithSpanProcessor(processor sdktrace.SpanProcessor) ProviderOption {
+	return func(opt *providerOpts) error {
+		if processor == nil {
+			return errors.New("span processor must not be nil")
+		}
+
+		opt.spanProcessors = append(opt.spanProcessors, processor)
+
+		return nil
+	}
+}
+
+// WithGlobalTracerProvider whether the provider is registered as the global otel tracer provider. It defaults to true.
+func WithGlobalTracerProvider(setGlobal bool) ProviderOption {
+	return func(opt *providerOpts) error {
+		opt.setGlobal = setGlobal
+
+		return nil
+	}
+}
+
+// InitTracerProvider creates a tracer provider that exports to the exporter, registers it globally and returns it so that it can be flushed and shut down.
+// Every setting is taken from its option, otherwise from the CM_PREFIX aware environment variable, otherwise from the standard OTEL_* variable and finally from the default.
+func InitTracerProvider(exporter sdktrace.SpanExporter, opts ...ProviderOption) (*Provider, error) {
+	if exporter == nil {
+		return nil, errors.New("exporter must not be nil")
+	}
+
+	prefix := GetEnvWithPrefix("", EnvCmPrefix)
+
+	options := &providerOpts{
+		serviceName:        GetEnvWithPrefix(prefix, EnvServiceName),
+		sampler:            nil,
+		maxExportBatchSize: 0,
+		batchTimeout:       0,
+		exportTimeout:      0,
+		maxQueueSize:       0,
+		spanLimits:         nil,
+		idGenerator:        nil,
+		resources:          []*resource.Resource{},
+		spanProcessors:     []sdktrace.SpanProcessor{},
+		setGlobal:          true,
+		ignoreEnvErrors:    false,
+	}
+
+	for _, opt := range opts {
+		if err := opt(options); err != nil {
+			return nil, err
+		}
+	}
+
+	if errEnv := loadProviderEnv(prefix, options); errEnv != nil {
+		if !options.ignoreEnvErrors {
+			return nil, errEnv
+		}
+
+		otel.Handle(errors.Join(errors.New("ignored the invalid environment variables"), errEnv))
+	}
+
+	res, errRes := resource.Merge(
+		resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceName(GetServiceName(options.serviceName)),
+		),
+		loadEnvResource(prefix, options.serviceName),
+	)
+	if errRes != nil {
+		return nil, errRes
+	}
+
+	for _, extra := range options.resources {
+		res, errRes = resource.Merge(res, extra)
+		if errRes != nil {
+			return nil, errors.Join(errors.New("could not merge the additional resource"), errRes)
+		}
+	}
+
+	batchOpts := []sdktrace.BatchSpanProcessorOption{
+		sdktrace.WithMaxExportBatchSize(options.maxExportBatchSize),
+	}
+
+	if options.batchTimeout != 0 {
+		batchOpts = append(batchOpts, sdktrace.WithBatchTimeout(options.batchTimeout))
+	}
+
+	if options.exportTimeout != 0 {
+		batchOpts = append(batchOpts, sdktrace.WithExportTimeout(options.exportTimeout))
+	}
+
+	if options.maxQueueSize != 0 {
+		batchOpts = append(batchOpts, sdktrace.WithMaxQueueSize(options.maxQueueSize))
+	}
+
+	providerOptions := []sdktrace.TracerProviderOption{
+		sdktrace.WithBatcher(exporter, batchOpts...),
+		sdktrace.WithResource(res),
+	}
+
+	if options.sampler != nil {
+		providerOptions = append(providerOptions, sdktrace.WithSampler(options.sampler))
+	}
+
+	if options.spanLimits != nil {
+		providerOptions = append(providerOptions, sdktrace.WithRawSpanLimits(*options.spanLimits))
+	}
+
+	if options.idGenerator != nil {
+		providerOptions = append(providerOptions, sdktrace.WithIDGenerator(options.idGenerator))
+	}
+
+	for _, processor := range options.spanProcessors {
+		providerOptions = append(providerOptions, sdktrace.WithSpanProcessor(processor))
+	}
+
+	tracerProvider := sdktrace.NewTracerProvider(providerOptions...)
+
+	if options.setGlobal {
+		otel.SetTracerProvider(tracerProvider)
+	}
+
+	return &Provider{
+		tracerProvider: tracerProvider,
+	}, nil
+}
+
+// loadProviderEnv fills in the settings that were not set through an option from the environment variables. The settings whose variable is invalid are left to their defaults and the errors are returned together.
+func loadProviderEnv(prefix string, options *providerOpts) error {
+	var errs error
+
+	if options.sampler == nil {
+		if ratio := GetEnvWithPrefix(prefix, EnvTracesSamplerRatio); ratio != "" {
+			parsedRatio, errRatio := strconv.ParseFloat(ratio, 64)
+			if errRatio != nil || parsedRatio < 0 || parsedRatio > 1 {
+				errs = errors.Join(errs, fmt.Errorf("%s%s must be a number between 0 and 1, got %s", prefix, EnvTracesSamplerRatio, ratio))
+			} else {
+				options.sampler = sdktrace.ParentBased(sdktrace.TraceIDRatioBased(parsedRatio))
+			}
+		}
+	}
+
+	if options.maxExportBatchSize == 0 {
+		size, errSize := envPositiveInt(prefix, EnvBatchMaxExportSize)
+		errs = errors.Join(errs, errSize)
+
+		options.maxExportBatchSize = size
+	}
+
+	if options.maxExportBatchSize == 0 {
+		if size, errSize := strconv.Atoi(os.Getenv(envOtelBSPMaxExportBatchSize)); errSize == nil && size > 0 {
+			options.maxExportBatchSize = size
+		} else {
+			options.maxExportBatchSize = DefaultMaxExportBatchSize
+		}
+	}
+
+	if options.maxQueueSize == 0 {
+		size, errSize := envPositiveInt(prefix, EnvBatchMaxQueueSize)
+		errs = errors.Join(errs, errSize)
+
+		options.maxQueueSize = size
+	}
+
+	if options.batchTimeout == 0 {
+		timeout, errTimeout := envPositiveInt(prefix, EnvBatchTimeout)
+		errs = errors.Join(errs, errTimeout)
+
+		options.batchTimeout = time.Duration(timeout) * time.Millisecond
+	}
+
+	return errs
+}
+
+// envPositiveInt returns the value of <prefix><env> or zero when it is not set
+func envPositiveInt(prefix, env string) (int, error) {
+	value := GetEnvWithPrefix(prefix, env)
+	if value == "" {
+		return 0, nil
+	}
+
+	parsed, errParse := strconv.Atoi(value)
+	if errParse != nil || parsed <= 0 {
+		return 0, fmt.Errorf("%s%s must be a positive integer, got %s", prefix, env, value)
+	}
+
+	return parsed, nil
+}
@@ -0,0 +1,123 @@
+package cmotel
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestInitTracerProvider(t *testing.T) {
+	t.Setenv(EnvServiceName, "orders")
+	t.Setenv(EnvServiceNamePrefix, "cluster.namespace")
+	t.Setenv(EnvBatchTimeout, "3600000")
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider, err := InitTracerProvider(exporter, WithGlobalTracerProvider(false))
+	if err != nil {
+		t.Fatalf("InitTracerProvider() error = %v", err)
+	}
+
+	_, span := provider.Tracer("test").Start(context.Background(), "span")
+	span.End()
+
+	if got := len(exporter.GetSpans()); got != 0 {
+		t.Fatalf("got %d exported spans before flushing, want 0", got)
+	}
+
+	if err := provider.ForceFlush(context.Background()); err != nil {
+		t.Fatalf("ForceFlush() error = %v", err)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("got %d exported spans after flushing, want 1", len(spans))
+	}
+
+	serviceName, _ := spans[0].Resource.Set().Value("service.name")
+	if serviceName.AsString() != "cluster.namespace.orders" {
+		t.Errorf("service.name = %v, want cluster.namespace.orders", serviceName.AsString())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := provider.Shutdown(ctx); err != nil {
+		t.Errorf("Shutdown() error = %v", err)
+	}
+}
+
+func TestInitTracerProviderInvalidEnv(t *testing.T) {
+	tests := []struct {
+		name  string
+		env   string
+		value string
+	}{
+		{name: "sampler ratio out of range", env: EnvTracesSamplerRatio, value: "1.5"},
+		{name: "batch size not a number", env: EnvBatchMaxExportSize, value: "fifty"},
+		{name: "negative queue size", env: EnvBatchMaxQueueSize, value: "-1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(tt.env, tt.value)
+
+			if _, err := InitTracerProvider(tracetest.NewInMemoryExporter(), WithGlobalTracerProvider(false)); err == nil {
+				t.Errorf("InitTracerProvider() error = nil, want an error for %s=%s", tt.env, tt.value)
+			}
+		})
+	}
+}
+
+type detectorFunc func(ctx context.Context) (*resource.Resource, error)
+
+func (f detectorFunc) Detect(ctx context.Context) (*resource.Resource, error) {
+	return f(ctx)
+}
+
+func TestWithResourceDetectorsFailing(t *testing.T) {
+	t.Setenv(EnvServiceName, "orders")
+
+	found := detectorFunc(func(context.Context) (*resource.Resource, error) {
+		return resource.NewSchemaless(attribute.String("cloud.provider", "aws")), nil
+	})
+	failing := detectorFunc(func(context.Context) (*resource.Resource, error) {
+		return nil, errors.New("metadata service timed out")
+	})
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider, err := InitTracerProvider(exporter, WithGlobalTracerProvider(false), WithResourceDetectors(found, failing))
+	if err != nil {
+		t.Fatalf("InitTracerProvider() error = %v, want the failing detector to be skipped", err)
+	}
+
+	_, span := provider.Tracer("test").Start(context.Background(), "span")
+	span.End()
+
+	if err := provider.ForceFlush(context.Background()); err != nil {
+		t.Fatalf("ForceFlush() error = %v", err)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("got %d exported spans, want 1", len(spans))
+	}
+
+	if value, ok := spans[0].Resource.Set().Value("cloud.provider"); !ok || value.AsString() != "aws" {
+		t.Errorf("cloud.provider = %v, want the resource of the other detector", value.AsString())
+	}
+}
+
+func TestInitDefaultTracerProviderInvalidEnv(t *testing.T) {
+	t.Setenv(EnvBatchMaxExportSize, "fifty")
+
+	previous := otel.GetTracerProvider()
+	defer otel.SetTracerProvider(previous)
+
+	if err := InitDefaultTracerProvider(tracetest.NewInMemoryExporter()); err != nil {
+		t.Errorf("InitDefaultTracerProvider() error = %v, want the invalid variable ignored", err)
+	}
+}
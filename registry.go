@@ -0,0 +1,122 @@
+package cmotel
+
+import (
+	"sort"
+	"sync"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SpanInfo a snapshot of a span registered in a CMOtel object, see ExtendedCMOtel.Spans
+type SpanInfo struct {
+	Name         string `json:"name"`
+	InternalName string `json:"internal_name"`
+	TraceID      string `json:"trace_id"`
+	SpanID       string `json:"span_id"`
+	ParentName   string `json:"parent_name,omitempty"`
+
+	// ParentInternalName the internal name of the parent, also set when the parent was created by other instrumentation or another CMOtel object and so has no local name
+	ParentInternalName string `json:"parent_internal_name,omitempty"`
+
+	// Remote the span was received from another service, e.g. through the span map header
+	Remote bool `json:"remote"`
+
+	// Ended the span was ended through CMOtel.EndSpan
+	Ended bool `json:"ended"`
+
+	// Recording the span is still recording, false once it is ended by any means or when it was not sampled
+	Recording  bool `json:"recording"`
+	Unverified bool `json:"unverified,omitempty"`
+
+	Components    []CMComponent `json:"components,omitempty"`
+	Relationships []string      `json:"relationships,omitempty"`
+}
+
+// spanRegistry keeps what is needed to describe the spans of a cmOtel object. It has its own lock so that it can be read while the spans are created.
+type spanRegistry struct {
+	mu    sync.RWMutex
+	spans map[string]*SpanInfo
+	raw   map[string]trace.Span
+}
+
+func newSpanRegistry() *spanRegistry {
+	return &spanRegistry{
+		spans: map[string]*SpanInfo{},
+		raw:   map[string]trace.Span{},
+	}
+}
+
+func (r *spanRegistry) register(info SpanInfo, span trace.Span) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.spans[info.Name] = &info
+	r.raw[info.Name] = span
+}
+
+func (r *spanRegistry) update(name string, change func(info *SpanInfo)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if info, ok := r.spans[name]; ok {
+		change(info)
+	}
+}
+
+// updateSpan changes the entry of the span whatever its name, which may have been reused by a newer span
+func (r *spanRegistry) updateSpan(spanID trace.SpanID, change func(info *SpanInfo)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, info := range r.spans {
+		if info.SpanID == spanID.String() {
+			change(info)
+
+			return
+		}
+	}
+}
+
+// ended whether the span was ended, either through CMOtel.EndSpan or directly. Spans that were not sampled never record so they are only considered ended through CMOtel.EndSpan.
+func (r *spanRegistry) ended(name string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	info, ok := r.spans[name]
+	if !ok {
+		return false
+	}
+
+	span := r.raw[name]
+
+	return info.Ended || (!info.Remote && span != nil && span.SpanContext().IsSampled() && !span.IsRecording())
+}
+
+func (r *spanRegistry) snapshot() []SpanInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	spans := make([]SpanInfo, 0, len(r.spans))
+	for name, info := range r.spans {
+		snapshot := *info
+		snapshot.Components = append([]CMComponent{}, info.Components...)
+		snapshot.Relationships = append([]string{}, info.Relationships...)
+
+		if span := r.raw[name]; span != nil {
+			snapshot.Recording = span.IsRecording()
+		}
+
+		spans = append(spans, snapshot)
+	}
+
+	sort.Slice(spans, func(i, j int) bool {
+		return spans[i].Name < spans[j].Name
+	})
+
+	return spans
+}
+
+// Spans returns a snapshot of the spans known to the object sorted by name. It is safe to call while spans are being created, e.g. from a debug endpoint.
+func (cm *cmOtel) Spans() []SpanInfo {
+	return cm.registry.snapshot()
+}
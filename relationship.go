@@ -0,0 +1,41 @@
+package cmotel
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// RelationshipSeparator separates the source and the destination internal names in the SpanAttrRelationship attribute
+const RelationshipSeparator = "@@@"
+
+// FormatRelationship returns the value of the SpanAttrRelationship attribute for a relationship from one internal name to another
+func FormatRelationship(from, to string) string {
+	return fmt.Sprintf("%s%s%s", from, RelationshipSeparator, to)
+}
+
+// ParseRelationship splits the value of the SpanAttrRelationship attribute into the source and destination internal names
+func ParseRelationship(relationship string) (string, string, error) {
+	parts := strings.Split(relationship, RelationshipSeparator)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("invalid relationship %s", relationship)
+	}
+
+	return parts[0], parts[1], nil
+}
+
+// ParseComponent decodes the value of the SpanAttrComponent attribute
+func ParseComponent(component string) (CMComponent, error) {
+	decoded := CMComponent{}
+
+	if errUnmarshal := json.Unmarshal([]byte(component), &decoded); errUnmarshal != nil {
+		return CMComponent{}, errors.Join(errors.New("could not decode the component"), errUnmarshal)
+	}
+
+	if decoded.InternalID == "" {
+		return CMComponent{}, errors.New("the component has no internal id")
+	}
+
+	return decoded, nil
+}
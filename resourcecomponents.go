@@ -0,0 +1,83 @@
+package cmotel
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"sort"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ResourceAttrComponentsPrefix prefix of the resource attributes holding the containers, e.g. the node and the pod, the service runs in. Every detector uses its own key, see ResourceComponentsKey, so that merging the resources keeps all of them.
+const ResourceAttrComponentsPrefix = "coordimap.resource_attr.components."
+
+// resourceComponentLevels how deep a container type is nested, the outermost first. Types not listed are nested innermost.
+var resourceComponentLevels = map[string]int{
+	ComponentTypeCloudAccount: 1,
+	ComponentTypeCloudRegion:  2,
+	ComponentTypeK8SNode:      10,
+	ComponentTypeK8SPod:       11,
+}
+
+// ResourceComponentsKey the resource attribute key of the containers found by the detector named source, e.g. k8s
+func ResourceComponentsKey(source string) attribute.Key {
+	return attribute.Key(ResourceAttrComponentsPrefix + source)
+}
+
+// ResourceComponents returns the resource attribute holding the containers found by the detector named source
+func ResourceComponents(source string, components ...CMComponent) (attribute.KeyValue, error) {
+	encoded := make([]string, 0, len(components))
+
+	for _, component := range components {
+		if component.InternalID == "" {
+			return attribute.KeyValue{}, errors.New("the component has no internal id")
+		}
+
+		component.IsContainer = true
+
+		value, errMarshal := json.Marshal(component)
+		if errMarshal != nil {
+			return attribute.KeyValue{}, errors.Join(errors.New("could not encode the component"), errMarshal)
+		}
+
+		encoded = append(encoded, string(value))
+	}
+
+	return ResourceComponentsKey(source).StringSlice(encoded), nil
+}
+
+// ParseResourceComponents decodes the containers of all the detectors from the resource attributes and orders them from the outermost, e.g. the node, to the innermost, e.g. the pod, which encloses the spans of the service. Components that cannot be decoded are skipped.
+func ParseResourceComponents(attributes []attribute.KeyValue) []CMComponent {
+	components := []CMComponent{}
+
+	for _, attr := range attributes {
+		if !strings.HasPrefix(string(attr.Key), ResourceAttrComponentsPrefix) {
+			continue
+		}
+
+		for _, value := range attr.Value.AsStringSlice() {
+			component, errComponent := ParseComponent(value)
+			if errComponent != nil {
+				continue
+			}
+
+			components = append(components, component)
+		}
+	}
+
+	sort.SliceStable(components, func(i, j int) bool {
+		return resourceComponentLevel(components[i].Type) < resourceComponentLevel(components[j].Type)
+	})
+
+	return components
+}
+
+func resourceComponentLevel(componentType string) int {
+	if level, ok := resourceComponentLevels[componentType]; ok {
+		return level
+	}
+
+	return math.MaxInt
+}
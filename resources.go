@@ -1,73 +1,82 @@
 package cmotel
 
 import (
+	"context"
 	"fmt"
-	"os"
-	"strings"
 
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/sdk/resource"
-	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+
+	"github.com/coordimap/cm-otel-go/internal/semconv"
 )
 
-// LoadEnvVarsAsResource loads all the known environment variables as resource attributes
+// LoadEnvVarsAsResource loads the known environment variables, named <prefix><variable>, as resource attributes along with the pod metadata found by NewK8SDetector and the Coordimap identity of the service named by <prefix>SERVICE_NAME.
+//
+// Every value is taken from the first source setting it:
+//   - cluster: K8S_CLUSTER_NAME
+//   - namespace: NAMESPACE_NAME, then the namespace file of the downward API volume
+//   - pod: POD_NAME, then the name file of the downward API volume
+//   - full pod name: <cluster>.<namespace>.<pod>, only when the three of them are known
+//   - full service name: GetServiceName(<service>), i.e. the service part of the internal names, which only reads the variables prefixed by CM_PREFIX and never the downward API volume
 func LoadEnvVarsAsResource(prefix string) *resource.Resource {
+	return loadEnvResource(prefix, GetEnvWithPrefix(prefix, EnvServiceName))
+}
+
+func loadEnvResource(prefix, serviceName string) *resource.Resource {
 	foundAttributes := []attribute.KeyValue{}
-	hasClusterName := false
-	hasNamespace := false
-	hasPodName := false
-
-	for _, env := range os.Environ() {
-		pair := strings.SplitN(env, "=", 2)
-
-		switch pair[0] {
-		case fmt.Sprintf("%s%s", prefix, EnvK8SNamespaceName):
-			foundAttributes = append(foundAttributes, semconv.K8SNamespaceName(pair[1]))
-			hasNamespace = true
-			break
-
-		case fmt.Sprintf("%s%s", prefix, EnvNodeIPType):
-			foundAttributes = append(foundAttributes, attribute.KeyValue{
-				Key:   EnvNodeIPType,
-				Value: attribute.StringValue(pair[1]),
-			})
-			break
-
-		case fmt.Sprintf("%s%s", prefix, EnvNodeNameType):
-			foundAttributes = append(foundAttributes, semconv.K8SNodeName(pair[1]))
-			break
-
-		case fmt.Sprintf("%s%s", prefix, EnvPodNameType):
-			foundAttributes = append(foundAttributes, semconv.K8SPodName(pair[1]))
-			hasPodName = true
-			break
-
-		case fmt.Sprintf("%s%s", prefix, EnvServiceAccountType):
-			foundAttributes = append(foundAttributes, attribute.KeyValue{
-				Key:   EnvServiceAccountType,
-				Value: attribute.StringValue(pair[1]),
-			})
-			break
-
-		case fmt.Sprintf("%s%s", prefix, EnvK8SClusterName):
-			foundAttributes = append(foundAttributes, attribute.KeyValue{
-				Key:   EnvK8SClusterName,
-				Value: attribute.StringValue(pair[1]),
-			})
-			hasClusterName = true
-			break
+
+	for _, env := range []string{EnvNodeIPType, EnvServiceAccountType} {
+		if value := GetEnvWithPrefix(prefix, env); value != "" {
+			foundAttributes = append(foundAttributes, attribute.String(env, value))
 		}
 	}
 
-	if hasClusterName && hasNamespace && hasPodName {
-		foundAttributes = append(foundAttributes, attribute.KeyValue{
-			Key: PodNameCompleteType,
-			Value: attribute.StringValue(fmt.Sprintf("%s.%s.%s",
-				os.Getenv(fmt.Sprintf("%s%s", prefix, EnvK8SClusterName)),
-				os.Getenv(fmt.Sprintf("%s%s", prefix, EnvK8SNamespaceName)),
-				os.Getenv(fmt.Sprintf("%s%s", prefix, EnvNodeNameType)))),
-		})
+	k8sResource := resource.Empty()
+
+	// the downward API files that cannot be read are skipped by the detector, which still returns the rest
+	detector, errDetector := NewK8SDetector(WithK8SEnvPrefix(prefix))
+	if errDetector == nil {
+		if detected, _ := detector.Detect(context.Background()); detected != nil {
+			k8sResource = detected
+		}
+	}
+
+	foundAttributes = append(foundAttributes, identityAttributes(serviceName, k8sResource)...)
+
+	merged, errMerge := resource.Merge(resource.NewWithAttributes(semconv.SchemaURL, foundAttributes...), k8sResource)
+	if errMerge != nil {
+		return resource.NewWithAttributes(semconv.SchemaURL, foundAttributes...)
+	}
+
+	return merged
+}
+
+// identityAttributes returns the coordimap.identity.* attributes from the k8s.* attributes found by the Kubernetes detector
+func identityAttributes(serviceName string, k8sResource *resource.Resource) []attribute.KeyValue {
+	identity := []attribute.KeyValue{}
+
+	cluster, _ := k8sResource.Set().Value(semconv.K8SClusterName("").Key)
+	namespace, _ := k8sResource.Set().Value(semconv.K8SNamespaceName("").Key)
+	pod, _ := k8sResource.Set().Value(semconv.K8SPodName("").Key)
+
+	if cluster.AsString() != "" {
+		// K8S_CLUSTER_NAME is deprecated in favour of IdentityAttrCluster but still emitted for the existing consumers
+		identity = append(identity, attribute.String(IdentityAttrCluster, cluster.AsString()), attribute.String(EnvK8SClusterName, cluster.AsString()))
+	}
+
+	if namespace.AsString() != "" {
+		identity = append(identity, attribute.String(IdentityAttrNamespace, namespace.AsString()))
+	}
+
+	if cluster.AsString() != "" && namespace.AsString() != "" && pod.AsString() != "" {
+		fullName := fmt.Sprintf("%s.%s.%s", cluster.AsString(), namespace.AsString(), pod.AsString())
+		identity = append(identity, attribute.String(IdentityAttrPodFullName, fullName), attribute.String(PodNameCompleteType, fullName))
+	}
+
+	// resolved like the internal names so that both always agree
+	if serviceName != "" {
+		identity = append(identity, attribute.String(IdentityAttrServiceFullName, GetServiceName(serviceName)))
 	}
 
-	return resource.NewWithAttributes(semconv.SchemaURL, foundAttributes...)
+	return identity
 }
@@ -0,0 +1,125 @@
+package cmotel
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestLoadEnvVarsAsResource(t *testing.T) {
+	tests := []struct {
+		name   string
+		prefix string
+		env    map[string]string
+		files  map[string]string
+		want   map[attribute.Key]string
+	}{
+		{
+			name: "full pod name uses the pod name",
+			env: map[string]string{
+				EnvK8SClusterName:   "prod",
+				EnvK8SNamespaceName: "shop",
+				EnvNodeNameType:     "node-1",
+				EnvPodNameType:      "orders-0",
+				EnvServiceName:      "orders",
+			},
+			want: map[attribute.Key]string{
+				IdentityAttrCluster:         "prod",
+				IdentityAttrNamespace:       "shop",
+				IdentityAttrPodFullName:     "prod.shop.orders-0",
+				IdentityAttrServiceFullName: "prod.shop.orders",
+				"k8s.pod.name":              "orders-0",
+				"k8s.node.name":             "node-1",
+				// the deprecated attributes are still emitted
+				EnvK8SClusterName:   "prod",
+				PodNameCompleteType: "prod.shop.orders-0",
+			},
+		},
+		{
+			name:   "prefixed variables",
+			prefix: "APP_",
+			env: map[string]string{
+				EnvCmPrefix:                   "APP_",
+				"APP_" + EnvK8SClusterName:    "prod",
+				"APP_" + EnvK8SNamespaceName:  "shop",
+				"APP_" + EnvPodNameType:       "orders-0",
+				"APP_" + EnvServiceName:       "orders",
+				"APP_" + EnvServiceNamePrefix: "eu.shop",
+				"APP_" + EnvNodeIPType:        "10.0.0.1",
+				EnvPodNameType:                "ignored-0",
+			},
+			want: map[attribute.Key]string{
+				IdentityAttrPodFullName:     "prod.shop.orders-0",
+				IdentityAttrServiceFullName: "eu.shop.orders",
+				EnvNodeIPType:               "10.0.0.1",
+			},
+		},
+		{
+			name: "downward API files fill the missing variables",
+			env: map[string]string{
+				EnvK8SClusterName: "prod",
+				EnvPodNameType:    "orders-0",
+				EnvServiceName:    "orders",
+			},
+			files: map[string]string{
+				"namespace": "shop",
+				"name":      "ignored-0",
+			},
+			want: map[attribute.Key]string{
+				IdentityAttrNamespace:   "shop",
+				IdentityAttrPodFullName: "prod.shop.orders-0",
+				// the internal names do not read the downward API volume
+				IdentityAttrServiceFullName: ".orders",
+			},
+		},
+		{
+			name: "partial identity",
+			env: map[string]string{
+				EnvK8SClusterName: "prod",
+				EnvPodNameType:    "orders-0",
+				EnvServiceName:    "orders",
+			},
+			want: map[attribute.Key]string{
+				IdentityAttrCluster:         "prod",
+				IdentityAttrNamespace:       "",
+				IdentityAttrPodFullName:     "",
+				IdentityAttrServiceFullName: ".orders",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, contents := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			t.Setenv(EnvCmPrefix, "")
+			for _, env := range []string{EnvK8SClusterName, EnvK8SNamespaceName, EnvNodeNameType, EnvPodNameType, EnvServiceName, EnvServiceNamePrefix, EnvNodeIPType} {
+				t.Setenv(env, "")
+				t.Setenv(tt.prefix+env, "")
+			}
+			for env, value := range tt.env {
+				t.Setenv(env, value)
+			}
+			t.Setenv(tt.prefix+EnvDownwardAPIPath, dir)
+
+			res := LoadEnvVarsAsResource(tt.prefix)
+
+			if got, _ := res.Set().Value(IdentityAttrServiceFullName); got.AsString() != "" && got.AsString() != GetServiceName(GetEnvWithPrefix(tt.prefix, EnvServiceName)) {
+				t.Errorf("full service name = %q, want the service part of the internal names %q", got.AsString(), GetServiceName(GetEnvWithPrefix(tt.prefix, EnvServiceName)))
+			}
+
+			for key, want := range tt.want {
+				got, _ := res.Set().Value(key)
+				if got.AsString() != want {
+					t.Errorf("%s = %q, want %q", key, got.AsString(), want)
+				}
+			}
+		})
+	}
+}
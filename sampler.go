@@ -0,0 +1,213 @@
+package cmotel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// coordimapAttributePrefix the prefix shared by all the Coordimap span attributes
+const coordimapAttributePrefix = "coordimap."
+
+type topologySamplerOpts struct {
+	topologySampler sdktrace.Sampler
+	strip           bool
+}
+
+// TopologySamplerOption the function parameter for NewTopologySampler
+type TopologySamplerOption = func(opt *topologySamplerOpts) error
+
+// WithTopologySampleRatio samples the spans carrying topology at their own ratio instead of always recording them
+func WithTopologySampleRatio(ratio float64) TopologySamplerOption {
+	return func(opt *topologySamplerOpts) error {
+		if ratio < 0 || ratio > 1 {
+			return errors.New("the topology sample ratio must be between 0 and 1")
+		}
+
+		opt.topologySampler = sdktrace.TraceIDRatioBased(ratio)
+
+		return nil
+	}
+}
+
+// WithTopologyStripping marks the spans that are only recorded because of their topology with SpanAttrTopologyOnly so that NewTopologyStrippingExporter removes everything but the Coordimap attributes and relationships
+func WithTopologyStripping() TopologySamplerOption {
+	return func(opt *topologySamplerOpts) error {
+		opt.strip = true
+
+		return nil
+	}
+}
+
+type topologySampler struct {
+	delegate        sdktrace.Sampler
+	topologySampler sdktrace.Sampler
+	strip           bool
+}
+
+// NewTopologySampler wraps a sampler so that the spans carrying Coordimap topology, i.e. a component, parent name, relationship or target service attribute or a relationship link, are never dropped.
+// Every other span is sampled by the delegate. Samplers only see the attributes and links given when the span starts: components must be added with WithSpanComponent to be taken into account,
+// while a span whose only topology is a component added afterwards through AddComponent is sampled by the delegate alone.
+func NewTopologySampler(delegate sdktrace.Sampler, opts ...TopologySamplerOption) (sdktrace.Sampler, error) {
+	if delegate == nil {
+		return nil, errors.New("the delegate sampler must not be nil")
+	}
+
+	options := &topologySamplerOpts{
+		topologySampler: sdktrace.AlwaysSample(),
+		strip:           false,
+	}
+
+	for _, opt := range opts {
+		if err := opt(options); err != nil {
+			return nil, err
+		}
+	}
+
+	return &topologySampler{
+		delegate:        delegate,
+		topologySampler: options.topologySampler,
+		strip:           options.strip,
+	}, nil
+}
+
+func (s *topologySampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	result := s.delegate.ShouldSample(p)
+	if result.Decision == sdktrace.RecordAndSample || !carriesTopology(p) {
+		return result
+	}
+
+	topologyResult := s.topologySampler.ShouldSample(p)
+	if topologyResult.Decision != sdktrace.RecordAndSample {
+		return result
+	}
+
+	attributes := append([]attribute.KeyValue{}, result.Attributes...)
+	if s.strip {
+		attributes = append(attributes, attribute.Bool(SpanAttrTopologyOnly, true))
+	}
+
+	return sdktrace.SamplingResult{
+		Decision:   sdktrace.RecordAndSample,
+		Attributes: attributes,
+		Tracestate: result.Tracestate,
+	}
+}
+
+func (s *topologySampler) Description() string {
+	return fmt.Sprintf("TopologySampler{delegate:%s,topology:%s,strip:%t}", s.delegate.Description(), s.topologySampler.Description(), s.strip)
+}
+
+func carriesTopology(p sdktrace.SamplingParameters) bool {
+	for _, attr := range p.Attributes {
+		switch attr.Key {
+		case SpanAttrComponent, SpanAttrParentName, SpanAttrRelationship, SpanAttrRelationships, SpanAttrTargetService:
+			return true
+		}
+	}
+
+	for _, link := range p.Links {
+		for _, attr := range link.Attributes {
+			if attr.Key == SpanAttrRelationship {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+type topologyStrippingExporter struct {
+	sdktrace.SpanExporter
+}
+
+// NewTopologyStrippingExporter wraps an exporter so that the spans marked with SpanAttrTopologyOnly are exported with only their Coordimap attributes and relationship links
+func NewTopologyStrippingExporter(exporter sdktrace.SpanExporter) sdktrace.SpanExporter {
+	return &topologyStrippingExporter{
+		SpanExporter: exporter,
+	}
+}
+
+func (e *topologyStrippingExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	exported := make([]sdktrace.ReadOnlySpan, 0, len(spans))
+
+	for _, span := range spans {
+		if !isTopologyOnly(span) {
+			exported = append(exported, span)
+			continue
+		}
+
+		links := []sdktrace.Link{}
+		for _, link := range span.Links() {
+			if relationship := coordimapAttributes(link.Attributes); len(relationship) != 0 {
+				link.Attributes = relationship
+				links = append(links, link)
+			}
+		}
+
+		exported = append(exported, strippedSpan{
+			ReadOnlySpan: span,
+			attributes:   coordimapAttributes(span.Attributes()),
+			links:        links,
+		})
+	}
+
+	return e.SpanExporter.ExportSpans(ctx, exported)
+}
+
+// strippedSpan a span exported with only its Coordimap attributes and relationship links
+type strippedSpan struct {
+	sdktrace.ReadOnlySpan
+	attributes []attribute.KeyValue
+	links      []sdktrace.Link
+}
+
+func (s strippedSpan) Attributes() []attribute.KeyValue {
+	return s.attributes
+}
+
+func (s strippedSpan) Links() []sdktrace.Link {
+	return s.links
+}
+
+func (s strippedSpan) Events() []sdktrace.Event {
+	return nil
+}
+
+func (s strippedSpan) DroppedAttributes() int {
+	return 0
+}
+
+func (s strippedSpan) DroppedLinks() int {
+	return 0
+}
+
+func (s strippedSpan) DroppedEvents() int {
+	return 0
+}
+
+func isTopologyOnly(span sdktrace.ReadOnlySpan) bool {
+	for _, attr := range span.Attributes() {
+		if attr.Key == SpanAttrTopologyOnly {
+			return attr.Value.AsBool()
+		}
+	}
+
+	return false
+}
+
+func coordimapAttributes(attributes []attribute.KeyValue) []attribute.KeyValue {
+	kept := []attribute.KeyValue{}
+
+	for _, attr := range attributes {
+		if strings.HasPrefix(string(attr.Key), coordimapAttributePrefix) {
+			kept = append(kept, attr)
+		}
+	}
+
+	return kept
+}
@@ -0,0 +1,91 @@
+package cmotel
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTopologySampler(t *testing.T) {
+	sampler, err := NewTopologySampler(sdktrace.NeverSample(), WithTopologyStripping())
+	if err != nil {
+		t.Fatalf("NewTopologySampler() error = %v", err)
+	}
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithSampler(sampler),
+		sdktrace.WithSyncer(NewTopologyStrippingExporter(exporter)),
+	)
+	tracer := provider.Tracer("test")
+
+	_, plain := tracer.Start(context.Background(), "plain", trace.WithAttributes(attribute.String("http.method", "GET")))
+	plain.End()
+
+	_, topology := tracer.Start(context.Background(), "topology", trace.WithAttributes(
+		attribute.String("http.method", "GET"),
+		attribute.String(SpanAttrParentName, "cluster.namespace.service@parent"),
+	))
+	topology.AddEvent("dropped")
+	topology.End()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("got %d exported spans, want 1", len(spans))
+	}
+
+	if spans[0].Name != "topology" {
+		t.Errorf("exported span = %v, want topology", spans[0].Name)
+	}
+
+	want := map[attribute.Key]bool{SpanAttrParentName: true, SpanAttrTopologyOnly: true}
+	if len(spans[0].Attributes) != len(want) {
+		t.Errorf("exported attributes = %v, want only %v", spans[0].Attributes, want)
+	}
+	for _, attr := range spans[0].Attributes {
+		if !want[attr.Key] {
+			t.Errorf("attribute %s was not stripped", attr.Key)
+		}
+	}
+
+	if len(spans[0].Events) != 0 {
+		t.Errorf("exported events = %v, want none", spans[0].Events)
+	}
+}
+
+func TestTopologySamplerStartComponent(t *testing.T) {
+	t.Setenv(EnvServiceNamePrefix, "cluster.namespace")
+
+	sampler, err := NewTopologySampler(sdktrace.NeverSample())
+	if err != nil {
+		t.Fatalf("NewTopologySampler() error = %v", err)
+	}
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSampler(sampler), sdktrace.WithSyncer(exporter))
+	cm := New(provider.Tracer("test"), "orders")
+
+	cm.NewSpan(WithSpanName("late"))
+	if err := cm.AddComponent(WithAddComponentSpanName("late"), WithAddComponentType(ComponentTypePostgres)); err != nil {
+		t.Fatalf("AddComponent() error = %v", err)
+	}
+	cm.EndSpan("late")
+
+	if _, spanCtx := cm.NewSpan(WithSpanName("early"), WithSpanComponent(WithAddComponentType(ComponentTypePostgres))); spanCtx == context.TODO() {
+		t.Fatalf("NewSpan() did not create the span")
+	}
+	cm.EndSpan("early")
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 || spans[0].Name != "cluster.namespace.orders@early" {
+		t.Fatalf("exported spans = %v, want only the span with a start time component", spans)
+	}
+
+	if infos := cm.Spans(); len(infos) != 2 || len(infos[0].Components) != 1 || infos[0].Components[0].Type != ComponentTypePostgres {
+		t.Errorf("Spans() = %v, want the early span registered with its component", infos)
+	}
+}
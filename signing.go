@@ -0,0 +1,163 @@
+package cmotel
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// UnverifiedSpanMapPolicy decides what happens to the spans of a span map whose signature could not be verified
+type UnverifiedSpanMapPolicy string
+
+const (
+	// UnverifiedSpanMapDrop ignores all the spans of an unverified span map
+	UnverifiedSpanMapDrop UnverifiedSpanMapPolicy = "drop"
+
+	// UnverifiedSpanMapTag registers the spans but tags every relationship drawn from them with SpanAttrUnverified
+	UnverifiedSpanMapTag UnverifiedSpanMapPolicy = "tag"
+)
+
+const (
+	spanMapSignatureVersion = "v1"
+
+	// DefaultSpanMapSignatureTTL the default time a span map signature is valid for
+	DefaultSpanMapSignatureTTL = 5 * time.Minute
+
+	// DefaultSpanMapClockSkew the default tolerance when checking the expiry of a span map signature
+	DefaultSpanMapClockSkew = 30 * time.Second
+)
+
+var (
+	// ErrSpanMapSignatureMissing the span map was not signed
+	ErrSpanMapSignatureMissing = errors.New("the span map signature is missing")
+
+	// ErrSpanMapSignatureMalformed the signature header could not be parsed
+	ErrSpanMapSignatureMalformed = errors.New("the span map signature is malformed")
+
+	// ErrSpanMapSignatureUnknownKey the signature was created with a key ID the verifier does not know
+	ErrSpanMapSignatureUnknownKey = errors.New("the span map signature key is unknown")
+
+	// ErrSpanMapSignatureExpired the signature is past its expiry, including the clock skew
+	ErrSpanMapSignatureExpired = errors.New("the span map signature has expired")
+
+	// ErrSpanMapSignatureInvalid the signature does not match the span map
+	ErrSpanMapSignatureInvalid = errors.New("the span map signature is invalid")
+)
+
+// SpanMapKey a shared HMAC key. The ID is sent along with the signature so that keys can be rotated.
+type SpanMapKey struct {
+	ID     string
+	Secret []byte
+}
+
+// SignSpanMap signs an encoded span map header and returns the value of the signature header, v1;kid=<key id>;exp=<unix seconds>;sig=<base64url HMAC-SHA256>
+func SignSpanMap(spanMap string, key SpanMapKey, ttl time.Duration) (string, error) {
+	return signSpanMapAt(spanMap, key, time.Now().Add(ttl))
+}
+
+func signSpanMapAt(spanMap string, key SpanMapKey, expiresAt time.Time) (string, error) {
+	if key.ID == "" || strings.ContainsAny(key.ID, ";=") {
+		return "", errors.New("the key ID must not be empty nor contain ; or =")
+	}
+
+	if len(key.Secret) == 0 {
+		return "", errors.New("the key secret must not be empty")
+	}
+
+	expiry := strconv.FormatInt(expiresAt.Unix(), 10)
+
+	return fmt.Sprintf("%s;kid=%s;exp=%s;sig=%s", spanMapSignatureVersion, key.ID, expiry, computeSpanMapSignature(spanMap, key, expiry)), nil
+}
+
+func computeSpanMapSignature(spanMap string, key SpanMapKey, expiry string) string {
+	mac := hmac.New(sha256.New, key.Secret)
+	mac.Write([]byte(strings.Join([]string{spanMapSignatureVersion, key.ID, expiry, spanMap}, "\n")))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// SpanMapVerifier verifies span map signatures against a set of known keys
+type SpanMapVerifier struct {
+	keys      map[string]SpanMapKey
+	clockSkew time.Duration
+	now       func() time.Time
+}
+
+// NewSpanMapVerifier creates a verifier that accepts signatures created by any of the provided keys. Keeping the previous key next to the new one allows rotating keys without dropping requests.
+func NewSpanMapVerifier(clockSkew time.Duration, keys ...SpanMapKey) (*SpanMapVerifier, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("at least one key must be provided")
+	}
+
+	if clockSkew < 0 {
+		return nil, errors.New("the clock skew must not be negative")
+	}
+
+	verifier := &SpanMapVerifier{
+		keys:      map[string]SpanMapKey{},
+		clockSkew: clockSkew,
+		now:       time.Now,
+	}
+
+	for _, key := range keys {
+		if key.ID == "" || len(key.Secret) == 0 {
+			return nil, errors.New("every key must have an ID and a secret")
+		}
+
+		if _, exists := verifier.keys[key.ID]; exists {
+			return nil, fmt.Errorf("key %s is provided more than once", key.ID)
+		}
+
+		verifier.keys[key.ID] = key
+	}
+
+	return verifier, nil
+}
+
+// Verify checks that the signature was created for the span map by a known key and has not expired
+func (v *SpanMapVerifier) Verify(spanMap, signature string) error {
+	if signature == "" {
+		return ErrSpanMapSignatureMissing
+	}
+
+	parts := strings.Split(signature, ";")
+	if len(parts) != 4 || parts[0] != spanMapSignatureVersion {
+		return ErrSpanMapSignatureMalformed
+	}
+
+	values := map[string]string{}
+	for _, part := range parts[1:] {
+		pair := strings.SplitN(part, "=", 2)
+		if len(pair) != 2 {
+			return ErrSpanMapSignatureMalformed
+		}
+
+		values[pair[0]] = pair[1]
+	}
+
+	key, ok := v.keys[values["kid"]]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrSpanMapSignatureUnknownKey, values["kid"])
+	}
+
+	expiry, errExpiry := strconv.ParseInt(values["exp"], 10, 64)
+	if errExpiry != nil {
+		return errors.Join(ErrSpanMapSignatureMalformed, errExpiry)
+	}
+
+	expected := computeSpanMapSignature(spanMap, key, values["exp"])
+	if !hmac.Equal([]byte(expected), []byte(values["sig"])) {
+		return ErrSpanMapSignatureInvalid
+	}
+
+	if v.now().Add(-v.clockSkew).After(time.Unix(expiry, 0)) {
+		return ErrSpanMapSignatureExpired
+	}
+
+	return nil
+}
@@ -0,0 +1,90 @@
+package cmotel
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSpanMapVerifierVerify(t *testing.T) {
+	oldKey := SpanMapKey{ID: "2026-09", Secret: []byte("old-secret")}
+	newKey := SpanMapKey{ID: "2026-10", Secret: []byte("new-secret")}
+	unknownKey := SpanMapKey{ID: "other", Secret: []byte("other-secret")}
+	now := time.Unix(1760000000, 0)
+	spanMap := `{"cluster.namespace.service@span":"00-12345678901234567890123456789012-3456789012345678-01"}`
+
+	sign := func(key SpanMapKey, expiresAt time.Time) string {
+		signature, err := signSpanMapAt(spanMap, key, expiresAt)
+		if err != nil {
+			t.Fatalf("signSpanMapAt() error = %v", err)
+		}
+
+		return signature
+	}
+
+	tests := []struct {
+		name      string
+		spanMap   string
+		signature string
+		wantErr   error
+	}{
+		{
+			name:      "valid with current key",
+			spanMap:   spanMap,
+			signature: sign(newKey, now.Add(time.Minute)),
+		},
+		{
+			name:      "valid with previous key",
+			spanMap:   spanMap,
+			signature: sign(oldKey, now.Add(time.Minute)),
+		},
+		{
+			name:      "expired within clock skew",
+			spanMap:   spanMap,
+			signature: sign(newKey, now.Add(-10*time.Second)),
+		},
+		{
+			name:      "expired",
+			spanMap:   spanMap,
+			signature: sign(newKey, now.Add(-time.Minute)),
+			wantErr:   ErrSpanMapSignatureExpired,
+		},
+		{
+			name:      "tampered span map",
+			spanMap:   `{"cluster.namespace.attacker@span":"00-12345678901234567890123456789012-3456789012345678-01"}`,
+			signature: sign(newKey, now.Add(time.Minute)),
+			wantErr:   ErrSpanMapSignatureInvalid,
+		},
+		{
+			name:      "unknown key",
+			spanMap:   spanMap,
+			signature: sign(unknownKey, now.Add(time.Minute)),
+			wantErr:   ErrSpanMapSignatureUnknownKey,
+		},
+		{
+			name:    "missing",
+			spanMap: spanMap,
+			wantErr: ErrSpanMapSignatureMissing,
+		},
+		{
+			name:      "malformed",
+			spanMap:   spanMap,
+			signature: "v1;kid=2026-10",
+			wantErr:   ErrSpanMapSignatureMalformed,
+		},
+	}
+
+	verifier, err := NewSpanMapVerifier(30*time.Second, oldKey, newKey)
+	if err != nil {
+		t.Fatalf("NewSpanMapVerifier() error = %v", err)
+	}
+	verifier.now = func() time.Time { return now }
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := verifier.Verify(tt.spanMap, tt.signature); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
 	"strings"
 
 	"go.opentelemetry.io/otel/attribute"
@@ -43,7 +44,8 @@ func WithSpanContext(ctx context.Context) SpanOption {
 	}
 }
 
-// WithSpanName the name of the span. It must be a non empty string
+// WithSpanName the name of the span. It must be a non empty string without @, which separates the service from the span in internal names.
+// Under the DuplicateSpanNameSuffix and DuplicateSpanNameStack policies it must not end with SpanInstanceSeparator and a number either, e.g. retry#2, since it would read as an instance of another span.
 func WithSpanName(name string) SpanOption {
 	return func(opt *newSpanOpts) error {
 		if name == "" {
@@ -71,7 +73,50 @@ func WithParentSpanName(name string) SpanOption {
 	}
 }
 
+// WithSpanStartOptions extra otel options used when starting the span, e.g. trace.WithNewRoot() or trace.WithSpanKind()
+func WithSpanStartOptions(startOpts ...trace.SpanStartOption) SpanOption {
+	return func(opt *newSpanOpts) error {
+		opt.startOpts = append(opt.startOpts, startOpts...)
+
+		return nil
+	}
+}
+
+// WithSpanComponent registers the span as a component when it starts, see CMOtel.AddComponent. Unlike AddComponent, the component is seen by samplers such as NewTopologySampler.
+// The span options of AddComponent, WithAddComponentSpan and WithAddComponentSpanName, are ignored.
+func WithSpanComponent(opts ...addComponentOptionType) SpanOption {
+	return func(opt *newSpanOpts) error {
+		component := &addComponentOpts{
+			span:          nil,
+			componentType: "",
+			spanName:      "",
+			attributes:    []attribute.KeyValue{},
+			isContainer:   false,
+		}
+
+		for _, componentOpt := range opts {
+			if err := componentOpt(component); err != nil {
+				return errors.Join(errors.New("invalid component"), err)
+			}
+		}
+
+		opt.component = component
+
+		return nil
+	}
+}
+
 func (cm *cmOtel) NewSpan(opts ...SpanOption) (trace.Span, context.Context) {
+	_, span, ctx, errSpan := cm.newSpan(opts...)
+	if errSpan != nil {
+		return nil, context.TODO()
+	}
+
+	return span, ctx
+}
+
+// newSpan creates the span and returns the name it is registered under
+func (cm *cmOtel) newSpan(opts ...SpanOption) (string, trace.Span, context.Context, error) {
 	spanOpts := &newSpanOpts{
 		ctx:          context.Background(),
 		name:         "",
@@ -79,19 +124,40 @@ func (cm *cmOtel) NewSpan(opts ...SpanOption) (trace.Span, context.Context) {
 		to:           []string{},
 		internalFrom: []string{},
 		externalFrom: []string{},
+		startOpts:    []trace.SpanStartOption{},
+		component:    nil,
 	}
 	newSpanOpts := []trace.SpanStartOption{}
 
+	if cm.optionsErr != nil {
+		return "", nil, context.TODO(), errors.Join(errors.New("the CMOtel object has invalid options"), cm.optionsErr)
+	}
+
 	for _, opt := range opts {
-		opt(spanOpts)
+		if err := opt(spanOpts); err != nil {
+			return "", nil, context.TODO(), err
+		}
+	}
+
+	if errName := cm.checkInstanceName(spanOpts.name); errName != nil {
+		return "", nil, context.TODO(), errName
 	}
 
+	newSpanOpts = append(newSpanOpts, spanOpts.startOpts...)
+
 	hasParentConfig := false
 	parentSpanID := ""
 
+	for _, from := range append(append([]string{}, spanOpts.internalFrom...), spanOpts.externalFrom...) {
+		if !cm.SpanExists(from) {
+			cm.lint(LintUnknownRelationshipSource, spanOpts.name, "the relationship source %s does not exist", from)
+		}
+	}
+
 	if spanOpts.parentName != "" {
 		if !cm.SpanExists(spanOpts.parentName) {
-			return nil, context.TODO()
+			cm.lint(LintUnknownParent, spanOpts.name, "the parent %s does not exist so the span was not created", spanOpts.parentName)
+			return "", nil, context.TODO(), fmt.Errorf("parent span %s does not exist", spanOpts.parentName)
 		}
 
 		spanOpts.ctx = cm.spans[spanOpts.parentName].ctx
@@ -113,31 +179,48 @@ func (cm *cmOtel) NewSpan(opts ...SpanOption) (trace.Span, context.Context) {
 		}
 	}
 
+	parentInternalName := ""
 	if hasParentConfig {
+		parentInternalName = cm.generateInternalName(spanOpts.parentName)
+
+		if cm.spans[spanOpts.parentName].unverified {
+			newSpanOpts = append(newSpanOpts, trace.WithAttributes(attribute.Bool(SpanAttrUnverified, true)))
+		}
+	} else if internalName, ok := InternalNameFromContext(spanOpts.ctx); ok {
+		// the parent was created by other instrumentation or another CMOtel object
+		parentInternalName = internalName
+	}
+
+	if parentInternalName != "" {
 		// needed to generate the respective relationship
 		newSpanOpts = append(newSpanOpts, trace.WithAttributes(attribute.KeyValue{
 			Key:   SpanAttrParentName,
-			Value: attribute.StringValue(cm.generateInternalName(spanOpts.parentName)),
+			Value: attribute.StringValue(parentInternalName),
 		}))
 	}
 
 	spanLinks := []trace.Link{}
 
+	// a link to a span that does not exist has an invalid span context and would be dropped by the SDK anyway
 	for _, internalFrom := range spanOpts.internalFrom {
+		if !cm.SpanExists(internalFrom) {
+			continue
+		}
+
 		spanLinks = append(spanLinks, trace.Link{
 			SpanContext: trace.SpanContextFromContext(cm.spans[internalFrom].ctx),
-			Attributes: []attribute.KeyValue{
-				attribute.String(SpanAttrRelationship, fmt.Sprintf("%s@@@%s", cm.generateInternalName(internalFrom), cm.generateInternalName(spanOpts.name))),
-			},
+			Attributes:  cm.relationshipAttributes(internalFrom, FormatRelationship(cm.generateInternalName(internalFrom), cm.generateInternalName(spanOpts.name))),
 		})
 	}
 
 	for _, from := range spanOpts.externalFrom {
+		if !cm.SpanExists(from) {
+			continue
+		}
+
 		spanLinks = append(spanLinks, trace.Link{
 			SpanContext: trace.SpanContextFromContext(cm.spans[from].ctx),
-			Attributes: []attribute.KeyValue{
-				attribute.String(SpanAttrRelationship, fmt.Sprintf("%s@@@%s", from, cm.generateInternalName(spanOpts.name))),
-			},
+			Attributes:  cm.relationshipAttributes(from, FormatRelationship(from, cm.generateInternalName(spanOpts.name))),
 		})
 	}
 
@@ -145,20 +228,76 @@ func (cm *cmOtel) NewSpan(opts ...SpanOption) (trace.Span, context.Context) {
 		newSpanOpts = append(newSpanOpts, trace.WithLinks(spanLinks...))
 	}
 
+	key, errKey := cm.spanKey(spanOpts.name)
+	if errKey != nil {
+		cm.lint(LintDuplicateSpanName, spanOpts.name, "the span was not created: %s", errKey.Error())
+		return "", nil, context.TODO(), errKey
+	}
+
+	components := []CMComponent{}
+	if spanOpts.component != nil {
+		component, componentAttribute, errComponent := cm.newComponent(key, spanOpts.component)
+		if errComponent != nil {
+			return "", nil, context.TODO(), errComponent
+		}
+
+		components = append(components, component)
+		newSpanOpts = append(newSpanOpts, trace.WithAttributes(componentAttribute))
+	}
+
 	ctx, span := cm.tracer.Start(
 		spanOpts.ctx,
 		cm.generateInternalName(spanOpts.name),
 		newSpanOpts...,
 	)
+	ctx = ContextWithInternalName(ctx, cm.generateInternalName(spanOpts.name))
 
-	cm.spans[spanOpts.name] = cmSpan{
-		ctx:  ctx,
-		span: span,
+	cm.spans[key] = cmSpan{
+		ctx:           ctx,
+		span:          span,
+		unverified:    false,
+		relationships: &[]string{},
 	}
+	cm.pushInstance(spanOpts.name, key)
 
-	cm.spanIDToNameMapper[cm.spans[spanOpts.name].span.SpanContext().SpanID().String()] = spanOpts.name
+	cm.spanIDToNameMapper[cm.spans[key].span.SpanContext().SpanID().String()] = key
 
-	return span, ctx
+	relationships := []string{}
+	for _, link := range spanLinks {
+		for _, attr := range link.Attributes {
+			if attr.Key == SpanAttrRelationship {
+				relationships = append(relationships, attr.Value.AsString())
+			}
+		}
+	}
+
+	cm.registry.register(SpanInfo{
+		Name:               key,
+		InternalName:       cm.generateInternalName(spanOpts.name),
+		TraceID:            span.SpanContext().TraceID().String(),
+		SpanID:             span.SpanContext().SpanID().String(),
+		ParentName:         spanOpts.parentName,
+		ParentInternalName: parentInternalName,
+		Remote:             false,
+		Ended:              false,
+		Unverified:         false,
+		Components:         components,
+		Relationships:      relationships,
+	}, span)
+
+	return key, span, ctx, nil
+}
+
+func (cm *cmOtel) relationshipAttributes(from, relationship string) []attribute.KeyValue {
+	attributes := []attribute.KeyValue{
+		attribute.String(SpanAttrRelationship, relationship),
+	}
+
+	if cm.spans[from].unverified {
+		attributes = append(attributes, attribute.Bool(SpanAttrUnverified, true))
+	}
+
+	return attributes
 }
 
 func (cm *cmOtel) SpanExists(name string) bool {
@@ -170,13 +309,24 @@ func (cm *cmOtel) SpanExists(name string) bool {
 }
 
 func (cm *cmOtel) EndSpan(name string, opts ...trace.SpanEndOption) error {
+	name = cm.resolveInstance(name)
+
 	span, ok := cm.spans[name]
 	if !ok {
 		return fmt.Errorf("span %s does not exist", name)
 	}
 
+	if cm.registry.ended(name) {
+		cm.lint(LintSpanEndedTwice, name, "the span had already ended")
+	}
+
 	span.span.End(opts...)
 
+	cm.registry.update(name, func(info *SpanInfo) {
+		info.Ended = true
+	})
+	cm.popInstance(name)
+
 	return nil
 }
 
@@ -219,6 +369,39 @@ func WithAddComponentType(componentType string) addComponentOptionType {
 	}
 }
 
+// WithAddComponentContainer marks the component as a container that encloses the components of the child spans
+func WithAddComponentContainer() addComponentOptionType {
+	return func(opt *addComponentOpts) error {
+		opt.isContainer = true
+
+		return nil
+	}
+}
+
+// WithAddComponentFromAttributes derives the type and data of the component from the semantic convention attributes with the first matching rule, or the DefaultComponentRules when rules is nil. An explicit WithAddComponentType takes precedence over the type of the rule.
+func WithAddComponentFromAttributes(rules *ComponentRules, attributes ...attribute.KeyValue) addComponentOptionType {
+	return func(opt *addComponentOpts) error {
+		if rules == nil {
+			rules = DefaultComponentRules()
+		}
+
+		match, ok := rules.Match(trace.SpanKindUnspecified, attributes)
+		if !ok {
+			return errors.New("no component rule matches the attributes")
+		}
+
+		if opt.componentType == "" {
+			opt.componentType = match.Type
+		}
+
+		for key, value := range match.Data {
+			opt.attributes = append(opt.attributes, attribute.String(key, value))
+		}
+
+		return nil
+	}
+}
+
 // WithAddComponentAttribute extra attributes to add to the component
 func WithAddComponentAttribute(attribute attribute.KeyValue) addComponentOptionType {
 	return func(opt *addComponentOpts) error {
@@ -271,31 +454,45 @@ func (cm *cmOtel) AddComponent(opts ...addComponentOptionType) error {
 		return fmt.Errorf("span %s does not exist", options.spanName)
 	}
 
+	if cm.registry.ended(options.spanName) {
+		cm.lint(LintComponentOnEndedSpan, options.spanName, "the component %s is added after the span ended so it will not be exported", options.componentType)
+	}
+
+	newComponent, componentAttribute, errComponent := cm.newComponent(options.spanName, options)
+	if errComponent != nil {
+		return errComponent
+	}
+
+	options.span.SetAttributes(componentAttribute)
+
+	cm.registry.update(options.spanName, func(info *SpanInfo) {
+		info.Components = append(info.Components, newComponent)
+	})
+
+	return nil
+}
+
+// newComponent returns the component of the span registered under spanName along with its SpanAttrComponent attribute
+func (cm *cmOtel) newComponent(spanName string, options *addComponentOpts) (CMComponent, attribute.KeyValue, error) {
 	newComponentData := map[string]string{}
 	for _, attr := range options.attributes {
 		newComponentData[string(attr.Key)] = attr.Value.AsString()
 	}
 
 	newComponent := CMComponent{
-		InternalID: cm.generateInternalName(options.spanName),
-		Name:       options.spanName,
-		Type:       options.componentType,
-		Data:       newComponentData,
+		InternalID:  cm.generateInternalName(spanName),
+		Name:        spanInstanceBase(spanName),
+		Type:        options.componentType,
+		Data:        newComponentData,
+		IsContainer: options.isContainer || options.componentType == ComponentTypeGenericContainer,
 	}
 
 	marshaledNewComponent, errMarshaledNewComponent := json.Marshal(newComponent)
 	if errMarshaledNewComponent != nil {
-		return errors.Join(errors.New("cannot marshal the component"), errMarshaledNewComponent)
+		return CMComponent{}, attribute.KeyValue{}, errors.Join(errors.New("cannot marshal the component"), errMarshaledNewComponent)
 	}
 
-	options.span.SetAttributes([]attribute.KeyValue{
-		{
-			Key:   SpanAttrComponent,
-			Value: attribute.StringValue(string(marshaledNewComponent)),
-		},
-	}...)
-
-	return nil
+	return newComponent, attribute.String(SpanAttrComponent, string(marshaledNewComponent)), nil
 }
 
 func (cm *cmOtel) generateInternalName(name string) string {
@@ -303,7 +500,7 @@ func (cm *cmOtel) generateInternalName(name string) string {
 		return name
 	}
 
-	return fmt.Sprintf("%s@%s", GetServiceName(cm.serviceName), name)
+	return fmt.Sprintf("%s@%s", GetServiceName(cm.serviceName), spanInstanceBase(name))
 }
 
 // AddRemoteSpanCtx load the remote span context and name in the library in order to use it for relationships
@@ -313,9 +510,24 @@ func (cm *cmOtel) AddRemoteSpanCtx(spanCtx context.Context, spanName string) err
 	}
 
 	cm.spans[spanName] = cmSpan{
-		ctx:  spanCtx,
-		span: trace.SpanFromContext(spanCtx),
-	}
+		ctx:           ContextWithInternalName(spanCtx, cm.generateInternalName(spanName)),
+		span:          trace.SpanFromContext(spanCtx),
+		unverified:    false,
+		relationships: &[]string{},
+	}
+
+	spanCtxValue := trace.SpanContextFromContext(spanCtx)
+	cm.registry.register(SpanInfo{
+		Name:          spanName,
+		InternalName:  cm.generateInternalName(spanName),
+		TraceID:       spanCtxValue.TraceID().String(),
+		SpanID:        spanCtxValue.SpanID().String(),
+		Remote:        spanCtxValue.IsRemote(),
+		Ended:         false,
+		Unverified:    false,
+		Components:    []CMComponent{},
+		Relationships: []string{},
+	}, trace.SpanFromContext(spanCtx))
 
 	return nil
 }
@@ -348,17 +560,126 @@ func (cm *cmOtel) GetSpanTraceparentMaps(spanNames []string) (map[string]string,
 	return allSpans, nil
 }
 
-// GetSpanTraceparent returns the traceparent string for an existing span
+// InjectSpanHeaders sets the span map header for the provided span names, the baggage with the identity of this service and the trace headers of the first span, which is considered the direct parent of the outgoing call, in all the configured formats
+func (cm *cmOtel) InjectSpanHeaders(header http.Header, spanNames []string) error {
+	if len(spanNames) == 0 {
+		return errors.New("at least one span name must be provided")
+	}
+
+	spanMap, errSpanMap := cm.GetSpanTraceparentMaps(spanNames)
+	if errSpanMap != nil {
+		return errSpanMap
+	}
+
+	formats := cm.headerFormats
+	if len(formats) == 0 {
+		formats = []TraceHeaderFormat{TraceHeaderFormatW3C}
+	}
+
+	spanMapFormat := TraceHeaderFormatW3C
+	for _, format := range formats {
+		if format != TraceHeaderFormatB3Multi {
+			spanMapFormat = format
+			break
+		}
+	}
+
+	marshaledSpanMap, errMarshal := MarshalSpanMap(spanMap, append([]SpanMapOption{WithSpanMapFormat(spanMapFormat)}, cm.spanMapOpts...)...)
+	if errMarshal != nil {
+		return errors.Join(errors.New("could not marshal the span map"), errMarshal)
+	}
+
+	header.Set(EnvTraceParentsMapHeaderName, marshaledSpanMap)
+
+	if cm.signingKey != nil {
+		signature, errSign := SignSpanMap(marshaledSpanMap, *cm.signingKey, cm.signingTTL)
+		if errSign != nil {
+			return errors.Join(errors.New("could not sign the span map"), errSign)
+		}
+
+		header.Set(EnvTraceParentsMapSignatureHeaderName, signature)
+	}
+
+	if cm.serviceName != "" {
+		// the pod name goes to every downstream service so it is only sent when asked to
+		identity := LocalServiceIdentity(cm.serviceName)
+		if !cm.podBaggage {
+			identity.Pod = ""
+		}
+
+		if errBaggage := InjectServiceBaggage(cm.spans[spanNames[0]].ctx, identity, header); errBaggage != nil {
+			return errors.Join(errors.New("could not set the service baggage"), errBaggage)
+		}
+	}
+
+	return InjectTraceHeaders(cm.spans[spanNames[0]].span.SpanContext(), header, formats...)
+}
+
+// SetSpanFromTraceparent loads a remote span from a W3C traceparent
 func (cm *cmOtel) SetSpanFromTraceparent(name, traceparent string) error {
+	return cm.setSpanFromParsedHeader(name, traceparent, ParseTraceParent)
+}
+
+// SetSpanFromB3 loads a remote span from a B3 single header value
+func (cm *cmOtel) SetSpanFromB3(name, b3 string) error {
+	return cm.setSpanFromParsedHeader(name, b3, ParseB3)
+}
+
+// SetSpanFromUberTraceID loads a remote span from a Jaeger uber-trace-id header value
+func (cm *cmOtel) SetSpanFromUberTraceID(name, uberTraceID string) error {
+	return cm.setSpanFromParsedHeader(name, uberTraceID, ParseUberTraceID)
+}
+
+// SetSpanFromTraceHeader loads a remote span from a traceparent, B3 single header or uber-trace-id value by detecting its format
+func (cm *cmOtel) SetSpanFromTraceHeader(name, value string) error {
+	return cm.setSpanFromParsedHeader(name, value, ParseTraceHeader)
+}
+
+// SetSpanFromB3MultiHeader loads a remote span from the X-B3-* headers
+func (cm *cmOtel) SetSpanFromB3MultiHeader(name string, header http.Header) error {
+	if cm.SpanExists(name) {
+		return nil
+	}
+
+	ctx, errParse := ParseB3MultiHeader(header)
+	if errParse != nil {
+		return errors.Join(errors.New("could not parse the provided b3 headers"), errParse)
+	}
+
+	return cm.setRemoteSpan(name, ctx)
+}
+
+// MarkSpanUnverified marks a remote span as coming from an unverified span map. Every relationship drawn from it gets the SpanAttrUnverified attribute.
+func (cm *cmOtel) MarkSpanUnverified(name string) error {
+	span, ok := cm.spans[name]
+	if !ok {
+		return fmt.Errorf("span %s does not exist", name)
+	}
+
+	span.unverified = true
+	cm.spans[name] = span
+
+	cm.registry.update(name, func(info *SpanInfo) {
+		info.Unverified = true
+	})
+
+	return nil
+}
+
+func (cm *cmOtel) setSpanFromParsedHeader(name, value string, parse func(string) (context.Context, error)) error {
 	if cm.SpanExists(name) {
 		return nil
 	}
 
-	ctx, errParseTraceparent := ParseTraceParent(traceparent)
-	if errParseTraceparent != nil {
-		return errors.Join(errors.New("could not parse the provided traceparent"), errParseTraceparent)
+	ctx, errParse := parse(value)
+	if errParse != nil {
+		return errors.Join(errors.New("could not parse the provided trace header"), errParse)
 	}
 
+	return cm.setRemoteSpan(name, ctx)
+}
+
+func (cm *cmOtel) setRemoteSpan(name string, ctx context.Context) error {
 	if errAddRemoteSpan := cm.AddRemoteSpanCtx(ctx, name); errAddRemoteSpan != nil {
 		return errors.Join(fmt.Errorf("could not add remote span with name %s", name), errAddRemoteSpan)
 	}
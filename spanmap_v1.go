@@ -0,0 +1,184 @@
+package cmotel
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SpanMapVersionPrefixV1 the prefix of span map headers using SpanMapEncodingV1. The rest of the header is the base64url encoded payload.
+const SpanMapVersionPrefixV1 = "cm1."
+
+const (
+	spanMapV1FlagCompressed byte = 0x01
+
+	// limits the size of a decompressed payload so that a small header cannot expand without bounds
+	spanMapV1MaxDecodedSize = 1 << 20
+)
+
+// encodeSpanMapV1 writes the payload as
+//
+//	flags byte
+//	uvarint number of trace IDs, followed by the 16 byte trace IDs
+//	uvarint number of spans, followed by each span as
+//	  uvarint index of its trace ID, 8 byte span ID, trace flags byte, uvarint name length, name
+//
+// where everything after the flags byte is deflated when compression is enabled.
+func encodeSpanMapV1(spans map[string]string, names []string, compress bool) (string, error) {
+	traceIndexes := map[trace.TraceID]uint64{}
+	traceIDs := []trace.TraceID{}
+	spanCtxs := make([]trace.SpanContext, 0, len(names))
+
+	for _, name := range names {
+		ctx, errParse := ParseTraceHeader(spans[name])
+		if errParse != nil {
+			return "", errors.Join(fmt.Errorf("could not parse the span %s", name), errParse)
+		}
+
+		spanCtx := trace.SpanContextFromContext(ctx)
+		if _, ok := traceIndexes[spanCtx.TraceID()]; !ok {
+			traceIndexes[spanCtx.TraceID()] = uint64(len(traceIDs))
+			traceIDs = append(traceIDs, spanCtx.TraceID())
+		}
+
+		spanCtxs = append(spanCtxs, spanCtx)
+	}
+
+	body := []byte{}
+	body = binary.AppendUvarint(body, uint64(len(traceIDs)))
+	for _, traceID := range traceIDs {
+		body = append(body, traceID[:]...)
+	}
+
+	body = binary.AppendUvarint(body, uint64(len(names)))
+	for i, name := range names {
+		spanID := spanCtxs[i].SpanID()
+
+		body = binary.AppendUvarint(body, traceIndexes[spanCtxs[i].TraceID()])
+		body = append(body, spanID[:]...)
+		body = append(body, byte(spanCtxs[i].TraceFlags()))
+		body = binary.AppendUvarint(body, uint64(len(name)))
+		body = append(body, name...)
+	}
+
+	flags := byte(0)
+	if compress {
+		var compressed bytes.Buffer
+
+		writer, errWriter := flate.NewWriter(&compressed, flate.BestCompression)
+		if errWriter != nil {
+			return "", errors.Join(errors.New("could not create the compressor"), errWriter)
+		}
+
+		if _, errWrite := writer.Write(body); errWrite != nil {
+			return "", errors.Join(errors.New("could not compress the span map"), errWrite)
+		}
+
+		if errClose := writer.Close(); errClose != nil {
+			return "", errors.Join(errors.New("could not compress the span map"), errClose)
+		}
+
+		flags |= spanMapV1FlagCompressed
+		body = compressed.Bytes()
+	}
+
+	payload := append([]byte{flags}, body...)
+
+	return SpanMapVersionPrefixV1 + base64.RawURLEncoding.EncodeToString(payload), nil
+}
+
+func decodeSpanMapV1(encoded string) (map[string]string, error) {
+	payload, errDecode := base64.RawURLEncoding.DecodeString(encoded)
+	if errDecode != nil {
+		return map[string]string{}, errors.Join(errors.New("could not decode the base64 payload"), errDecode)
+	}
+
+	if len(payload) == 0 {
+		return map[string]string{}, errors.New("the span map payload is empty")
+	}
+
+	var body io.Reader = bytes.NewReader(payload[1:])
+	if payload[0]&spanMapV1FlagCompressed != 0 {
+		body = flate.NewReader(body)
+	}
+
+	reader := bufio.NewReader(io.LimitReader(body, spanMapV1MaxDecodedSize))
+
+	traceCount, errTraceCount := binary.ReadUvarint(reader)
+	if errTraceCount != nil {
+		return map[string]string{}, errors.Join(errors.New("could not read the number of trace ids"), errTraceCount)
+	}
+
+	traceIDs := []trace.TraceID{}
+	for i := uint64(0); i < traceCount; i++ {
+		var traceID trace.TraceID
+		if _, errRead := io.ReadFull(reader, traceID[:]); errRead != nil {
+			return map[string]string{}, errors.Join(errors.New("could not read the trace id"), errRead)
+		}
+
+		traceIDs = append(traceIDs, traceID)
+	}
+
+	spanCount, errSpanCount := binary.ReadUvarint(reader)
+	if errSpanCount != nil {
+		return map[string]string{}, errors.Join(errors.New("could not read the number of spans"), errSpanCount)
+	}
+
+	decodedMap := map[string]string{}
+	for i := uint64(0); i < spanCount; i++ {
+		traceIndex, errTraceIndex := binary.ReadUvarint(reader)
+		if errTraceIndex != nil {
+			return map[string]string{}, errors.Join(errors.New("could not read the trace id index"), errTraceIndex)
+		}
+
+		if traceIndex >= uint64(len(traceIDs)) {
+			return map[string]string{}, fmt.Errorf("trace id index %d is out of range", traceIndex)
+		}
+
+		var spanID trace.SpanID
+		if _, errRead := io.ReadFull(reader, spanID[:]); errRead != nil {
+			return map[string]string{}, errors.Join(errors.New("could not read the span id"), errRead)
+		}
+
+		traceFlags, errFlags := reader.ReadByte()
+		if errFlags != nil {
+			return map[string]string{}, errors.Join(errors.New("could not read the trace flags"), errFlags)
+		}
+
+		nameLength, errNameLength := binary.ReadUvarint(reader)
+		if errNameLength != nil {
+			return map[string]string{}, errors.Join(errors.New("could not read the span name length"), errNameLength)
+		}
+
+		if nameLength > spanMapV1MaxDecodedSize {
+			return map[string]string{}, fmt.Errorf("span name length %d is too large", nameLength)
+		}
+
+		name := make([]byte, nameLength)
+		if _, errRead := io.ReadFull(reader, name); errRead != nil {
+			return map[string]string{}, errors.Join(errors.New("could not read the span name"), errRead)
+		}
+
+		spanCtx := trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    traceIDs[traceIndex],
+			SpanID:     spanID,
+			TraceFlags: trace.TraceFlags(traceFlags),
+		})
+
+		traceparent, errFormat := FormatTraceHeader(spanCtx, TraceHeaderFormatW3C)
+		if errFormat != nil {
+			return map[string]string{}, errors.Join(fmt.Errorf("invalid span %s", name), errFormat)
+		}
+
+		decodedMap[string(name)] = traceparent
+	}
+
+	return decodedMap, nil
+}
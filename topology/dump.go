@@ -0,0 +1,295 @@
+package topology
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ReadDump decodes the spans of a trace dump. The reader may hold any number of JSON documents, one per line or pretty printed, each being either:
+//   - an OTLP/JSON export request, {"resourceSpans": [...]}, as written by the collector file exporter
+//   - a span as written by the SDK stdout exporter, {"Name": ..., "SpanContext": ...}
+func ReadDump(r io.Reader) ([]Span, error) {
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+
+	spans := []Span{}
+
+	for document := 1; ; document++ {
+		raw := json.RawMessage{}
+		if errDecode := decoder.Decode(&raw); errDecode != nil {
+			if errors.Is(errDecode, io.EOF) {
+				return spans, nil
+			}
+
+			return nil, errors.Join(fmt.Errorf("could not decode JSON document %d", document), errDecode)
+		}
+
+		probe := struct {
+			ResourceSpans json.RawMessage `json:"resourceSpans"`
+			SpanContext   json.RawMessage `json:"SpanContext"`
+		}{}
+		if errProbe := json.Unmarshal(raw, &probe); errProbe != nil {
+			return nil, errors.Join(fmt.Errorf("JSON document %d is not an object", document), errProbe)
+		}
+
+		switch {
+		case probe.ResourceSpans != nil:
+			otlpSpans, errOTLP := decodeOTLP(raw)
+			if errOTLP != nil {
+				return nil, errors.Join(fmt.Errorf("could not decode the OTLP/JSON document %d", document), errOTLP)
+			}
+
+			spans = append(spans, otlpSpans...)
+
+		case probe.SpanContext != nil:
+			stdoutSpan, errStdout := decodeStdout(raw)
+			if errStdout != nil {
+				return nil, errors.Join(fmt.Errorf("could not decode the stdout exporter span %d", document), errStdout)
+			}
+
+			spans = append(spans, stdoutSpan)
+
+		default:
+			return nil, fmt.Errorf("JSON document %d is neither an OTLP/JSON export request nor a stdout exporter span", document)
+		}
+	}
+}
+
+type otlpRequest struct {
+	ResourceSpans []struct {
+		Resource struct {
+			Attributes []otlpAttribute `json:"attributes"`
+		} `json:"resource"`
+		ScopeSpans []struct {
+			Spans []otlpSpan `json:"spans"`
+		} `json:"scopeSpans"`
+	} `json:"resourceSpans"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	Name              string          `json:"name"`
+	StartTimeUnixNano json.Number     `json:"startTimeUnixNano"`
+	EndTimeUnixNano   json.Number     `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes"`
+	Links             []struct {
+		TraceID    string          `json:"traceId"`
+		SpanID     string          `json:"spanId"`
+		Attributes []otlpAttribute `json:"attributes"`
+	} `json:"links"`
+	Status struct {
+		Code json.RawMessage `json:"code"`
+	} `json:"status"`
+}
+
+type otlpAttribute struct {
+	Key   string `json:"key"`
+	Value struct {
+		StringValue *string      `json:"stringValue"`
+		BoolValue   *bool        `json:"boolValue"`
+		IntValue    *json.Number `json:"intValue"`
+		DoubleValue *json.Number `json:"doubleValue"`
+		ArrayValue  *struct {
+			Values []struct {
+				StringValue *string `json:"stringValue"`
+			} `json:"values"`
+		} `json:"arrayValue"`
+	} `json:"value"`
+}
+
+func decodeOTLP(raw json.RawMessage) ([]Span, error) {
+	request := otlpRequest{}
+	if errUnmarshal := json.Unmarshal(raw, &request); errUnmarshal != nil {
+		return nil, errUnmarshal
+	}
+
+	spans := []Span{}
+
+	for _, resourceSpans := range request.ResourceSpans {
+		resourceAttributes := otlpAttributes(resourceSpans.Resource.Attributes)
+
+		for _, scopeSpans := range resourceSpans.ScopeSpans {
+			for _, s := range scopeSpans.Spans {
+				span := Span{
+					Name:       s.Name,
+					TraceID:    s.TraceID,
+					SpanID:     s.SpanID,
+					StartTime:  unixNano(s.StartTimeUnixNano),
+					EndTime:    unixNano(s.EndTimeUnixNano),
+					Error:      isOTLPError(s.Status.Code),
+					Attributes: otlpAttributes(s.Attributes),
+					Links:      make([]Link, 0, len(s.Links)),
+					Resource:   resourceAttributes,
+				}
+
+				for _, link := range s.Links {
+					span.Links = append(span.Links, Link{
+						TraceID:    link.TraceID,
+						SpanID:     link.SpanID,
+						Attributes: otlpAttributes(link.Attributes),
+					})
+				}
+
+				spans = append(spans, span)
+			}
+		}
+	}
+
+	return spans, nil
+}
+
+// otlpAttributes converts the scalar and string array attributes, the only ones Coordimap uses, and skips the rest
+func otlpAttributes(attributes []otlpAttribute) []attribute.KeyValue {
+	converted := make([]attribute.KeyValue, 0, len(attributes))
+
+	for _, attr := range attributes {
+		switch {
+		case attr.Value.StringValue != nil:
+			converted = append(converted, attribute.String(attr.Key, *attr.Value.StringValue))
+		case attr.Value.BoolValue != nil:
+			converted = append(converted, attribute.Bool(attr.Key, *attr.Value.BoolValue))
+		case attr.Value.IntValue != nil:
+			if value, errParse := attr.Value.IntValue.Int64(); errParse == nil {
+				converted = append(converted, attribute.Int64(attr.Key, value))
+			}
+		case attr.Value.DoubleValue != nil:
+			if value, errParse := attr.Value.DoubleValue.Float64(); errParse == nil {
+				converted = append(converted, attribute.Float64(attr.Key, value))
+			}
+		case attr.Value.ArrayValue != nil:
+			values := make([]string, 0, len(attr.Value.ArrayValue.Values))
+			for _, value := range attr.Value.ArrayValue.Values {
+				if value.StringValue != nil {
+					values = append(values, *value.StringValue)
+				}
+			}
+
+			converted = append(converted, attribute.StringSlice(attr.Key, values))
+		}
+	}
+
+	return converted
+}
+
+// isOTLPError the status code is either the number 2 or its enum name
+func isOTLPError(code json.RawMessage) bool {
+	switch string(code) {
+	case "2", `"STATUS_CODE_ERROR"`:
+		return true
+	}
+
+	return false
+}
+
+func unixNano(value json.Number) time.Time {
+	nanos, errParse := strconv.ParseInt(value.String(), 10, 64)
+	if errParse != nil || nanos == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(0, nanos)
+}
+
+type stdoutSpanContext struct {
+	TraceID string `json:"TraceID"`
+	SpanID  string `json:"SpanID"`
+}
+
+type stdoutAttribute struct {
+	Key   string `json:"Key"`
+	Value struct {
+		Type  string          `json:"Type"`
+		Value json.RawMessage `json:"Value"`
+	} `json:"Value"`
+}
+
+type stdoutSpan struct {
+	Name        string            `json:"Name"`
+	SpanContext stdoutSpanContext `json:"SpanContext"`
+	StartTime   time.Time         `json:"StartTime"`
+	EndTime     time.Time         `json:"EndTime"`
+	Attributes  []stdoutAttribute `json:"Attributes"`
+	Links       []struct {
+		SpanContext stdoutSpanContext `json:"SpanContext"`
+		Attributes  []stdoutAttribute `json:"Attributes"`
+	} `json:"Links"`
+	Status struct {
+		Code string `json:"Code"`
+	} `json:"Status"`
+	Resource []stdoutAttribute `json:"Resource"`
+}
+
+func decodeStdout(raw json.RawMessage) (Span, error) {
+	s := stdoutSpan{}
+	if errUnmarshal := json.Unmarshal(raw, &s); errUnmarshal != nil {
+		return Span{}, errUnmarshal
+	}
+
+	span := Span{
+		Name:       s.Name,
+		TraceID:    s.SpanContext.TraceID,
+		SpanID:     s.SpanContext.SpanID,
+		StartTime:  s.StartTime,
+		EndTime:    s.EndTime,
+		Error:      s.Status.Code == "Error",
+		Attributes: stdoutAttributes(s.Attributes),
+		Links:      make([]Link, 0, len(s.Links)),
+		Resource:   stdoutAttributes(s.Resource),
+	}
+
+	for _, link := range s.Links {
+		span.Links = append(span.Links, Link{
+			TraceID:    link.SpanContext.TraceID,
+			SpanID:     link.SpanContext.SpanID,
+			Attributes: stdoutAttributes(link.Attributes),
+		})
+	}
+
+	return span, nil
+}
+
+// stdoutAttributes converts the scalar and string slice attributes, the only ones Coordimap uses, and skips the rest
+func stdoutAttributes(attributes []stdoutAttribute) []attribute.KeyValue {
+	converted := make([]attribute.KeyValue, 0, len(attributes))
+
+	for _, attr := range attributes {
+		var errValue error
+
+		switch attr.Value.Type {
+		case "STRING":
+			var value string
+			if errValue = json.Unmarshal(attr.Value.Value, &value); errValue == nil {
+				converted = append(converted, attribute.String(attr.Key, value))
+			}
+		case "BOOL":
+			var value bool
+			if errValue = json.Unmarshal(attr.Value.Value, &value); errValue == nil {
+				converted = append(converted, attribute.Bool(attr.Key, value))
+			}
+		case "INT64":
+			var value int64
+			if errValue = json.Unmarshal(attr.Value.Value, &value); errValue == nil {
+				converted = append(converted, attribute.Int64(attr.Key, value))
+			}
+		case "FLOAT64":
+			var value float64
+			if errValue = json.Unmarshal(attr.Value.Value, &value); errValue == nil {
+				converted = append(converted, attribute.Float64(attr.Key, value))
+			}
+		case "STRINGSLICE":
+			var value []string
+			if errValue = json.Unmarshal(attr.Value.Value, &value); errValue == nil {
+				converted = append(converted, attribute.StringSlice(attr.Key, value))
+			}
+		}
+	}
+
+	return converted
+}
@@ -0,0 +1,96 @@
+package topology
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	cmotel "github.com/coordimap/cm-otel-go"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+const otlpDump = `{"resourceSpans":[{"resource":{},"scopeSpans":[{"scope":{"name":"test"},"spans":[
+{"traceId":"4bf92f3577b34da6a3ce929d0e0e4736","spanId":"00f067aa0ba902b7","name":"cluster.namespace.orders@query","startTimeUnixNano":"1760000000000000000","endTimeUnixNano":"1760000000040000000",
+"attributes":[{"key":"coordimap.span_attr.parent_name","value":{"stringValue":"cluster.namespace.orders@handler"}}],
+"links":[{"traceId":"4bf92f3577b34da6a3ce929d0e0e4736","spanId":"00f067aa0ba902b8","attributes":[{"key":"coordimap.span_attr.relationship","value":{"stringValue":"cluster.namespace.gateway@request@@@cluster.namespace.orders@query"}}]}],
+"status":{"code":2}}]}]}]}
+`
+
+func TestReadDump(t *testing.T) {
+	t.Setenv(cmotel.EnvServiceNamePrefix, "cluster.namespace")
+
+	stdoutDump := &bytes.Buffer{}
+	exporter, errExporter := stdouttrace.New(stdouttrace.WithWriter(stdoutDump), stdouttrace.WithPrettyPrint())
+	if errExporter != nil {
+		t.Fatalf("stdouttrace.New() error = %v", errExporter)
+	}
+
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	cm := cmotel.New(provider.Tracer("test"), "orders")
+	cm.NewSpan(cmotel.WithSpanName("handler"))
+	cm.NewSpan(cmotel.WithSpanName("query"), cmotel.WithParentSpanName("handler"))
+	cm.EndSpan("query")
+	cm.EndSpan("handler")
+	provider.Shutdown(context.Background())
+
+	tests := []struct {
+		name      string
+		dump      string
+		wantSpans int
+		wantEdges []Edge
+		wantErr   bool
+	}{
+		{
+			name:      "otlp json",
+			dump:      otlpDump,
+			wantSpans: 1,
+			wantEdges: []Edge{
+				{From: "cluster.namespace.gateway@request", To: "cluster.namespace.orders@query", Kind: EdgeKindRelationship},
+				{From: "cluster.namespace.orders@handler", To: "cluster.namespace.orders@query", Kind: EdgeKindParent},
+			},
+		},
+		{
+			name:      "stdout exporter",
+			dump:      stdoutDump.String(),
+			wantSpans: 2,
+			wantEdges: []Edge{
+				{From: "cluster.namespace.orders@handler", To: "cluster.namespace.orders@query", Kind: EdgeKindParent},
+			},
+		},
+		{
+			name:    "unknown document",
+			dump:    `{"spans":[]}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spans, err := ReadDump(strings.NewReader(tt.dump))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadDump() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if len(spans) != tt.wantSpans {
+				t.Fatalf("ReadDump() = %d spans, want %d", len(spans), tt.wantSpans)
+			}
+
+			graph := NewGraph()
+			for _, span := range spans {
+				graph.Add(span)
+			}
+
+			edges := graph.Edges()
+			if len(edges) != len(tt.wantEdges) {
+				t.Fatalf("Edges() = %v, want %v", edges, tt.wantEdges)
+			}
+
+			for i, edge := range edges {
+				if edge.Edge != tt.wantEdges[i] {
+					t.Errorf("Edges()[%d] = %v, want %v", i, edge.Edge, tt.wantEdges[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,319 @@
+package topology
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// PayloadVersion the version of the payload sent by the Exporter
+const PayloadVersion = "v1"
+
+// Payload the body sent by the Exporter
+type Payload struct {
+	Version string     `json:"version"`
+	Nodes   []SeenNode `json:"nodes"`
+	Edges   []SeenEdge `json:"edges"`
+}
+
+type exporterOpts struct {
+	client         *http.Client
+	headers        map[string]string
+	maxBatchSize   int
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxPending     int
+}
+
+// ExporterOption the function parameter for NewExporter
+type ExporterOption = func(opt *exporterOpts) error
+
+// WithHTTPClient the client used to send the payloads. It defaults to a client with a 10 seconds timeout.
+func WithHTTPClient(client *http.Client) ExporterOption {
+	return func(opt *exporterOpts) error {
+		if client == nil {
+			return errors.New("the http client must not be nil")
+		}
+
+		opt.client = client
+
+		return nil
+	}
+}
+
+// WithHeader a header sent with every payload, e.g. an authorization token
+func WithHeader(key, value string) ExporterOption {
+	return func(opt *exporterOpts) error {
+		if key == "" {
+			return errors.New("the header key must not be empty")
+		}
+
+		opt.headers[key] = value
+
+		return nil
+	}
+}
+
+// WithMaxBatchSize the maximum number of nodes and edges sent in one payload. It defaults to 500.
+func WithMaxBatchSize(size int) ExporterOption {
+	return func(opt *exporterOpts) error {
+		if size <= 0 {
+			return errors.New("the max batch size must be positive")
+		}
+
+		opt.maxBatchSize = size
+
+		return nil
+	}
+}
+
+// WithRetry the number of attempts to send a payload and the delay before the first retry, which doubles on every attempt. It defaults to 3 attempts starting at 100ms.
+func WithRetry(maxAttempts int, initialBackoff time.Duration) ExporterOption {
+	return func(opt *exporterOpts) error {
+		if maxAttempts <= 0 || initialBackoff < 0 {
+			return errors.New("the attempts must be positive and the backoff must not be negative")
+		}
+
+		opt.maxAttempts = maxAttempts
+		opt.initialBackoff = initialBackoff
+
+		return nil
+	}
+}
+
+// WithMaxPending the maximum number of nodes and edges kept for the next export when sending fails. It defaults to 10000, above which the pending topology is dropped.
+func WithMaxPending(size int) ExporterOption {
+	return func(opt *exporterOpts) error {
+		if size <= 0 {
+			return errors.New("the max pending size must be positive")
+		}
+
+		opt.maxPending = size
+
+		return nil
+	}
+}
+
+// Exporter an sdktrace.SpanExporter that sends the deduplicated topology carried by the spans to a Coordimap HTTP endpoint instead of the spans themselves
+type Exporter struct {
+	endpoint string
+	options  *exporterOpts
+
+	mu       sync.Mutex
+	pending  *Graph
+	shutdown bool
+}
+
+// NewExporter creates an exporter that posts the topology as a JSON Payload to the endpoint
+func NewExporter(endpoint string, opts ...ExporterOption) (*Exporter, error) {
+	parsed, errParse := url.Parse(endpoint)
+	if errParse != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return nil, fmt.Errorf("the endpoint must be an http or https URL, got %s", endpoint)
+	}
+
+	options := &exporterOpts{
+		client:         &http.Client{Timeout: 10 * time.Second},
+		headers:        map[string]string{},
+		maxBatchSize:   500,
+		maxAttempts:    3,
+		initialBackoff: 100 * time.Millisecond,
+		maxPending:     10000,
+	}
+
+	for _, opt := range opts {
+		if err := opt(options); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Exporter{
+		endpoint: endpoint,
+		options:  options,
+		pending:  NewGraph(),
+	}, nil
+}
+
+// ExportSpans extracts the topology of the spans and sends it along with anything left over from previous failed exports
+func (e *Exporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	e.mu.Lock()
+	if e.shutdown {
+		e.mu.Unlock()
+		return errors.New("the exporter has been shut down")
+	}
+
+	for _, span := range spans {
+		e.pending.Add(FromReadOnlySpan(span))
+	}
+
+	batch := e.takePending()
+	e.mu.Unlock()
+
+	return e.flush(ctx, batch)
+}
+
+// Shutdown sends the pending topology one last time and stops the exporter
+func (e *Exporter) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	if e.shutdown {
+		e.mu.Unlock()
+		return nil
+	}
+
+	e.shutdown = true
+	batch := e.takePending()
+	e.mu.Unlock()
+
+	return e.flush(ctx, batch)
+}
+
+// takePending returns the pending topology and starts a new one. The lock must be held.
+func (e *Exporter) takePending() *Graph {
+	batch := e.pending
+	e.pending = NewGraph()
+
+	return batch
+}
+
+// flush sends the batch without holding the lock, so that the retries of one export do not block the others. The payloads that could not be sent are merged back into the pending topology, unless the exporter has been shut down.
+func (e *Exporter) flush(ctx context.Context, batch *Graph) error {
+	if batch.Len() == 0 {
+		return nil
+	}
+
+	payloads := splitPayload(batch, e.options.maxBatchSize)
+	for i, payload := range payloads {
+		if errSend := e.send(ctx, payload); errSend != nil {
+			return e.requeue(payloads[i:], errSend)
+		}
+	}
+
+	return nil
+}
+
+// requeue merges the payloads that could not be sent into the pending topology and returns why they were not sent
+func (e *Exporter) requeue(payloads []Payload, errSend error) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.shutdown {
+		return errors.Join(errors.New("dropped the topology that could not be sent since the exporter has been shut down"), errSend)
+	}
+
+	e.pending.mu.Lock()
+	for _, payload := range payloads {
+		for _, node := range payload.Nodes {
+			e.pending.addNode(node)
+		}
+
+		for _, edge := range payload.Edges {
+			e.pending.addEdge(edge)
+		}
+	}
+	e.pending.mu.Unlock()
+
+	if e.pending.Len() > e.options.maxPending {
+		e.pending = NewGraph()
+		return errors.Join(errors.New("dropped the pending topology since it exceeds the maximum size"), errSend)
+	}
+
+	return errSend
+}
+
+// splitPayload splits the graph in payloads of at most size nodes and edges. Nodes are sent first so that edges always refer to known nodes.
+func splitPayload(graph *Graph, size int) []Payload {
+	payloads := []Payload{}
+	current := Payload{Version: PayloadVersion, Nodes: []SeenNode{}, Edges: []SeenEdge{}}
+
+	flushIfFull := func() {
+		if len(current.Nodes)+len(current.Edges) >= size {
+			payloads = append(payloads, current)
+			current = Payload{Version: PayloadVersion, Nodes: []SeenNode{}, Edges: []SeenEdge{}}
+		}
+	}
+
+	for _, node := range graph.Nodes() {
+		current.Nodes = append(current.Nodes, node)
+		flushIfFull()
+	}
+
+	for _, edge := range graph.Edges() {
+		current.Edges = append(current.Edges, edge)
+		flushIfFull()
+	}
+
+	if len(current.Nodes)+len(current.Edges) != 0 {
+		payloads = append(payloads, current)
+	}
+
+	return payloads
+}
+
+func (e *Exporter) send(ctx context.Context, payload Payload) error {
+	body, errMarshal := json.Marshal(payload)
+	if errMarshal != nil {
+		return errors.Join(errors.New("could not marshal the topology payload"), errMarshal)
+	}
+
+	backoff := e.options.initialBackoff
+	var errLast error
+
+	for attempt := 1; attempt <= e.options.maxAttempts; attempt++ {
+		retry, errPost := e.post(ctx, body)
+		if errPost == nil {
+			return nil
+		}
+
+		errLast = errPost
+		if !retry || attempt == e.options.maxAttempts {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.Join(errLast, ctx.Err())
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+	}
+
+	return errors.Join(fmt.Errorf("could not send the topology to %s", e.endpoint), errLast)
+}
+
+// post sends the body once and returns whether a failure is worth retrying
+func (e *Exporter) post(ctx context.Context, body []byte) (bool, error) {
+	req, errReq := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if errReq != nil {
+		return false, errReq
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range e.options.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, errDo := e.options.client.Do(req)
+	if errDo != nil {
+		return ctx.Err() == nil, errDo
+	}
+	defer resp.Body.Close()
+
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+
+	return retry, fmt.Errorf("the topology endpoint responded with %s", resp.Status)
+}
@@ -0,0 +1,247 @@
+package topology
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	cmotel "github.com/coordimap/cm-otel-go"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestExporter(t *testing.T) {
+	t.Setenv(cmotel.EnvServiceNamePrefix, "cluster.namespace")
+
+	var mu sync.Mutex
+	attempts := 0
+	payloads := []Payload{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		attempts++
+		if attempts == 1 {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		if r.Header.Get("Authorization") != "Bearer token" {
+			rw.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		payload := Payload{}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		payloads = append(payloads, payload)
+	}))
+	defer server.Close()
+
+	exporter, err := NewExporter(server.URL, WithRetry(2, time.Millisecond), WithHeader("Authorization", "Bearer token"))
+	if err != nil {
+		t.Fatalf("NewExporter() error = %v", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter))
+	cm := cmotel.New(provider.Tracer("test"), "orders")
+
+	cm.NewSpan(cmotel.WithSpanName("handler"))
+	for i := 0; i < 3; i++ {
+		span, _ := cm.NewSpan(cmotel.WithSpanName("query"), cmotel.WithParentSpanName("handler"))
+		if err := cm.AddComponent(cmotel.WithAddComponentSpan(span), cmotel.WithAddComponentType("postgres")); err != nil {
+			t.Fatalf("AddComponent() error = %v", err)
+		}
+		span.End()
+	}
+	cm.EndSpan("handler")
+
+	if err := provider.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if attempts != 2 {
+		t.Errorf("got %d attempts, want 2", attempts)
+	}
+	if len(payloads) != 1 {
+		t.Fatalf("got %d payloads, want 1", len(payloads))
+	}
+
+	payload := payloads[0]
+	if len(payload.Nodes) != 2 {
+		t.Errorf("nodes = %v, want handler and query", payload.Nodes)
+	}
+	if len(payload.Nodes) == 2 && (payload.Nodes[1].ID != "cluster.namespace.orders@query" || payload.Nodes[1].Type != "postgres") {
+		t.Errorf("query node = %+v, want a postgres component", payload.Nodes[1])
+	}
+
+	want := Edge{From: "cluster.namespace.orders@handler", To: "cluster.namespace.orders@query", Kind: EdgeKindParent}
+	if len(payload.Edges) != 1 || payload.Edges[0].Edge != want {
+		t.Errorf("edges = %v, want [%v]", payload.Edges, want)
+	}
+	if len(payload.Edges) == 1 && payload.Edges[0].LastSeen.Before(payload.Edges[0].FirstSeen) {
+		t.Errorf("edge last seen %v is before first seen %v", payload.Edges[0].LastSeen, payload.Edges[0].FirstSeen)
+	}
+}
+
+func TestSplitPayload(t *testing.T) {
+	graph := NewGraph()
+	for _, name := range []string{"a", "b", "c"} {
+		graph.Add(Span{
+			Name:       "svc@" + name,
+			Attributes: nil,
+			Links: []Link{{Attributes: []attribute.KeyValue{
+				attribute.String(cmotel.SpanAttrRelationship, cmotel.FormatRelationship("svc@root", "svc@"+name)),
+			}}},
+		})
+	}
+
+	payloads := splitPayload(graph, 3)
+	if len(payloads) != 3 {
+		t.Fatalf("got %d payloads, want 3", len(payloads))
+	}
+
+	total := 0
+	for _, payload := range payloads {
+		total += len(payload.Nodes) + len(payload.Edges)
+	}
+	if total != graph.Len() {
+		t.Errorf("payloads hold %d nodes and edges, want %d", total, graph.Len())
+	}
+}
+
+func TestExporterSendsWithoutLock(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}))
+	defer server.Close()
+
+	exporter, err := NewExporter(server.URL)
+	if err != nil {
+		t.Fatalf("NewExporter() error = %v", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	cm := cmotel.New(provider.Tracer("test"), "orders")
+
+	sent := make(chan struct{})
+	go func() {
+		cm.NewSpan(cmotel.WithSpanName("handler"))
+		span, _ := cm.NewSpan(cmotel.WithSpanName("query"), cmotel.WithParentSpanName("handler"))
+		span.End()
+		close(sent)
+	}()
+	<-started
+
+	exported := make(chan error)
+	go func() {
+		exported <- exporter.ExportSpans(context.Background(), nil)
+	}()
+
+	select {
+	case err := <-exported:
+		if err != nil {
+			t.Errorf("ExportSpans() error = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("ExportSpans() waited for the pending request of another export")
+	}
+
+	close(release)
+	<-sent
+}
+
+func TestExporterRequeuesUnsentPayloads(t *testing.T) {
+	var mu sync.Mutex
+	requests := 0
+	received := 0
+	failing := true
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		requests++
+		if failing && requests == 2 {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		payload := Payload{}
+		json.NewDecoder(r.Body).Decode(&payload)
+		received += len(payload.Nodes) + len(payload.Edges)
+	}))
+	defer server.Close()
+
+	exporter, err := NewExporter(server.URL, WithMaxBatchSize(1), WithRetry(1, 0))
+	if err != nil {
+		t.Fatalf("NewExporter() error = %v", err)
+	}
+
+	graph := NewGraph()
+	graph.Add(Span{
+		Name: "svc@a",
+		Links: []Link{{Attributes: []attribute.KeyValue{
+			attribute.String(cmotel.SpanAttrRelationship, cmotel.FormatRelationship("svc@root", "svc@a")),
+		}}},
+	})
+
+	if err := exporter.flush(context.Background(), graph); err == nil {
+		t.Fatalf("flush() error = nil, want the failure of the second payload")
+	}
+
+	mu.Lock()
+	failing = false
+	mu.Unlock()
+
+	if err := exporter.ExportSpans(context.Background(), nil); err != nil {
+		t.Fatalf("ExportSpans() error = %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if received != graph.Len() {
+		t.Errorf("received %d nodes and edges, want each of the %d sent once", received, graph.Len())
+	}
+}
+
+func TestExporterShutdownDropsUnsent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	exporter, err := NewExporter(server.URL, WithRetry(1, 0))
+	if err != nil {
+		t.Fatalf("NewExporter() error = %v", err)
+	}
+
+	exporter.pending.Add(Span{
+		Name: "svc@a",
+		Links: []Link{{Attributes: []attribute.KeyValue{
+			attribute.String(cmotel.SpanAttrRelationship, cmotel.FormatRelationship("svc@root", "svc@a")),
+		}}},
+	})
+
+	if err := exporter.Shutdown(context.Background()); err == nil {
+		t.Errorf("Shutdown() error = nil, want the dropped topology reported")
+	}
+	if exporter.pending.Len() != 0 {
+		t.Errorf("pending = %d nodes and edges after shutdown, want none kept", exporter.pending.Len())
+	}
+}
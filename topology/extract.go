@@ -0,0 +1,199 @@
+package topology
+
+import (
+	"strings"
+
+	cmotel "github.com/coordimap/cm-otel-go"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// EdgeKind how the relationship between two nodes was found
+type EdgeKind string
+
+const (
+	// EdgeKindParent the destination span was created with the source as its parent, SpanAttrParentName
+	EdgeKindParent EdgeKind = "parent"
+
+	// EdgeKindRelationship an explicit relationship, SpanAttrRelationship or SpanAttrRelationships
+	EdgeKindRelationship EdgeKind = "relationship"
+
+	// EdgeKindTarget the source span called or connected to the destination service, SpanAttrTargetService
+	EdgeKindTarget EdgeKind = "target"
+
+	// EdgeKindSource the source service called the destination span, SpanAttrSourceService
+	EdgeKindSource EdgeKind = "source"
+)
+
+// Node a component of the topology identified by its internal name
+type Node struct {
+	ID   string            `json:"id"`
+	Name string            `json:"name,omitempty"`
+	Type string            `json:"type,omitempty"`
+	Data map[string]string `json:"data,omitempty"`
+
+	// Container the node encloses the nodes of its child spans
+	Container bool `json:"container,omitempty"`
+}
+
+// Edge a relationship between two nodes
+type Edge struct {
+	From       string   `json:"from"`
+	To         string   `json:"to"`
+	Kind       EdgeKind `json:"kind"`
+	Unverified bool     `json:"unverified,omitempty"`
+}
+
+// Extraction the nodes and edges carried by a single span
+type Extraction struct {
+	// Node the node of the span itself, nil when the span carries no topology
+	Node  *Node
+	Nodes []Node
+	Edges []Edge
+}
+
+// Extract decodes the Coordimap attributes and links of a span. Attributes that cannot be decoded are skipped.
+func Extract(span Span) Extraction {
+	extraction := Extraction{
+		Node:  nil,
+		Nodes: []Node{},
+		Edges: []Edge{},
+	}
+
+	self := Node{ID: span.Name, Name: spanName(span.Name)}
+	hasTopology := false
+	hasParent := false
+	unverified := false
+
+	// spans of other instrumentation are only part of the topology once they carry an internal name
+	hasInternalName := strings.Contains(span.Name, "@")
+
+	for _, attr := range span.Attributes {
+		if attr.Key == cmotel.SpanAttrUnverified && attr.Value.AsBool() {
+			unverified = true
+		}
+
+		// spans of other instrumentation are named by the annotating processor
+		if attr.Key == cmotel.SpanAttrInternalName && attr.Value.AsString() != "" {
+			self = Node{ID: attr.Value.AsString(), Name: spanName(attr.Value.AsString())}
+			hasInternalName = true
+		}
+	}
+
+	for _, attr := range span.Attributes {
+		switch attr.Key {
+		case cmotel.SpanAttrComponent:
+			component, errComponent := cmotel.ParseComponent(attr.Value.AsString())
+			if errComponent != nil {
+				continue
+			}
+
+			self = Node{ID: component.InternalID, Name: component.Name, Type: component.Type, Data: component.Data, Container: component.IsContainer}
+			hasTopology = true
+
+		case cmotel.SpanAttrParentName:
+			hasParent = true
+			extraction.Nodes = append(extraction.Nodes, Node{ID: attr.Value.AsString(), Name: spanName(attr.Value.AsString())})
+			extraction.Edges = append(extraction.Edges, Edge{From: attr.Value.AsString(), To: self.ID, Kind: EdgeKindParent, Unverified: unverified})
+			hasTopology = true
+
+		case cmotel.SpanAttrRelationships:
+			for _, relationship := range attr.Value.AsStringSlice() {
+				from, to, errParse := cmotel.ParseRelationship(relationship)
+				if errParse != nil {
+					continue
+				}
+
+				extraction.Nodes = append(extraction.Nodes, Node{ID: from, Name: spanName(from)}, Node{ID: to, Name: spanName(to)})
+				extraction.Edges = append(extraction.Edges, Edge{From: from, To: to, Kind: EdgeKindRelationship, Unverified: unverified})
+				hasTopology = true
+			}
+
+		case cmotel.SpanAttrTargetService:
+			extraction.Nodes = append(extraction.Nodes, Node{ID: attr.Value.AsString(), Name: attr.Value.AsString()})
+			extraction.Edges = append(extraction.Edges, Edge{From: self.ID, To: attr.Value.AsString(), Kind: EdgeKindTarget})
+			hasTopology = true
+
+		case cmotel.SpanAttrSourceService:
+			extraction.Nodes = append(extraction.Nodes, Node{ID: attr.Value.AsString(), Name: attr.Value.AsString()})
+			extraction.Edges = append(extraction.Edges, Edge{From: attr.Value.AsString(), To: self.ID, Kind: EdgeKindSource})
+			hasTopology = true
+		}
+	}
+
+	for _, link := range span.Links {
+		if edge, ok := relationshipEdge(link.Attributes); ok {
+			extraction.Nodes = append(extraction.Nodes, Node{ID: edge.From, Name: spanName(edge.From)}, Node{ID: edge.To, Name: spanName(edge.To)})
+			extraction.Edges = append(extraction.Edges, edge)
+			hasTopology = true
+		}
+	}
+
+	// the containers the service runs in, e.g. the node and the pod, nest into each other and enclose the root spans of the service
+	containers := []cmotel.CMComponent{}
+	if hasInternalName {
+		containers = cmotel.ParseResourceComponents(span.Resource)
+	}
+
+	for i, container := range containers {
+		extraction.Nodes = append(extraction.Nodes, Node{ID: container.InternalID, Name: container.Name, Type: container.Type, Data: container.Data, Container: true})
+
+		if i > 0 {
+			extraction.Edges = append(extraction.Edges, Edge{From: containers[i-1].InternalID, To: container.InternalID, Kind: EdgeKindParent})
+		}
+	}
+
+	if len(containers) > 0 && !hasParent {
+		extraction.Edges = append(extraction.Edges, Edge{From: containers[len(containers)-1].InternalID, To: span.Name, Kind: EdgeKindParent})
+		hasTopology = true
+	}
+
+	// the edges were created before the component, if any, was decoded so they point to the span name
+	for i := range extraction.Edges {
+		if extraction.Edges[i].From == span.Name {
+			extraction.Edges[i].From = self.ID
+		}
+
+		if extraction.Edges[i].To == span.Name {
+			extraction.Edges[i].To = self.ID
+		}
+	}
+
+	if hasTopology {
+		extraction.Node = &self
+	}
+
+	return extraction
+}
+
+func relationshipEdge(attributes []attribute.KeyValue) (Edge, bool) {
+	edge := Edge{Kind: EdgeKindRelationship}
+	found := false
+
+	for _, attr := range attributes {
+		switch attr.Key {
+		case cmotel.SpanAttrRelationship:
+			from, to, errParse := cmotel.ParseRelationship(attr.Value.AsString())
+			if errParse != nil {
+				return Edge{}, false
+			}
+
+			edge.From = from
+			edge.To = to
+			found = true
+
+		case cmotel.SpanAttrUnverified:
+			edge.Unverified = attr.Value.AsBool()
+		}
+	}
+
+	return edge, found
+}
+
+// spanName returns the span name part of an internal name or the name itself when it has none
+func spanName(internalName string) string {
+	if _, _, name := cmotel.SplitInternalName(internalName); name != "" {
+		return name
+	}
+
+	return internalName
+}
@@ -0,0 +1,265 @@
+package topology
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// SeenNode a node along with the first and last time a span carried it
+type SeenNode struct {
+	Node
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// SeenEdge an edge along with the first and last time a span carried it and the statistics of those spans
+type SeenEdge struct {
+	Edge
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	Stats     EdgeStats `json:"stats"`
+}
+
+type edgeKey struct {
+	from string
+	to   string
+	kind EdgeKind
+}
+
+// Graph the deduplicated nodes and edges extracted from spans. It is safe for concurrent use.
+type Graph struct {
+	mu    sync.RWMutex
+	nodes map[string]*SeenNode
+	edges map[edgeKey]*SeenEdge
+}
+
+// NewGraph creates an empty graph
+func NewGraph() *Graph {
+	return &Graph{
+		nodes: map[string]*SeenNode{},
+		edges: map[edgeKey]*SeenEdge{},
+	}
+}
+
+// Add extracts the topology of the span and adds it to the graph
+func (g *Graph) Add(span Span) {
+	extraction := Extract(span)
+
+	firstSeen := span.StartTime
+	lastSeen := span.EndTime
+	if lastSeen.IsZero() {
+		lastSeen = firstSeen
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	nodes := extraction.Nodes
+	if extraction.Node != nil {
+		nodes = append(nodes, *extraction.Node)
+	}
+
+	for _, node := range nodes {
+		g.addNode(SeenNode{Node: node, FirstSeen: firstSeen, LastSeen: lastSeen})
+	}
+
+	for _, edge := range extraction.Edges {
+		stats := newEdgeStats()
+		stats.record(span)
+
+		g.addEdge(SeenEdge{Edge: edge, FirstSeen: firstSeen, LastSeen: lastSeen, Stats: stats})
+	}
+}
+
+// Merge adds all the nodes and edges of the other graph
+func (g *Graph) Merge(other *Graph) {
+	nodes := other.Nodes()
+	edges := other.Edges()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for _, node := range nodes {
+		g.addNode(node)
+	}
+
+	for _, edge := range edges {
+		g.addEdge(edge)
+	}
+}
+
+func (g *Graph) addNode(node SeenNode) {
+	existing, ok := g.nodes[node.ID]
+	if !ok {
+		g.nodes[node.ID] = &node
+		return
+	}
+
+	// nodes referenced by other spans only know their ID until their own span is seen
+	if existing.Type == "" && node.Type != "" {
+		existing.Name = node.Name
+		existing.Type = node.Type
+		existing.Data = node.Data
+		existing.Container = node.Container
+	}
+
+	existing.FirstSeen, existing.LastSeen = widen(existing.FirstSeen, existing.LastSeen, node.FirstSeen, node.LastSeen)
+}
+
+func (g *Graph) addEdge(edge SeenEdge) {
+	key := edgeKey{from: edge.From, to: edge.To, kind: edge.Kind}
+
+	existing, ok := g.edges[key]
+	if !ok {
+		edge.Stats.Latency = edge.Stats.Latency.clone()
+		g.edges[key] = &edge
+		return
+	}
+
+	existing.Stats.merge(edge.Stats)
+
+	// a single verified observation is enough to consider the edge verified
+	existing.Unverified = existing.Unverified && edge.Unverified
+	existing.FirstSeen, existing.LastSeen = widen(existing.FirstSeen, existing.LastSeen, edge.FirstSeen, edge.LastSeen)
+}
+
+func widen(first, last, otherFirst, otherLast time.Time) (time.Time, time.Time) {
+	if first.IsZero() || (!otherFirst.IsZero() && otherFirst.Before(first)) {
+		first = otherFirst
+	}
+
+	if otherLast.After(last) {
+		last = otherLast
+	}
+
+	return first, last
+}
+
+// Len returns the number of nodes and edges
+func (g *Graph) Len() int {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	return len(g.nodes) + len(g.edges)
+}
+
+// Nodes returns a copy of the nodes sorted by ID
+func (g *Graph) Nodes() []SeenNode {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	nodes := make([]SeenNode, 0, len(g.nodes))
+	for _, node := range g.nodes {
+		nodes = append(nodes, *node)
+	}
+
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].ID < nodes[j].ID
+	})
+
+	return nodes
+}
+
+// Edges returns a copy of the edges sorted by source, destination and kind
+func (g *Graph) Edges() []SeenEdge {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	edges := make([]SeenEdge, 0, len(g.edges))
+	for _, edge := range g.edges {
+		edges = append(edges, copyEdge(edge))
+	}
+
+	sort.Slice(edges, func(i, j int) bool {
+		return lessEdge(edges[i].Edge, edges[j].Edge)
+	})
+
+	return edges
+}
+
+// Node returns the node with the ID
+func (g *Graph) Node(id string) (SeenNode, bool) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	node, ok := g.nodes[id]
+	if !ok {
+		return SeenNode{}, false
+	}
+
+	return *node, true
+}
+
+// Edge returns the edge from one node to another of the provided kind
+func (g *Graph) Edge(from, to string, kind EdgeKind) (SeenEdge, bool) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	edge, ok := g.edges[edgeKey{from: from, to: to, kind: kind}]
+	if !ok {
+		return SeenEdge{}, false
+	}
+
+	return copyEdge(edge), true
+}
+
+// EdgesFrom returns the outgoing edges of a node sorted by destination and kind
+func (g *Graph) EdgesFrom(id string) []SeenEdge {
+	return g.filterEdges(func(edge *SeenEdge) bool {
+		return edge.From == id
+	})
+}
+
+// EdgesTo returns the incoming edges of a node sorted by source and kind
+func (g *Graph) EdgesTo(id string) []SeenEdge {
+	return g.filterEdges(func(edge *SeenEdge) bool {
+		return edge.To == id
+	})
+}
+
+// Reset removes all the nodes and edges
+func (g *Graph) Reset() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.nodes = map[string]*SeenNode{}
+	g.edges = map[edgeKey]*SeenEdge{}
+}
+
+func (g *Graph) filterEdges(keep func(edge *SeenEdge) bool) []SeenEdge {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	edges := []SeenEdge{}
+	for _, edge := range g.edges {
+		if keep(edge) {
+			edges = append(edges, copyEdge(edge))
+		}
+	}
+
+	sort.Slice(edges, func(i, j int) bool {
+		return lessEdge(edges[i].Edge, edges[j].Edge)
+	})
+
+	return edges
+}
+
+func copyEdge(edge *SeenEdge) SeenEdge {
+	copied := *edge
+	copied.Stats.Latency = edge.Stats.Latency.clone()
+
+	return copied
+}
+
+func lessEdge(a, b Edge) bool {
+	if a.From != b.From {
+		return a.From < b.From
+	}
+
+	if a.To != b.To {
+		return a.To < b.To
+	}
+
+	return a.Kind < b.Kind
+}
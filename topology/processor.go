@@ -0,0 +1,42 @@
+package topology
+
+import (
+	"context"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Processor an sdktrace.SpanProcessor that builds the topology of the process in memory from the ended spans
+type Processor struct {
+	graph *Graph
+}
+
+// NewProcessor creates a processor with an empty graph. Register it with sdktrace.WithSpanProcessor or cmotel.WithSpanProcessor.
+func NewProcessor() *Processor {
+	return &Processor{
+		graph: NewGraph(),
+	}
+}
+
+// Graph returns the live graph of the processor. It keeps being updated as spans end.
+func (p *Processor) Graph() *Graph {
+	return p.graph
+}
+
+// OnStart does nothing since the topology is only complete once the span has ended
+func (p *Processor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {}
+
+// OnEnd adds the topology of the span to the graph
+func (p *Processor) OnEnd(s sdktrace.ReadOnlySpan) {
+	p.graph.Add(FromReadOnlySpan(s))
+}
+
+// Shutdown does nothing, the graph stays available
+func (p *Processor) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// ForceFlush does nothing since the spans are processed synchronously
+func (p *Processor) ForceFlush(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,123 @@
+package topology
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	cmotel "github.com/coordimap/cm-otel-go"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestProcessor(t *testing.T) {
+	t.Setenv(cmotel.EnvServiceNamePrefix, "cluster.namespace")
+
+	processor := NewProcessor()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(processor))
+	cm := cmotel.New(provider.Tracer("test"), "orders")
+
+	start := time.Unix(1760000000, 0)
+	cm.NewSpan(cmotel.WithSpanName("handler"))
+	for i, duration := range []time.Duration{2 * time.Millisecond, 40 * time.Millisecond, 20 * time.Second} {
+		span, _ := cm.NewSpan(
+			cmotel.WithSpanName("query"),
+			cmotel.WithParentSpanName("handler"),
+			cmotel.WithSpanStartOptions(trace.WithTimestamp(start)),
+		)
+		if i == 2 {
+			span.SetStatus(codes.Error, "timeout")
+		}
+		span.End(trace.WithTimestamp(start.Add(duration)))
+	}
+	cm.EndSpan("handler")
+
+	graph := processor.Graph()
+
+	if _, ok := graph.Node("cluster.namespace.orders@handler"); !ok {
+		t.Errorf("Node(handler) not found")
+	}
+
+	edges := graph.EdgesFrom("cluster.namespace.orders@handler")
+	if len(edges) != 1 {
+		t.Fatalf("EdgesFrom(handler) = %v, want one edge", edges)
+	}
+
+	edge, ok := graph.Edge("cluster.namespace.orders@handler", "cluster.namespace.orders@query", EdgeKindParent)
+	if !ok {
+		t.Fatalf("Edge(handler, query) not found")
+	}
+
+	if edge.Stats.Requests != 3 || edge.Stats.Errors != 1 {
+		t.Errorf("stats = %d requests and %d errors, want 3 and 1", edge.Stats.Requests, edge.Stats.Errors)
+	}
+
+	tests := []struct {
+		quantile float64
+		want     time.Duration
+	}{
+		{quantile: 0, want: 5 * time.Millisecond},
+		{quantile: 0.5, want: 50 * time.Millisecond},
+		{quantile: 1, want: 20 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := edge.Stats.Latency.Quantile(tt.quantile); got != tt.want {
+			t.Errorf("Quantile(%v) = %v, want %v", tt.quantile, got, tt.want)
+		}
+	}
+
+	if got := len(graph.EdgesTo("cluster.namespace.orders@query")); got != 1 {
+		t.Errorf("EdgesTo(query) returned %d edges, want 1", got)
+	}
+
+	provider.Shutdown(context.Background())
+}
+
+func TestProcessorResourceContainers(t *testing.T) {
+	t.Setenv(cmotel.EnvServiceNamePrefix, "cluster.namespace")
+
+	containers, err := cmotel.ResourceComponents(cmotel.K8SResourceSource,
+		cmotel.CMComponent{Name: "orders-0", InternalID: "prod.shop.orders-0", Type: cmotel.ComponentTypeK8SPod},
+		cmotel.CMComponent{Name: "node-1", InternalID: "prod.node-1", Type: cmotel.ComponentTypeK8SNode},
+	)
+	if err != nil {
+		t.Fatalf("ResourceComponents() error = %v", err)
+	}
+
+	processor := NewProcessor()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(processor), sdktrace.WithResource(resource.NewSchemaless(containers)))
+	cm := cmotel.New(provider.Tracer("test"), "orders")
+
+	cm.NewSpan(cmotel.WithSpanName("handler"))
+	cm.NewSpan(cmotel.WithSpanName("query"), cmotel.WithParentSpanName("handler"))
+	cm.EndSpan("query")
+	cm.EndSpan("handler")
+
+	graph := processor.Graph()
+
+	if node, ok := graph.Node("prod.shop.orders-0"); !ok || !node.Container {
+		t.Errorf("Node(pod) = %v, want a container", node)
+	}
+
+	for _, edge := range [][2]string{
+		{"prod.node-1", "prod.shop.orders-0"},
+		{"prod.shop.orders-0", "cluster.namespace.orders@handler"},
+	} {
+		if _, ok := graph.Edge(edge[0], edge[1], EdgeKindParent); !ok {
+			t.Errorf("Edge(%s, %s) not found", edge[0], edge[1])
+		}
+	}
+
+	if _, ok := graph.Edge("prod.shop.orders-0", "cluster.namespace.orders@query", EdgeKindParent); ok {
+		t.Errorf("the pod encloses the child span query directly, want only the root span")
+	}
+
+	_, span := provider.Tracer("otelhttp").Start(context.Background(), "HTTP GET")
+	span.End()
+
+	if node, ok := processor.Graph().Node("HTTP GET"); ok {
+		t.Errorf("Node(HTTP GET) = %v, want spans without an internal name left out of the topology", node)
+	}
+}
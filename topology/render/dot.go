@@ -0,0 +1,86 @@
+package render
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/coordimap/cm-otel-go/topology"
+)
+
+// DOT writes the graph as a Graphviz digraph. Groups are rendered as clusters and unverified edges are dashed.
+func DOT(w io.Writer, graph *topology.Graph, opts ...Option) error {
+	l, errLayout := newLayout(graph, opts...)
+	if errLayout != nil {
+		return errLayout
+	}
+
+	nodes := map[string]topology.Node{}
+	for _, node := range l.nodes {
+		nodes[node.ID] = node.Node
+	}
+
+	out := bufio.NewWriter(w)
+	clusters := 0
+
+	writeNode := func(indent, id string) {
+		fmt.Fprintf(out, "%s%s [label=%s];\n", indent, strconv.Quote(id), strconv.Quote(label(nodes[id])))
+	}
+
+	var writeGroup func(indent string, group *Group)
+	writeGroup = func(indent string, group *Group) {
+		fmt.Fprintf(out, "%ssubgraph cluster_%d {\n", indent, clusters)
+		clusters++
+
+		fmt.Fprintf(out, "%s\tlabel=%s;\n", indent, strconv.Quote(groupLabel(group)))
+		if group.Kind == GroupKindContainer {
+			fmt.Fprintf(out, "%s\tstyle=rounded;\n", indent)
+		}
+
+		for _, id := range group.Nodes {
+			writeNode(indent+"\t", id)
+		}
+
+		for _, child := range group.Groups {
+			writeGroup(indent+"\t", child)
+		}
+
+		fmt.Fprintf(out, "%s}\n", indent)
+	}
+
+	fmt.Fprintln(out, "digraph topology {")
+	fmt.Fprintln(out, "\trankdir=LR;")
+	fmt.Fprintln(out, "\tnode [shape=box];")
+
+	for _, group := range l.groups {
+		writeGroup("\t", group)
+	}
+
+	for _, id := range l.ungrouped {
+		writeNode("\t", id)
+	}
+
+	for _, edge := range l.edges {
+		attrs := []string{"label=" + strconv.Quote(string(edge.Kind))}
+		if edge.Unverified {
+			attrs = append(attrs, "style=dashed")
+		}
+
+		fmt.Fprintf(out, "\t%s -> %s [%s];\n", strconv.Quote(edge.From), strconv.Quote(edge.To), strings.Join(attrs, ", "))
+	}
+
+	fmt.Fprintln(out, "}")
+
+	return out.Flush()
+}
+
+// groupLabel returns the text shown for a group, container groups only show the name of the container
+func groupLabel(group *Group) string {
+	if group.Kind == GroupKindContainer {
+		return strings.SplitN(group.Label, "\n", 2)[0]
+	}
+
+	return group.Label
+}
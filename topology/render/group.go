@@ -0,0 +1,197 @@
+// Package render writes a topology graph as Graphviz DOT, a Mermaid flowchart or a stable JSON document.
+package render
+
+import (
+	"sort"
+
+	cmotel "github.com/coordimap/cm-otel-go"
+	"github.com/coordimap/cm-otel-go/topology"
+)
+
+// GroupKind what the nodes of a group have in common
+type GroupKind string
+
+const (
+	// GroupKindService the nodes share the same service prefix, i.e. <cluster>.<namespace>
+	GroupKindService GroupKind = "service_prefix"
+
+	// GroupKindContainer the nodes are enclosed by a container component
+	GroupKindContainer GroupKind = "container"
+)
+
+type renderOpts struct {
+	groupByService   bool
+	groupByContainer bool
+}
+
+// Option the function parameter for the renderers
+type Option = func(opt *renderOpts) error
+
+// WithoutServiceGroups does not group the nodes by their service prefix
+func WithoutServiceGroups() Option {
+	return func(opt *renderOpts) error {
+		opt.groupByService = false
+
+		return nil
+	}
+}
+
+// WithoutContainerGroups does not group the nodes by the container component enclosing them
+func WithoutContainerGroups() Option {
+	return func(opt *renderOpts) error {
+		opt.groupByContainer = false
+
+		return nil
+	}
+}
+
+// Group a set of nodes, and nested groups, rendered together
+type Group struct {
+	ID     string    `json:"id"`
+	Label  string    `json:"label"`
+	Kind   GroupKind `json:"kind"`
+	Nodes  []string  `json:"nodes"`
+	Groups []*Group  `json:"groups,omitempty"`
+}
+
+// layout the sorted nodes and edges of the graph and how the nodes are grouped
+type layout struct {
+	nodes []topology.SeenNode
+	edges []topology.SeenEdge
+
+	// groups the top level groups
+	groups []*Group
+
+	// ungrouped the nodes that are not part of any group
+	ungrouped []string
+}
+
+func newLayout(graph *topology.Graph, opts ...Option) (*layout, error) {
+	options := &renderOpts{
+		groupByService:   true,
+		groupByContainer: true,
+	}
+
+	for _, opt := range opts {
+		if err := opt(options); err != nil {
+			return nil, err
+		}
+	}
+
+	l := &layout{
+		nodes:     graph.Nodes(),
+		edges:     graph.Edges(),
+		groups:    []*Group{},
+		ungrouped: []string{},
+	}
+
+	containers := map[string]bool{}
+	for _, node := range l.nodes {
+		if options.groupByContainer && node.Container {
+			containers[node.ID] = true
+		}
+	}
+
+	// every node is enclosed by the nearest container found by walking up its parents
+	parents := map[string]string{}
+	for _, edge := range l.edges {
+		if edge.Kind == topology.EdgeKindParent {
+			if _, ok := parents[edge.To]; !ok {
+				parents[edge.To] = edge.From
+			}
+		}
+	}
+
+	enclosing := func(id string) string {
+		seen := map[string]bool{id: true}
+
+		for parent, ok := parents[id]; ok && !seen[parent]; parent, ok = parents[parent] {
+			if containers[parent] {
+				return parent
+			}
+
+			seen[parent] = true
+		}
+
+		return ""
+	}
+
+	containerGroups := map[string]*Group{}
+	for _, node := range l.nodes {
+		if containers[node.ID] {
+			containerGroups[node.ID] = &Group{ID: node.ID, Label: label(node.Node), Kind: GroupKindContainer, Nodes: []string{node.ID}, Groups: []*Group{}}
+		}
+	}
+
+	serviceGroups := map[string]*Group{}
+	topLevel := func(id string) *Group {
+		prefix, _, _ := cmotel.SplitInternalName(id)
+		if !options.groupByService || prefix == "" {
+			return nil
+		}
+
+		group, ok := serviceGroups[prefix]
+		if !ok {
+			group = &Group{ID: prefix, Label: prefix, Kind: GroupKindService, Nodes: []string{}, Groups: []*Group{}}
+			serviceGroups[prefix] = group
+		}
+
+		return group
+	}
+
+	for _, node := range l.nodes {
+		container := enclosing(node.ID)
+
+		if containerGroup, ok := containerGroups[node.ID]; ok {
+			if container != "" {
+				containerGroups[container].Groups = append(containerGroups[container].Groups, containerGroup)
+			} else if group := topLevel(node.ID); group != nil {
+				group.Groups = append(group.Groups, containerGroup)
+			} else {
+				l.groups = append(l.groups, containerGroup)
+			}
+
+			continue
+		}
+
+		if container != "" {
+			containerGroups[container].Nodes = append(containerGroups[container].Nodes, node.ID)
+		} else if group := topLevel(node.ID); group != nil {
+			group.Nodes = append(group.Nodes, node.ID)
+		} else {
+			l.ungrouped = append(l.ungrouped, node.ID)
+		}
+	}
+
+	for _, group := range serviceGroups {
+		l.groups = append(l.groups, group)
+	}
+
+	sortGroups(l.groups)
+
+	return l, nil
+}
+
+func sortGroups(groups []*Group) {
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].ID < groups[j].ID
+	})
+
+	for _, group := range groups {
+		sortGroups(group.Groups)
+	}
+}
+
+// label returns the text shown for a node, its name followed by its type when known
+func label(node topology.Node) string {
+	name := node.Name
+	if name == "" {
+		name = node.ID
+	}
+
+	if node.Type == "" {
+		return name
+	}
+
+	return name + "\n" + node.Type
+}
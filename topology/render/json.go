@@ -0,0 +1,56 @@
+package render
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/coordimap/cm-otel-go/topology"
+)
+
+// Document the JSON rendering of the graph. It holds no timestamps so that rendering the same topology twice gives the same document.
+type Document struct {
+	Version string          `json:"version"`
+	Groups  []*Group        `json:"groups"`
+	Nodes   []topology.Node `json:"nodes"`
+	Edges   []DocumentEdge  `json:"edges"`
+}
+
+// DocumentEdge an edge of the Document along with the number of requests and errors seen on it
+type DocumentEdge struct {
+	topology.Edge
+	Requests int64 `json:"requests"`
+	Errors   int64 `json:"errors"`
+}
+
+// JSON writes the graph as an indented Document sorted by node ID and edge
+func JSON(w io.Writer, graph *topology.Graph, opts ...Option) error {
+	l, errLayout := newLayout(graph, opts...)
+	if errLayout != nil {
+		return errLayout
+	}
+
+	doc := Document{
+		Version: topology.PayloadVersion,
+		Groups:  l.groups,
+		Nodes:   make([]topology.Node, 0, len(l.nodes)),
+		Edges:   make([]DocumentEdge, 0, len(l.edges)),
+	}
+
+	for _, node := range l.nodes {
+		doc.Nodes = append(doc.Nodes, node.Node)
+	}
+
+	for _, edge := range l.edges {
+		doc.Edges = append(doc.Edges, DocumentEdge{Edge: edge.Edge, Requests: edge.Stats.Requests, Errors: edge.Stats.Errors})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	if errEncode := encoder.Encode(doc); errEncode != nil {
+		return errors.Join(errors.New("could not encode the topology document"), errEncode)
+	}
+
+	return nil
+}
@@ -0,0 +1,72 @@
+package render
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/coordimap/cm-otel-go/topology"
+)
+
+// mermaidEscaper escapes the characters that cannot appear in a quoted Mermaid label
+var mermaidEscaper = strings.NewReplacer(`"`, "#quot;", "\n", "<br/>")
+
+// Mermaid writes the graph as a Mermaid flowchart. Nodes get short IDs in the order of their internal names so that the output is stable, groups are rendered as subgraphs and unverified edges are dotted.
+func Mermaid(w io.Writer, graph *topology.Graph, opts ...Option) error {
+	l, errLayout := newLayout(graph, opts...)
+	if errLayout != nil {
+		return errLayout
+	}
+
+	ids := map[string]string{}
+	nodes := map[string]topology.Node{}
+	for i, node := range l.nodes {
+		ids[node.ID] = fmt.Sprintf("n%d", i)
+		nodes[node.ID] = node.Node
+	}
+
+	out := bufio.NewWriter(w)
+	subgraphs := 0
+
+	writeNode := func(indent, id string) {
+		fmt.Fprintf(out, "%s%s[\"%s\"]\n", indent, ids[id], mermaidEscaper.Replace(label(nodes[id])))
+	}
+
+	var writeGroup func(indent string, group *Group)
+	writeGroup = func(indent string, group *Group) {
+		fmt.Fprintf(out, "%ssubgraph g%d[\"%s\"]\n", indent, subgraphs, mermaidEscaper.Replace(groupLabel(group)))
+		subgraphs++
+
+		for _, id := range group.Nodes {
+			writeNode(indent+"  ", id)
+		}
+
+		for _, child := range group.Groups {
+			writeGroup(indent+"  ", child)
+		}
+
+		fmt.Fprintf(out, "%send\n", indent)
+	}
+
+	fmt.Fprintln(out, "flowchart LR")
+
+	for _, group := range l.groups {
+		writeGroup("  ", group)
+	}
+
+	for _, id := range l.ungrouped {
+		writeNode("  ", id)
+	}
+
+	for _, edge := range l.edges {
+		arrow := "-->"
+		if edge.Unverified {
+			arrow = "-.->"
+		}
+
+		fmt.Fprintf(out, "  %s %s|%s| %s\n", ids[edge.From], arrow, edge.Kind, ids[edge.To])
+	}
+
+	return out.Flush()
+}
@@ -0,0 +1,140 @@
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	cmotel "github.com/coordimap/cm-otel-go"
+	"github.com/coordimap/cm-otel-go/topology"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func testGraph(t *testing.T) *topology.Graph {
+	t.Helper()
+	t.Setenv(cmotel.EnvServiceNamePrefix, "cluster.namespace")
+
+	processor := topology.NewProcessor()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(processor))
+	cm := cmotel.New(provider.Tracer("test"), "orders")
+
+	cm.NewSpan(cmotel.WithSpanName("pod"))
+	if err := cm.AddComponent(cmotel.WithAddComponentSpanName("pod"), cmotel.WithAddComponentType(cmotel.ComponentTypeGenericContainer)); err != nil {
+		t.Fatalf("AddComponent() error = %v", err)
+	}
+	cm.NewSpan(cmotel.WithSpanName("handler"), cmotel.WithParentSpanName("pod"))
+	cm.NewSpan(cmotel.WithSpanName("query"), cmotel.WithParentSpanName("handler"))
+	cm.EndSpan("query")
+	cm.EndSpan("handler")
+	cm.EndSpan("pod")
+
+	return processor.Graph()
+}
+
+func TestMermaid(t *testing.T) {
+	graph := testGraph(t)
+
+	tests := []struct {
+		name string
+		opts []Option
+		want string
+	}{
+		{
+			name: "grouped",
+			opts: []Option{},
+			want: `flowchart LR
+  subgraph g0["cluster.namespace"]
+    subgraph g1["pod"]
+      n1["pod<br/>coordimap.asset.generic_container"]
+      n0["handler"]
+      n2["query"]
+    end
+  end
+  n0 -->|parent| n2
+  n1 -->|parent| n0
+`,
+		},
+		{
+			name: "without groups",
+			opts: []Option{WithoutServiceGroups(), WithoutContainerGroups()},
+			want: `flowchart LR
+  n0["handler"]
+  n1["pod<br/>coordimap.asset.generic_container"]
+  n2["query"]
+  n0 -->|parent| n2
+  n1 -->|parent| n0
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			if err := Mermaid(out, graph, tt.opts...); err != nil {
+				t.Fatalf("Mermaid() error = %v", err)
+			}
+
+			if got := out.String(); got != tt.want {
+				t.Errorf("Mermaid() = \n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDOT(t *testing.T) {
+	out := &bytes.Buffer{}
+	if err := DOT(out, testGraph(t)); err != nil {
+		t.Fatalf("DOT() error = %v", err)
+	}
+
+	want := `digraph topology {
+	rankdir=LR;
+	node [shape=box];
+	subgraph cluster_0 {
+		label="cluster.namespace";
+		subgraph cluster_1 {
+			label="pod";
+			style=rounded;
+			"cluster.namespace.orders@pod" [label="pod\ncoordimap.asset.generic_container"];
+			"cluster.namespace.orders@handler" [label="handler"];
+			"cluster.namespace.orders@query" [label="query"];
+		}
+	}
+	"cluster.namespace.orders@handler" -> "cluster.namespace.orders@query" [label="parent"];
+	"cluster.namespace.orders@pod" -> "cluster.namespace.orders@handler" [label="parent"];
+}
+`
+	if got := out.String(); got != want {
+		t.Errorf("DOT() = \n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	graph := testGraph(t)
+
+	first := &bytes.Buffer{}
+	if err := JSON(first, graph); err != nil {
+		t.Fatalf("JSON() error = %v", err)
+	}
+
+	second := &bytes.Buffer{}
+	if err := JSON(second, graph); err != nil {
+		t.Fatalf("JSON() error = %v", err)
+	}
+
+	if first.String() != second.String() {
+		t.Errorf("JSON() is not stable, got\n%s\nthen\n%s", first, second)
+	}
+
+	doc := Document{}
+	if err := json.Unmarshal(first.Bytes(), &doc); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+
+	if len(doc.Nodes) != 3 || len(doc.Edges) != 2 || len(doc.Groups) != 1 {
+		t.Errorf("JSON() = %d nodes, %d edges and %d groups, want 3, 2 and 1", len(doc.Nodes), len(doc.Edges), len(doc.Groups))
+	}
+
+	if got := doc.Groups[0].Groups[0]; got.Kind != GroupKindContainer || len(got.Nodes) != 3 {
+		t.Errorf("container group = %+v, want the pod enclosing the 3 nodes", got)
+	}
+}
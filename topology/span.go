@@ -0,0 +1,65 @@
+// Package topology reconstructs the Coordimap components and relationships carried by spans.
+package topology
+
+import (
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Span the parts of a span needed to extract the topology. It can be built from SDK spans or from trace dumps.
+type Span struct {
+	Name       string
+	TraceID    string
+	SpanID     string
+	StartTime  time.Time
+	EndTime    time.Time
+	Error      bool
+	Attributes []attribute.KeyValue
+	Links      []Link
+
+	// Resource the attributes of the resource that produced the span, see cmotel.ResourceComponents
+	Resource []attribute.KeyValue
+}
+
+// Link the parts of a span link needed to extract the topology
+type Link struct {
+	TraceID    string
+	SpanID     string
+	Attributes []attribute.KeyValue
+}
+
+// Duration returns how long the span took or zero if it has not ended
+func (s Span) Duration() time.Duration {
+	if s.EndTime.IsZero() || s.EndTime.Before(s.StartTime) {
+		return 0
+	}
+
+	return s.EndTime.Sub(s.StartTime)
+}
+
+// FromReadOnlySpan converts an SDK span
+func FromReadOnlySpan(s sdktrace.ReadOnlySpan) Span {
+	links := make([]Link, 0, len(s.Links()))
+	for _, link := range s.Links() {
+		links = append(links, Link{
+			TraceID:    link.SpanContext.TraceID().String(),
+			SpanID:     link.SpanContext.SpanID().String(),
+			Attributes: link.Attributes,
+		})
+	}
+
+	return Span{
+		Name:       s.Name(),
+		TraceID:    s.SpanContext().TraceID().String(),
+		SpanID:     s.SpanContext().SpanID().String(),
+		StartTime:  s.StartTime(),
+		EndTime:    s.EndTime(),
+		Error:      s.Status().Code == codes.Error,
+		Attributes: s.Attributes(),
+		Links:      links,
+		Resource:   s.Resource().Attributes(),
+	}
+}
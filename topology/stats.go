@@ -0,0 +1,146 @@
+package topology
+
+import (
+	"time"
+)
+
+// latencyBounds the upper bounds of the latency histogram buckets. Durations above the last bound are counted in an extra overflow bucket.
+var latencyBounds = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// LatencyBounds returns a copy of the upper bounds of the buckets of LatencyHistogram.Counts
+func LatencyBounds() []time.Duration {
+	return append([]time.Duration{}, latencyBounds...)
+}
+
+// EdgeStats the requests that went through an edge, i.e. the spans that carried it
+type EdgeStats struct {
+	Requests int64            `json:"requests"`
+	Errors   int64            `json:"errors"`
+	Latency  LatencyHistogram `json:"latency"`
+}
+
+// LatencyHistogram counts the durations of the spans per LatencyBounds bucket
+type LatencyHistogram struct {
+	// Counts has one more entry than LatencyBounds for the overflow bucket
+	Counts []int64       `json:"counts"`
+	Sum    time.Duration `json:"sum"`
+	Max    time.Duration `json:"max"`
+}
+
+func newEdgeStats() EdgeStats {
+	return EdgeStats{
+		Latency: LatencyHistogram{
+			Counts: make([]int64, len(latencyBounds)+1),
+		},
+	}
+}
+
+func (s *EdgeStats) record(span Span) {
+	s.Requests++
+	if span.Error {
+		s.Errors++
+	}
+
+	s.Latency.record(span.Duration())
+}
+
+func (s *EdgeStats) merge(other EdgeStats) {
+	s.Requests += other.Requests
+	s.Errors += other.Errors
+	s.Latency.merge(other.Latency)
+}
+
+func (h *LatencyHistogram) record(duration time.Duration) {
+	bucket := len(latencyBounds)
+	for i, bound := range latencyBounds {
+		if duration <= bound {
+			bucket = i
+			break
+		}
+	}
+
+	h.Counts[bucket]++
+	h.Sum += duration
+	if duration > h.Max {
+		h.Max = duration
+	}
+}
+
+func (h *LatencyHistogram) merge(other LatencyHistogram) {
+	for i := range other.Counts {
+		if i < len(h.Counts) {
+			h.Counts[i] += other.Counts[i]
+		}
+	}
+
+	h.Sum += other.Sum
+	if other.Max > h.Max {
+		h.Max = other.Max
+	}
+}
+
+func (h LatencyHistogram) clone() LatencyHistogram {
+	h.Counts = append([]int64{}, h.Counts...)
+
+	return h
+}
+
+// Count returns the number of recorded durations
+func (h LatencyHistogram) Count() int64 {
+	total := int64(0)
+	for _, count := range h.Counts {
+		total += count
+	}
+
+	return total
+}
+
+// Mean returns the average recorded duration
+func (h LatencyHistogram) Mean() time.Duration {
+	count := h.Count()
+	if count == 0 {
+		return 0
+	}
+
+	return h.Sum / time.Duration(count)
+}
+
+// Quantile returns the upper bound of the bucket holding the q quantile, 0 <= q <= 1. Durations in the overflow bucket are reported as the maximum recorded duration.
+func (h LatencyHistogram) Quantile(q float64) time.Duration {
+	count := h.Count()
+	if count == 0 {
+		return 0
+	}
+
+	rank := int64(q * float64(count))
+	if rank >= count {
+		rank = count - 1
+	}
+
+	seen := int64(0)
+	for i, bucketCount := range h.Counts {
+		seen += bucketCount
+		if seen > rank {
+			if i < len(latencyBounds) {
+				return latencyBounds[i]
+			}
+
+			break
+		}
+	}
+
+	return h.Max
+}
@@ -0,0 +1,239 @@
+package cmotel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TraceHeaderFormat the wire format used to represent a span context as a header value
+type TraceHeaderFormat string
+
+const (
+	// TraceHeaderFormatW3C the W3C traceparent format, 00-<trace-id>-<span-id>-<trace-flags>
+	TraceHeaderFormatW3C TraceHeaderFormat = "w3c"
+
+	// TraceHeaderFormatB3 the Zipkin B3 single header format, <trace-id>-<span-id>-<sampling-state>
+	TraceHeaderFormatB3 TraceHeaderFormat = "b3"
+
+	// TraceHeaderFormatB3Multi the Zipkin B3 multi header format, X-B3-TraceId, X-B3-SpanId and X-B3-Sampled.
+	// It cannot be represented as a single value and therefore cannot be used in the span map.
+	TraceHeaderFormatB3Multi TraceHeaderFormat = "b3multi"
+
+	// TraceHeaderFormatJaeger the Jaeger uber-trace-id format, <trace-id>:<span-id>:<parent-span-id>:<flags>
+	TraceHeaderFormatJaeger TraceHeaderFormat = "jaeger"
+)
+
+const (
+	// HeaderTraceparent the W3C trace context header
+	HeaderTraceparent = "traceparent"
+
+	// HeaderB3 the B3 single header
+	HeaderB3 = "b3"
+
+	// HeaderB3TraceID the B3 multi header holding the trace id
+	HeaderB3TraceID = "X-B3-TraceId"
+
+	// HeaderB3SpanID the B3 multi header holding the span id
+	HeaderB3SpanID = "X-B3-SpanId"
+
+	// HeaderB3ParentSpanID the B3 multi header holding the parent span id
+	HeaderB3ParentSpanID = "X-B3-ParentSpanId"
+
+	// HeaderB3Sampled the B3 multi header holding the sampling decision
+	HeaderB3Sampled = "X-B3-Sampled"
+
+	// HeaderB3Flags the B3 multi header holding the debug flag
+	HeaderB3Flags = "X-B3-Flags"
+
+	// HeaderUberTraceID the Jaeger trace context header
+	HeaderUberTraceID = "uber-trace-id"
+)
+
+// ParseB3 parses a B3 single header value, <trace-id>-<span-id>[-<sampling-state>[-<parent-span-id>]], and converts it to a remote span context.
+// The trace id can either be 16 or 32 hex characters long. A header that carries only the sampling state is rejected since it has no span to relate to.
+func ParseB3(b3 string) (context.Context, error) {
+	parts := strings.Split(b3, "-")
+
+	if len(parts) < 2 || len(parts) > 4 {
+		return context.TODO(), fmt.Errorf("Invalid b3 header: %s", b3)
+	}
+
+	sampled := ""
+	if len(parts) > 2 {
+		sampled = parts[2]
+	}
+
+	return newRemoteSpanContext(parts[0], parts[1], sampled)
+}
+
+// ParseB3MultiHeader parses the X-B3-* headers and converts them to a remote span context
+func ParseB3MultiHeader(header http.Header) (context.Context, error) {
+	traceID := header.Get(HeaderB3TraceID)
+	spanID := header.Get(HeaderB3SpanID)
+
+	if traceID == "" || spanID == "" {
+		return context.TODO(), errors.New("the b3 trace id and span id headers must both be set")
+	}
+
+	sampled := header.Get(HeaderB3Sampled)
+	if header.Get(HeaderB3Flags) == "1" {
+		sampled = "d"
+	}
+
+	return newRemoteSpanContext(traceID, spanID, sampled)
+}
+
+// ParseUberTraceID parses a Jaeger uber-trace-id header value, <trace-id>:<span-id>:<parent-span-id>:<flags>, and converts it to a remote span context.
+// Jaeger allows the ids to omit their leading zeros, so they are padded before being parsed.
+func ParseUberTraceID(uberTraceID string) (context.Context, error) {
+	parts := strings.Split(uberTraceID, ":")
+
+	if len(parts) != 4 {
+		return context.TODO(), fmt.Errorf("Invalid uber-trace-id header: %s", uberTraceID)
+	}
+
+	var flags byte
+	if _, err := fmt.Sscanf(parts[3], "%x", &flags); err != nil {
+		return context.TODO(), fmt.Errorf("Invalid uber-trace-id flags: %s", parts[3])
+	}
+
+	sampled := "0"
+	if flags&0x01 == 0x01 {
+		sampled = "1"
+	}
+
+	return newRemoteSpanContext(parts[0], parts[1], sampled)
+}
+
+// ParseTraceHeader detects whether the value is a W3C traceparent, a B3 single header or a Jaeger uber-trace-id and parses it accordingly
+func ParseTraceHeader(value string) (context.Context, error) {
+	switch DetectTraceHeaderFormat(value) {
+	case TraceHeaderFormatW3C:
+		return ParseTraceParent(value)
+	case TraceHeaderFormatB3:
+		return ParseB3(value)
+	case TraceHeaderFormatJaeger:
+		return ParseUberTraceID(value)
+	}
+
+	return context.TODO(), fmt.Errorf("Unknown trace header format: %s", value)
+}
+
+// DetectTraceHeaderFormat returns the format of a single trace header value or an empty string if it cannot be detected
+func DetectTraceHeaderFormat(value string) TraceHeaderFormat {
+	if strings.Count(value, ":") == 3 {
+		return TraceHeaderFormatJaeger
+	}
+
+	parts := strings.Split(value, "-")
+	if len(parts) == 4 && len(parts[0]) == 2 {
+		return TraceHeaderFormatW3C
+	}
+
+	if len(parts) >= 2 && (len(parts[0]) == 16 || len(parts[0]) == 32) {
+		return TraceHeaderFormatB3
+	}
+
+	return ""
+}
+
+// FormatTraceHeader converts the span context to a single header value in the requested format
+func FormatTraceHeader(spanCtx trace.SpanContext, format TraceHeaderFormat) (string, error) {
+	if !spanCtx.IsValid() {
+		return "", errors.New("the span context is not valid")
+	}
+
+	switch format {
+	case TraceHeaderFormatW3C, "":
+		return fmt.Sprintf("00-%s-%s-%s", spanCtx.TraceID().String(), spanCtx.SpanID().String(), spanCtx.TraceFlags().String()), nil
+	case TraceHeaderFormatB3:
+		return fmt.Sprintf("%s-%s-%s", spanCtx.TraceID().String(), spanCtx.SpanID().String(), b3Sampled(spanCtx)), nil
+	case TraceHeaderFormatJaeger:
+		return fmt.Sprintf("%s:%s:0:%s", spanCtx.TraceID().String(), spanCtx.SpanID().String(), spanCtx.TraceFlags().String()), nil
+	}
+
+	return "", fmt.Errorf("format %s cannot be represented as a single header value", format)
+}
+
+// InjectTraceHeaders sets the headers of every requested format on the provided header so that downstream services can continue the trace
+func InjectTraceHeaders(spanCtx trace.SpanContext, header http.Header, formats ...TraceHeaderFormat) error {
+	for _, format := range formats {
+		switch format {
+		case TraceHeaderFormatB3Multi:
+			if !spanCtx.IsValid() {
+				return errors.New("the span context is not valid")
+			}
+
+			header.Set(HeaderB3TraceID, spanCtx.TraceID().String())
+			header.Set(HeaderB3SpanID, spanCtx.SpanID().String())
+			header.Set(HeaderB3Sampled, b3Sampled(spanCtx))
+			continue
+		}
+
+		value, errFormat := FormatTraceHeader(spanCtx, format)
+		if errFormat != nil {
+			return errFormat
+		}
+
+		switch format {
+		case TraceHeaderFormatB3:
+			header.Set(HeaderB3, value)
+		case TraceHeaderFormatJaeger:
+			header.Set(HeaderUberTraceID, value)
+		default:
+			header.Set(HeaderTraceparent, value)
+		}
+	}
+
+	return nil
+}
+
+func b3Sampled(spanCtx trace.SpanContext) string {
+	if spanCtx.IsSampled() {
+		return "1"
+	}
+
+	return "0"
+}
+
+func newRemoteSpanContext(traceIDHex, spanIDHex, sampled string) (context.Context, error) {
+	traceID, err := trace.TraceIDFromHex(leftPadHex(traceIDHex, 32))
+	if err != nil {
+		return context.TODO(), err
+	}
+
+	spanID, err := trace.SpanIDFromHex(leftPadHex(spanIDHex, 16))
+	if err != nil {
+		return context.TODO(), err
+	}
+
+	traceFlags := trace.TraceFlags(0)
+	switch sampled {
+	case "1", "d", "true":
+		traceFlags = trace.FlagsSampled
+	case "", "0", "false":
+	default:
+		return context.TODO(), fmt.Errorf("Invalid sampling state: %s", sampled)
+	}
+
+	return trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: traceFlags,
+		TraceState: trace.TraceState{},
+		Remote:     true,
+	})), nil
+}
+
+func leftPadHex(hex string, length int) string {
+	if len(hex) >= length {
+		return hex
+	}
+
+	return strings.Repeat("0", length-len(hex)) + hex
+}
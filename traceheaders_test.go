@@ -0,0 +1,134 @@
+package cmotel
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestParseTraceHeader(t *testing.T) {
+	sampled := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x12, 0x34, 0x56, 0x78, 0x90, 0x12, 0x34, 0x56, 0x78, 0x90, 0x12, 0x34, 0x56, 0x78, 0x90, 0x12},
+		SpanID:     trace.SpanID{0x34, 0x56, 0x78, 0x90, 0x12, 0x34, 0x56, 0x78},
+		TraceFlags: trace.FlagsSampled,
+		Remote:     true,
+	})
+
+	shortTraceID := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{0, 0, 0, 0, 0, 0, 0, 0, 0x12, 0x34, 0x56, 0x78, 0x90, 0x12, 0x34, 0x56},
+		SpanID:  trace.SpanID{0, 0, 0, 0, 0x12, 0x34, 0x56, 0x78},
+		Remote:  true,
+	})
+
+	tests := []struct {
+		name       string
+		value      string
+		wantFormat TraceHeaderFormat
+		want       trace.SpanContext
+		wantErr    bool
+	}{
+		{
+			name:       "w3c",
+			value:      "00-12345678901234567890123456789012-3456789012345678-01",
+			wantFormat: TraceHeaderFormatW3C,
+			want:       sampled,
+		},
+		{
+			name:       "b3 single",
+			value:      "12345678901234567890123456789012-3456789012345678-1",
+			wantFormat: TraceHeaderFormatB3,
+			want:       sampled,
+		},
+		{
+			name:       "b3 single with parent",
+			value:      "12345678901234567890123456789012-3456789012345678-1-0000000000000001",
+			wantFormat: TraceHeaderFormatB3,
+			want:       sampled,
+		},
+		{
+			name:       "b3 single with 64 bit trace id",
+			value:      "1234567890123456-0000000012345678-0",
+			wantFormat: TraceHeaderFormatB3,
+			want:       shortTraceID,
+		},
+		{
+			name:       "uber-trace-id",
+			value:      "12345678901234567890123456789012:3456789012345678:0:1",
+			wantFormat: TraceHeaderFormatJaeger,
+			want:       sampled,
+		},
+		{
+			name:       "uber-trace-id without leading zeros",
+			value:      "1234567890123456:12345678:0:0",
+			wantFormat: TraceHeaderFormatJaeger,
+			want:       shortTraceID,
+		},
+		{
+			name:    "b3 sampling only",
+			value:   "1",
+			wantErr: true,
+		},
+		{
+			name:       "b3 invalid sampling state",
+			value:      "12345678901234567890123456789012-3456789012345678-x",
+			wantFormat: TraceHeaderFormatB3,
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DetectTraceHeaderFormat(tt.value); got != tt.wantFormat {
+				t.Errorf("DetectTraceHeaderFormat() = %v, want %v", got, tt.wantFormat)
+			}
+
+			got, err := ParseTraceHeader(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseTraceHeader() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(trace.SpanContextFromContext(got), tt.want) {
+				t.Errorf("ParseTraceHeader() = %v, want %v", trace.SpanContextFromContext(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestInjectTraceHeaders(t *testing.T) {
+	ctx, err := ParseTraceParent("00-12345678901234567890123456789012-3456789012345678-01")
+	if err != nil {
+		t.Fatalf("ParseTraceParent() error = %v", err)
+	}
+	spanCtx := trace.SpanContextFromContext(ctx)
+
+	header := http.Header{}
+	if err := InjectTraceHeaders(spanCtx, header, TraceHeaderFormatW3C, TraceHeaderFormatB3, TraceHeaderFormatB3Multi, TraceHeaderFormatJaeger); err != nil {
+		t.Fatalf("InjectTraceHeaders() error = %v", err)
+	}
+
+	want := map[string]string{
+		HeaderTraceparent: "00-12345678901234567890123456789012-3456789012345678-01",
+		HeaderB3:          "12345678901234567890123456789012-3456789012345678-1",
+		HeaderB3TraceID:   "12345678901234567890123456789012",
+		HeaderB3SpanID:    "3456789012345678",
+		HeaderB3Sampled:   "1",
+		HeaderUberTraceID: "12345678901234567890123456789012:3456789012345678:0:01",
+	}
+	for key, value := range want {
+		if got := header.Get(key); got != value {
+			t.Errorf("header %s = %v, want %v", key, got, value)
+		}
+	}
+
+	multiCtx, err := ParseB3MultiHeader(header)
+	if err != nil {
+		t.Fatalf("ParseB3MultiHeader() error = %v", err)
+	}
+	if !reflect.DeepEqual(trace.SpanContextFromContext(multiCtx), spanCtx) {
+		t.Errorf("ParseB3MultiHeader() = %v, want %v", trace.SpanContextFromContext(multiCtx), spanCtx)
+	}
+}
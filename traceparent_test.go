@@ -25,13 +25,20 @@ func TestParseTraceParent(t *testing.T) {
 			},
 			wantErr: false,
 			want: trace.NewSpanContext(trace.SpanContextConfig{
-				TraceID:    trace.TraceID{},
-				SpanID:     trace.SpanID{},
+				TraceID:    trace.TraceID{0x12, 0x34, 0x56, 0x78, 0x90, 0x12, 0x34, 0x56, 0x78, 0x90, 0x12, 0x34, 0x56, 0x78, 0x90, 0x12},
+				SpanID:     trace.SpanID{0x34, 0x56, 0x78, 0x90, 0x12, 0x34, 0x56, 0x78},
 				TraceFlags: trace.TraceFlags(0x01),
 				TraceState: trace.TraceState{},
 				Remote:     true,
 			}),
 		},
+		{
+			name: "invalid version",
+			args: args{
+				traceParent: "01-12345678901234567890123456789012-3456789012345678-01",
+			},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -40,7 +47,7 @@ func TestParseTraceParent(t *testing.T) {
 				t.Errorf("ParseTraceParent() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
-			if !reflect.DeepEqual(got, tt.want) {
+			if !reflect.DeepEqual(trace.SpanContextFromContext(got), tt.want) {
 				t.Errorf("ParseTraceParent() = %v, want %v", got, tt.want)
 			}
 		})
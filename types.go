@@ -2,10 +2,13 @@ package cmotel
 
 import (
 	"context"
+	"net/http"
+	"time"
 
 	"go.opentelemetry.io/otel/attribute"
-	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
 	"go.opentelemetry.io/otel/trace"
+
+	"github.com/coordimap/cm-otel-go/internal/semconv"
 )
 
 const (
@@ -18,8 +21,35 @@ const (
 	// SpanAttrRelationship span attribute to mark a relationship
 	SpanAttrRelationship = "coordimap.span_attr.relationship"
 
+	// SpanAttrInternalName span attribute naming a span created by other OTel instrumentation so that it can be the parent of Coordimap spans, see InternalNameFromContext
+	SpanAttrInternalName = "coordimap.span_attr.internal_name"
+
+	// SpanAttrRelationships span attribute holding the relationships drawn after the span started, see CMSpan.RelateFrom, as a list of values formatted like SpanAttrRelationship
+	SpanAttrRelationships = "coordimap.span_attr.relationships"
+
 	// SpanAttrTargetService span attribute to mark a call or connection to another service. This means an outgoing relationship.
 	SpanAttrTargetService = "coordimap.span_attr.target_service"
+
+	// SpanAttrUnverified span and link attribute to mark that the relationship comes from a span map whose signature could not be verified
+	SpanAttrUnverified = "coordimap.span_attr.unverified"
+
+	// SpanAttrUntrustedReason span attribute holding why the incoming trace context was not trusted and only linked to
+	SpanAttrUntrustedReason = "coordimap.span_attr.untrusted_reason"
+
+	// SpanAttrSourceService span attribute to mark a call coming from another service, as announced in the baggage. This means an incoming relationship.
+	SpanAttrSourceService = "coordimap.span_attr.source_service"
+
+	// SpanAttrSourceCluster span attribute holding the k8s cluster of the calling service, as announced in the baggage
+	SpanAttrSourceCluster = "coordimap.span_attr.source_cluster"
+
+	// SpanAttrSourceNamespace span attribute holding the k8s namespace of the calling service, as announced in the baggage
+	SpanAttrSourceNamespace = "coordimap.span_attr.source_namespace"
+
+	// SpanAttrSourcePod span attribute holding the k8s pod of the calling service, as announced in the baggage
+	SpanAttrSourcePod = "coordimap.span_attr.source_pod"
+
+	// SpanAttrTopologyOnly span attribute to mark a span that was only sampled because it carries topology
+	SpanAttrTopologyOnly = "coordimap.span_attr.topology_only"
 )
 
 const (
@@ -30,8 +60,19 @@ const (
 	EnvPodNameType = "POD_NAME"
 
 	// PodNameCompleteType this is the name of the attribute that will hold the full internal name of the pod, <K8S_CLUSTER_NAME>.<NAMESPACE_NAME>.<POD_NAME>
+	//
+	// Deprecated: use IdentityAttrPodFullName. The attribute is still emitted along with it until it is removed.
 	PodNameCompleteType = "POD_NAME"
 
+	// EnvPodUIDType the environment variable that contains the pod UID
+	EnvPodUIDType = "POD_UID"
+
+	// EnvContainerNameType the environment variable that contains the name of the container in the pod
+	EnvContainerNameType = "CONTAINER_NAME"
+
+	// EnvDownwardAPIPath the directory of the downward API volume holding the labels, annotations and uid files of the pod. It defaults to DefaultDownwardAPIPath.
+	EnvDownwardAPIPath = "DOWNWARD_API_PATH"
+
 	// EnvK8SNamespaceName the environment variable that contains the namespace name
 	EnvK8SNamespaceName = "NAMESPACE_NAME"
 
@@ -56,8 +97,67 @@ const (
 	// EnvTraceParentsMapHeaderName contains the header name where the spans will be stored
 	EnvTraceParentsMapHeaderName = "x-COORDIMAP-SPANS"
 
+	// EnvTraceParentsMapSignatureHeaderName contains the header name where the signature of the spans header will be stored
+	EnvTraceParentsMapSignatureHeaderName = "x-COORDIMAP-SPANS-SIGNATURE"
+
 	// EnvServiceNamePrefix preferrably to be used in order to uniquely identify the services
 	EnvServiceNamePrefix = "SERVICE_NAME_PREFIX"
+
+	// EnvTracesSamplerRatio the ratio, between 0 and 1, of the parent based trace ID ratio sampler
+	EnvTracesSamplerRatio = "TRACES_SAMPLER_RATIO"
+
+	// EnvBatchMaxExportSize the maximum number of spans exported in one batch
+	EnvBatchMaxExportSize = "BATCH_MAX_EXPORT_SIZE"
+
+	// EnvBatchMaxQueueSize the maximum number of spans waiting to be exported
+	EnvBatchMaxQueueSize = "BATCH_MAX_QUEUE_SIZE"
+
+	// EnvBatchTimeout the maximum delay, in milliseconds, before a batch is exported
+	EnvBatchTimeout = "BATCH_TIMEOUT_MS"
+
+	// EnvTracesExporter the exporter created by InitFromEnv, one of otlp, stdout, file or none
+	EnvTracesExporter = "TRACES_EXPORTER"
+
+	// EnvTracesExportFile the file the spans are written to when using the file exporter
+	EnvTracesExportFile = "TRACES_FILE"
+
+	// EnvOTLPEndpoint the base endpoint of the OTLP receiver, e.g. http://collector:4318
+	EnvOTLPEndpoint = "OTLP_ENDPOINT"
+
+	// EnvOTLPProtocol the OTLP protocol, either grpc or http/protobuf
+	EnvOTLPProtocol = "OTLP_PROTOCOL"
+
+	// EnvOTLPHeaders the headers sent with every export, as comma separated key=value pairs
+	EnvOTLPHeaders = "OTLP_HEADERS"
+
+	// EnvOTLPInsecure disables TLS when set to true
+	EnvOTLPInsecure = "OTLP_INSECURE"
+
+	// EnvOTLPCertificate the PEM file of the certificate authority used to verify the OTLP receiver
+	EnvOTLPCertificate = "OTLP_CERTIFICATE"
+
+	// EnvOTLPCompression the compression of the exports, either gzip or none
+	EnvOTLPCompression = "OTLP_COMPRESSION"
+
+	// EnvOTLPTimeout the maximum duration, in milliseconds, of an export
+	EnvOTLPTimeout = "OTLP_TIMEOUT_MS"
+
+	// EnvDebugEndpoint enables the debug endpoint of the debug package when set to true
+	EnvDebugEndpoint = "DEBUG_ENDPOINT"
+)
+
+const (
+	// IdentityAttrCluster resource attribute holding the k8s cluster the service runs in, see LoadEnvVarsAsResource
+	IdentityAttrCluster = "coordimap.identity.cluster"
+
+	// IdentityAttrNamespace resource attribute holding the k8s namespace the service runs in
+	IdentityAttrNamespace = "coordimap.identity.namespace"
+
+	// IdentityAttrPodFullName resource attribute holding the full name of the pod, <cluster>.<namespace>.<pod>
+	IdentityAttrPodFullName = "coordimap.identity.pod_full_name"
+
+	// IdentityAttrServiceFullName resource attribute holding the full name of the service, the service part of its internal names
+	IdentityAttrServiceFullName = "coordimap.identity.service_full_name"
 )
 
 const (
@@ -108,8 +208,12 @@ type contextKey string
 const ContextKey contextKey = "cmotel"
 
 type cmSpan struct {
-	ctx  context.Context
-	span trace.Span
+	ctx        context.Context
+	span       trace.Span
+	unverified bool
+
+	// relationships the relationships drawn through the CMSpan handles of this span, shared by all its handles
+	relationships *[]string
 }
 
 type newSpanOpts struct {
@@ -119,6 +223,8 @@ type newSpanOpts struct {
 	to           []string
 	internalFrom []string
 	externalFrom []string
+	startOpts    []trace.SpanStartOption
+	component    *addComponentOpts
 }
 
 type addComponentOpts struct {
@@ -134,12 +240,31 @@ type addComponentOptionType = func(c *addComponentOpts) error
 // SpanOption the function parameter for creating a Span
 type SpanOption = func(c *newSpanOpts) error
 
+// Option the function parameter for configuring a CMOtel object
+type Option = func(cm *cmOtel)
+
 type cmOtel struct {
 	tracer             trace.Tracer
 	serviceName        string
 	spans              map[string]cmSpan
 	relationships      map[string]string
 	spanIDToNameMapper map[string]string
+	headerFormats      []TraceHeaderFormat
+	spanMapOpts        []SpanMapOption
+	signingKey         *SpanMapKey
+	signingTTL         time.Duration
+	registry           *spanRegistry
+	linter             *linter
+	duplicatePolicy    DuplicateSpanNamePolicy
+	podBaggage         bool
+
+	// optionsErr the errors of the options, returned by every NewSpan since Option cannot return an error
+	optionsErr error
+
+	// instances, stacks and aliases track the instances of the spans under the DuplicateSpanNameStack policy
+	instances map[string]int
+	stacks    map[string][]string
+	aliases   map[string]string
 }
 
 // CMOtel The interface that helps manage Coordimap spans
@@ -155,10 +280,30 @@ type CMOtel interface {
 	SetSpanFromTraceparent(name, traceparent string) error
 }
 
+// ExtendedCMOtel the methods of the objects created by New on top of CMOtel, which is left as is so that other implementations and mocks of CMOtel keep compiling.
+// The objects returned by Singleton and CreateSingleton implement it too, e.g. cmotel.Singleton().(cmotel.ExtendedCMOtel).
+type ExtendedCMOtel interface {
+	CMOtel
+	SetSpanFromB3(name, b3 string) error
+	SetSpanFromB3MultiHeader(name string, header http.Header) error
+	SetSpanFromUberTraceID(name, uberTraceID string) error
+	SetSpanFromTraceHeader(name, value string) error
+	InjectSpanHeaders(header http.Header, spanNames []string) error
+	MarkSpanUnverified(name string) error
+	Spans() []SpanInfo
+	LintReport() []LintIssue
+	SpanName(span trace.Span) (string, error)
+	NewCMSpan(opts ...SpanOption) (CMSpan, error)
+	Span(name string) (CMSpan, error)
+}
+
 // CMComponent describes the main values of the component
 type CMComponent struct {
 	Name       string            `json:"name"`
 	InternalID string            `json:"internal_id"`
 	Type       string            `json:"type"`
 	Data       map[string]string `json:"data"`
+
+	// IsContainer marks the component as enclosing the components of its child spans, e.g. a pod or a cloud region
+	IsContainer bool `json:"is_container,omitempty"`
 }
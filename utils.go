@@ -8,12 +8,12 @@ import (
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 
-	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
-	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+
+	"github.com/coordimap/cm-otel-go/internal/semconv"
 )
 
 // GetEnvWithPrefix tries to fetch the environment variable value of <prefix><env>. In case it is not set then returns an empty string
@@ -23,11 +23,30 @@ func GetEnvWithPrefix(prefix string, env string) string {
 	return os.Getenv(envKey)
 }
 
-// GetServiceName returns the service name based on the unique service prefix.
+// GetServiceName returns the service name based on the unique service prefix. It names the service in the internal names and in the IdentityAttrServiceFullName resource attribute.
 func GetServiceName(name string) string {
 	return fmt.Sprintf("%s.%s", GetUniqueServicePrefix(), name)
 }
 
+// SplitInternalName splits an internal name, <service prefix>.<service name>@<span name>, into its parts. Names without @ are considered to be a service name, optionally prefixed.
+// The service name is the part after the last dot, so service names containing dots are split wrongly.
+// It returns the service prefix, the service name and the span name.
+func SplitInternalName(internalName string) (string, string, string) {
+	service := internalName
+	spanName := ""
+
+	if index := strings.LastIndex(internalName, "@"); index != -1 {
+		service = internalName[:index]
+		spanName = internalName[index+1:]
+	}
+
+	if index := strings.LastIndex(service, "."); index != -1 {
+		return service[:index], service[index+1:], spanName
+	}
+
+	return "", service, spanName
+}
+
 // GetUniqueServicePrefix returns the prefix that will be used to name the service and any other remote services that are being called. It takes into account the environment variables being set.
 // It first checks if the SERVICE_NAME_PREFIX env variable is set. If so that it generates the name by using the value as a prefix. Otherwise if K8S_CLUSTER_NAME and NAMESPACE_NAME have been set
 // then it uses both of them to generate the service name. It also takes into consideration the CM_PREFIX env variable when looking for the previously mentioned environment variables. If none has been set then it returns the provided name.
@@ -47,42 +66,27 @@ func GetUniqueServicePrefix() string {
 	return ""
 }
 
-// InitDefaultTracerProvider initialize the default provider
+// InitDefaultTracerProvider initialize the default provider. The invalid environment variables are reported to the OTel error handler and their defaults are used, as the function has never failed because of them.
+//
+// Deprecated: the provider cannot be flushed nor shut down, use InitTracerProvider instead.
 func InitDefaultTracerProvider(exporter sdktrace.SpanExporter) error {
-	prefix := GetEnvWithPrefix("", EnvCmPrefix)
-	serviceName := GetServiceName(GetEnvWithPrefix(prefix, EnvServiceName))
-
-	res, errRes := resource.Merge(
-		resource.NewWithAttributes(
-			semconv.SchemaURL,
-			semconv.ServiceName(serviceName),
-		),
-		LoadEnvVarsAsResource(prefix),
-	)
-
-	if errRes != nil {
-		return errRes
-	}
+	_, errInit := InitTracerProvider(exporter, func(opt *providerOpts) error {
+		opt.ignoreEnvErrors = true
 
-	tracer := sdktrace.NewTracerProvider(
-		sdktrace.WithBatcher(exporter, sdktrace.WithMaxExportBatchSize(50)),
-		sdktrace.WithResource(res),
-	)
+		return nil
+	})
 
-	otel.SetTracerProvider(tracer)
-
-	return nil
+	return errInit
 }
 
 // LoadRESTEndpointAtributes returns an array of KeyValues related to the given request object
 func LoadRESTEndpointAtributes(r *http.Request) []attribute.KeyValue {
 	foundAttributes := []attribute.KeyValue{}
 
-	foundAttributes = append(foundAttributes,
-		semconv.HTTPMethod(r.Method),
-		semconv.HTTPURL(r.RequestURI),
-		semconv.HTTPRoute(r.URL.Path),
-	)
+	conventions := semconv.FromEnv()
+	foundAttributes = append(foundAttributes, conventions.HTTPMethod(r.Method)...)
+	foundAttributes = append(foundAttributes, conventions.HTTPTarget(r.RequestURI, r.URL.Path, r.URL.RawQuery)...)
+	foundAttributes = append(foundAttributes, conventions.HTTPRoute(r.URL.Path)...)
 
 	var buf bytes.Buffer
 	tee := io.TeeReader(r.Body, &buf)
@@ -97,7 +101,10 @@ func LoadRESTEndpointAtributes(r *http.Request) []attribute.KeyValue {
 		}
 	}
 
-	return []attribute.KeyValue{}
+	// the handler still has to read the body
+	r.Body = io.NopCloser(&buf)
+
+	return foundAttributes
 }
 
 // ExtractJSONKeys returns a slice of all the keys found in a json byte slice. If there are nested objects they are of the format parentkey.childkey
@@ -0,0 +1,68 @@
+package cmotel
+
+import (
+	"io"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/coordimap/cm-otel-go/internal/semconv"
+)
+
+func TestLoadRESTEndpointAtributes(t *testing.T) {
+	tests := []struct {
+		name  string
+		optIn string
+		want  []attribute.KeyValue
+	}{
+		{
+			name:  "old names by default",
+			optIn: "",
+			want: []attribute.KeyValue{
+				attribute.String("http.method", "POST"),
+				attribute.String("http.url", "/users?id=1"),
+				attribute.String("http.route", "/users"),
+			},
+		},
+		{
+			name:  "stable names",
+			optIn: "http",
+			want: []attribute.KeyValue{
+				attribute.String("http.request.method", "POST"),
+				attribute.String("url.path", "/users"),
+				attribute.String("url.query", "id=1"),
+				attribute.String("http.route", "/users"),
+			},
+		},
+		{
+			name:  "old and stable names",
+			optIn: "http/dup",
+			want: []attribute.KeyValue{
+				attribute.String("http.method", "POST"),
+				attribute.String("http.request.method", "POST"),
+				attribute.String("http.url", "/users?id=1"),
+				attribute.String("url.path", "/users"),
+				attribute.String("url.query", "id=1"),
+				attribute.String("http.route", "/users"),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(semconv.EnvStabilityOptIn, tt.optIn)
+
+			r := httptest.NewRequest("POST", "/users?id=1", strings.NewReader(`{"name":"x"}`))
+
+			if got := LoadRESTEndpointAtributes(r); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LoadRESTEndpointAtributes() = %v, want %v", got, tt.want)
+			}
+
+			if body, _ := io.ReadAll(r.Body); string(body) != `{"name":"x"}` {
+				t.Errorf("body = %s, want it left readable", body)
+			}
+		})
+	}
+}